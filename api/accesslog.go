@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access log formats accepted in Config.AccessLogFormat
+const (
+	AccessLogJSON     = "json"
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+)
+
+// clfTimeLayout is the timestamp layout used by Apache and Nginx access logs
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogger writes one Common or Combined Log Format line per request
+type accessLogger struct {
+	mu       sync.Mutex
+	out      io.Writer
+	combined bool
+}
+
+// newAccessLogger returns nil for the default JSON format, which is handled
+// by the structured request log instead
+func newAccessLogger(format string, out io.Writer) (*accessLogger, error) {
+	switch format {
+	case "", AccessLogJSON:
+		return nil, nil
+	case AccessLogCommon, AccessLogCombined:
+		return &accessLogger{out: out, combined: format == AccessLogCombined}, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+}
+
+// log writes the access log line for a finished request, e.g.
+// 127.0.0.1 - - [10/Oct/2025:13:55:36 +0000] "GET /users?limit=5 HTTP/1.1" 200 512
+func (al *accessLogger) log(r *http.Request, uri string, status, size int, start time.Time) {
+	bytes := "-"
+	if size > 0 {
+		bytes = strconv.Itoa(size)
+	}
+
+	line := fmt.Sprintf("%s - - [%s] %q %d %s",
+		clientIP(r), start.Format(clfTimeLayout),
+		r.Method+" "+uri+" "+r.Proto, status, bytes)
+	if al.combined {
+		line += fmt.Sprintf(" %q %q", orDash(r.Referer()), orDash(r.UserAgent()))
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	io.WriteString(al.out, line+"\n")
+}
+
+// orDash returns "-" for empty values, as access logs expect
+func orDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
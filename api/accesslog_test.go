@@ -0,0 +1,52 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			AccessLogCommon,
+			`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /health\?verbose=1 HTTP/1\.1" 200 \d+\n$`,
+		},
+		{
+			AccessLogCombined,
+			`^192\.0\.2\.1 - - \[[^\]]+\] "GET /health\?verbose=1 HTTP/1\.1" 200 \d+ "https://example\.com/" "test-agent"\n$`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			s, _ := newTestServer(t, Config{AccessLogFormat: tt.format, AccessLogOutput: &out})
+
+			req := httptest.NewRequest(http.MethodGet, "/health?verbose=1", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("Referer", "https://example.com/")
+			req.Header.Set("User-Agent", "test-agent")
+			serve(s, req)
+
+			if !regexp.MustCompile(tt.want).MatchString(out.String()) {
+				t.Errorf("access log line %q doesn't match %s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogDefaultsToJSON(t *testing.T) {
+	var out bytes.Buffer
+	s, _ := newTestServer(t, Config{AccessLogOutput: &out})
+
+	serve(s, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if out.Len() != 0 {
+		t.Errorf("access log written in the default format: %q", out.String())
+	}
+}
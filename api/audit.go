@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/chrisp986/trader-backend/apperror"
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// recordAudit adds an entry to the audit trail for a change that has already
+// succeeded. A failure is logged but doesn't fail the request, and the entry
+// is written even if the client has gone away.
+func (s *Server) recordAudit(r *http.Request, userID int, action, entity string, entityID int, detail any) {
+	if s.audit == nil {
+		return
+	}
+	logger := LoggerFromContext(r.Context())
+
+	entry := &db.AuditEntry{Action: action, Entity: entity, EntityID: entityID}
+	if userID != 0 {
+		entry.UserID = &userID
+	}
+	if detail != nil {
+		b, err := json.Marshal(detail)
+		if err != nil {
+			logger.Error("Failed to encode audit detail", zap.String("action", action), zap.Error(err))
+		} else {
+			entry.Detail = b
+		}
+	}
+
+	if err := s.audit.Record(context.WithoutCancel(r.Context()), entry); err != nil {
+		logger.Error("Failed to record audit entry",
+			zap.String("action", action),
+			zap.String("entity", entity),
+			zap.Int("entity_id", entityID),
+			zap.Error(err),
+		)
+	}
+}
+
+// ListAuditResponse is a page of audit entries plus the total count for paging
+type ListAuditResponse struct {
+	Entries []*db.AuditEntry `json:"entries"`
+	Total   int              `json:"total"`
+}
+
+// listAuditHandler returns a page of the audit trail, newest first, selected
+// by ?limit= and ?offset= and optionally filtered to the changes made by
+// ?user_id=
+func (s *Server) listAuditHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := queryInt(r, "user_id", 0)
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+	if userID < 0 {
+		s.writeAppError(w, r, apperror.BadRequest("user_id must be a positive integer"))
+		return
+	}
+
+	limit, offset, _, err := parseListParams(r)
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+
+	entries, err := s.audit.List(r.Context(), userID, limit, offset)
+	if err != nil {
+		s.writeAppError(w, r, apperror.Internal("Failed to list audit entries", err))
+		return
+	}
+
+	total, err := s.audit.Count(r.Context(), userID)
+	if err != nil {
+		s.writeAppError(w, r, apperror.Internal("Failed to count audit entries", err))
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, ListAuditResponse{Entries: entries, Total: total}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode audit response", zap.Error(err))
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCreateUserRecordsAudit(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user",
+		`{"user_name":"john","email":"john@example.com","password":"secret-password"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+
+	rec = serve(s, adminRequest(http.MethodGet, "/v1/audit", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("audit status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp ListAuditResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 1 || len(resp.Entries) != 1 {
+		t.Fatalf("audit = %d entries, total %d, want 1", len(resp.Entries), resp.Total)
+	}
+	entry := resp.Entries[0]
+	if entry.Action != db.AuditActionCreate || entry.Entity != "user" || entry.EntityID == 0 {
+		t.Errorf("entry = %+v, want a user create", entry)
+	}
+	var detail map[string]string
+	if err := json.Unmarshal(entry.Detail, &detail); err != nil || detail["user_name"] != "john" {
+		t.Errorf("detail = %s, want the user name", entry.Detail)
+	}
+	if _, ok := detail["password"]; ok {
+		t.Errorf("detail exposes the password: %s", entry.Detail)
+	}
+
+	rec = serve(s, adminRequest(http.MethodGet, fmt.Sprintf("/v1/audit?user_id=%d", entry.EntityID+1), nil))
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Total != 0 {
+		t.Errorf("audit for another user = %+v, %v, want none", resp, err)
+	}
+}
+
+func TestAuditFailureDoesNotFailRequest(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, _ := newLoggedTestServer(t, Config{}, zap.New(core))
+	if _, err := s.dbManager.(*db.DatabaseManager).ExecuteStatement("DROP TABLE audit_log"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user",
+		`{"user_name":"john","email":"john@example.com","password":"secret-password"}`))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if n := logs.FilterMessage("Failed to record audit entry").Len(); n != 1 {
+		t.Errorf("got %d audit failure logs, want 1", n)
+	}
+}
+
+func TestAuditRequiresAdminToken(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	if rec := serve(s, jsonRequest(http.MethodGet, "/v1/audit", "")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+const userIDContextKey = contextKey("user_id")
+
+// GetUserID returns the authenticated user id stored by authMiddleware
+func GetUserID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(userIDContextKey).(int)
+	return id, ok
+}
+
+// IssueToken returns an HS256 JWT for userID that expires after ttl
+func (s *Server) IssueToken(userID int, ttl time.Duration) (string, error) {
+	if len(s.config.JWTSecret) == 0 {
+		return "", errors.New("jwt secret is not configured")
+	}
+
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.config.JWTSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, nil
+}
+
+// parseToken verifies an HS256 token and returns the user id in its subject
+func (s *Server) parseToken(tokenString string) (int, error) {
+	if len(s.config.JWTSecret) == 0 {
+		return 0, errors.New("jwt secret is not configured")
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return s.config.JWTSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid subject %q", claims.Subject)
+	}
+	return id, nil
+}
+
+// authMiddleware requires a valid bearer token for a user that still exists
+// and stores the user id in the request context for GetUserID
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := LoggerFromContext(r.Context())
+
+		tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			writeError(w, r, http.StatusUnauthorized, "A bearer token is required")
+			return
+		}
+
+		userID, err := s.parseToken(tokenString)
+		if err != nil {
+			logger.Info("Rejected invalid token", zap.Error(err))
+			writeError(w, r, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		exists, err := s.user.Exists(r.Context(), userID)
+		if err != nil {
+			logger.Error("Failed to check token user", zap.Int("user_id", userID), zap.Error(err))
+			writeServerError(w, r, err, "Failed to authenticate request")
+			return
+		}
+		if !exists {
+			writeError(w, r, http.StatusUnauthorized, "Token user no longer exists")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// authorize adds a bearer token for userID, issued by s, to req
+func authorize(t *testing.T, s *Server, req *http.Request, userID int) *http.Request {
+	t.Helper()
+
+	token, err := s.IssueToken(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "alice")
+	deleted := insertTestUser(t, store, "bob")
+	if err := store.User.Delete(context.Background(), deleted.UserID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	token := func(s *Server, userID int, ttl time.Duration) string {
+		token, err := s.IssueToken(userID, ttl)
+		if err != nil {
+			t.Fatalf("IssueToken: %v", err)
+		}
+		return "Bearer " + token
+	}
+	otherSecret := &Server{config: Config{JWTSecret: []byte("another-secret-another-secret-xx")}}
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"valid token", token(s, user.UserID, time.Hour), http.StatusOK},
+		{"expired token", token(s, user.UserID, -time.Minute), http.StatusUnauthorized},
+		{"bad signature", token(otherSecret, user.UserID, time.Hour), http.StatusUnauthorized},
+		{"missing header", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"deleted user", token(s, deleted.UserID, time.Hour), http.StatusUnauthorized},
+	}
+
+	handler := s.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := GetUserID(r.Context())
+		if !ok {
+			t.Error("GetUserID found no user id in an authenticated request")
+		}
+		io.WriteString(w, strconv.Itoa(id))
+	}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != strconv.Itoa(user.UserID) {
+				t.Errorf("handler saw user id %s, want %d", rec.Body, user.UserID)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// maxBarBatch bounds the bars accepted by one POST /v1/bars request
+const maxBarBatch = 5000
+
+// InsertBarsResponse reports how many of the posted bars were new
+type InsertBarsResponse struct {
+	Received int `json:"received"`
+	Inserted int `json:"inserted"`
+}
+
+// validateBars checks every bar up front so a bad row rejects the batch
+// before anything is written. Field names are prefixed with the bar's index.
+func validateBars(bars []db.Bar) map[string]string {
+	fields := make(map[string]string)
+
+	switch {
+	case len(bars) == 0:
+		fields["bars"] = "must contain at least one bar"
+	case len(bars) > maxBarBatch:
+		fields["bars"] = fmt.Sprintf("must contain at most %d bars", maxBarBatch)
+	default:
+		for i := range bars {
+			for field, problem := range bars[i].Validate() {
+				fields[fmt.Sprintf("bars[%d].%s", i, field)] = problem
+			}
+		}
+	}
+
+	return fields
+}
+
+// insertBarsHandler ingests a JSON array of OHLCV bars. Bars that already
+// exist are skipped, so clients can safely retry a batch.
+func (s *Server) insertBarsHandler(w http.ResponseWriter, r *http.Request) {
+	var bars []db.Bar
+	if err := decodeJSON(w, r, &bars); err != nil {
+		return
+	}
+
+	if fields := validateBars(bars); len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	inserted, err := s.bar.InsertBatch(r.Context(), bars)
+	if err != nil {
+		if cerr, ok := db.AsConstraintError(err); ok {
+			s.writeConstraintError(w, r, cerr)
+			return
+		}
+		LoggerFromContext(r.Context()).Error("Failed to insert bars", zap.Int("count", len(bars)), zap.Error(err))
+		writeServerError(w, r, err, "Failed to insert bars")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, InsertBarsResponse{Received: len(bars), Inserted: inserted}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode bars response", zap.Error(err))
+	}
+}
+
+// BarsResponse is a time-ordered run of bars
+type BarsResponse struct {
+	Bars []db.Bar `json:"bars"`
+}
+
+// queryBarsHandler returns the bars for ?symbol= and ?timeframe= between the
+// optional ?from= and ?to= timestamps, inclusive. ?limit= is capped at
+// db.MaxBarLimit.
+func (s *Server) queryBarsHandler(w http.ResponseWriter, r *http.Request) {
+	symbol, err := queryFilter(r, "symbol")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if symbol == "" {
+		writeError(w, r, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	timeframe := r.URL.Query().Get("timeframe")
+	if !slices.Contains(db.Timeframes, timeframe) {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("timeframe must be one of %v", db.Timeframes))
+		return
+	}
+
+	from, err := queryTime(r, "from")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := queryTime(r, "to")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		writeError(w, r, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", db.DefaultBarLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit < 1 {
+		writeError(w, r, http.StatusBadRequest, "limit must be positive")
+		return
+	}
+
+	bars, err := s.bar.Query(r.Context(), symbol, timeframe, from, to, limit)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to query bars", zap.String("symbol", symbol), zap.Error(err))
+		writeServerError(w, r, err, "Failed to query bars")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, BarsResponse{Bars: bars}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode bars response", zap.Error(err))
+	}
+}
+
+// defaultOHLCInterval is used when ohlcHandler is given no ?interval=
+const defaultOHLCInterval = "1d"
+
+// ohlcHandler aggregates the trades of the {symbol} URL parameter into bars
+// of ?interval= (1m, 1h or 1d, default 1d) between the optional ?from= and
+// ?to= timestamps, inclusive. ?limit= is capped at db.MaxBarLimit, and an
+// unknown instrument is a 404.
+func (s *Server) ohlcHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := db.NormalizeSymbol(chi.URLParam(r, "symbol"))
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = defaultOHLCInterval
+	}
+	if _, ok := db.OHLCIntervals[interval]; !ok {
+		intervals := slices.Sorted(maps.Keys(db.OHLCIntervals))
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("interval must be one of %v", intervals))
+		return
+	}
+
+	from, err := queryTime(r, "from")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := queryTime(r, "to")
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		writeError(w, r, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", db.DefaultBarLimit)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit < 1 {
+		writeError(w, r, http.StatusBadRequest, "limit must be positive")
+		return
+	}
+
+	if _, err := s.instrument.GetBySymbol(r.Context(), symbol); err != nil {
+		if errors.Is(err, db.ErrNoRecord) {
+			writeError(w, r, http.StatusNotFound, "Instrument not found")
+			return
+		}
+		LoggerFromContext(r.Context()).Error("Failed to get instrument", zap.String("symbol", symbol), zap.Error(err))
+		writeServerError(w, r, err, "Failed to get price bars")
+		return
+	}
+
+	bars, err := s.trade.OHLC(r.Context(), symbol, interval, from, to, limit)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to aggregate price bars", zap.String("symbol", symbol), zap.Error(err))
+		writeServerError(w, r, err, "Failed to get price bars")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, BarsResponse{Bars: bars}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode bars response", zap.Error(err))
+	}
+}
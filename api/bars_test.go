@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+)
+
+const testBarsBody = `[
+	{"symbol":"AAPL","timeframe":"1m","ts":"2024-01-02T14:30:00Z","open":100,"high":102,"low":99,"close":101,"volume":1000},
+	{"symbol":"AAPL","timeframe":"1m","ts":"2024-01-02T14:31:00Z","open":101,"high":103,"low":100,"close":102,"volume":800}
+]`
+
+func TestInsertBars(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	post := func(body string) (int, InsertBarsResponse, ErrorResponse) {
+		t.Helper()
+		rec := serve(s, adminRequest(http.MethodPost, "/v1/bars", strings.NewReader(body)))
+
+		var resp InsertBarsResponse
+		var errResp ErrorResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+		} else if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+			t.Fatalf("decode error response: %v", err)
+		}
+		return rec.Code, resp, errResp
+	}
+
+	if status, resp, _ := post(testBarsBody); status != http.StatusOK || resp != (InsertBarsResponse{Received: 2, Inserted: 2}) {
+		t.Errorf("first batch = %d %+v, want 200 with 2 inserted", status, resp)
+	}
+	if status, resp, _ := post(testBarsBody); status != http.StatusOK || resp != (InsertBarsResponse{Received: 2, Inserted: 0}) {
+		t.Errorf("repeated batch = %d %+v, want 200 with none inserted", status, resp)
+	}
+
+	malformed := strings.Replace(testBarsBody, `"high":103`, `"high":90`, 1)
+	status, _, errResp := post(malformed)
+	if status != http.StatusUnprocessableEntity || errResp.Fields["bars[1].high"] == "" {
+		t.Errorf("malformed batch = %d %v, want 422 naming bars[1].high", status, errResp.Fields)
+	}
+
+	if status, _, _ := post(`[{"symbol":`); status != http.StatusBadRequest {
+		t.Errorf("invalid JSON status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestInsertBarsRequiresAdminToken(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	req := adminRequest(http.MethodPost, "/v1/bars", strings.NewReader(testBarsBody))
+	req.Header.Del("X-Admin-Token")
+	if rec := serve(s, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestQueryBars(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	if rec := serve(s, adminRequest(http.MethodPost, "/v1/bars", strings.NewReader(testBarsBody))); rec.Code != http.StatusOK {
+		t.Fatalf("insert status = %d: %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		bars   int
+	}{
+		{"all", "symbol=aapl&timeframe=1m", http.StatusOK, 2},
+		{"range", "symbol=AAPL&timeframe=1m&from=2024-01-02T14:31:00Z&to=2024-01-02T15:00:00Z", http.StatusOK, 1},
+		{"empty range", "symbol=AAPL&timeframe=1m&from=2025-01-01T00:00:00Z", http.StatusOK, 0},
+		{"limit", "symbol=AAPL&timeframe=1m&limit=1", http.StatusOK, 1},
+		{"bad from", "symbol=AAPL&timeframe=1m&from=yesterday", http.StatusBadRequest, 0},
+		{"from after to", "symbol=AAPL&timeframe=1m&from=2024-01-03T00:00:00Z&to=2024-01-02T00:00:00Z", http.StatusBadRequest, 0},
+		{"bad timeframe", "symbol=AAPL&timeframe=2m", http.StatusBadRequest, 0},
+		{"missing symbol", "timeframe=1m", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/bars?"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp BarsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Bars) != tt.bars {
+				t.Errorf("got %d bars, want %d", len(resp.Bars), tt.bars)
+			}
+		})
+	}
+}
+
+func TestPriceOHLC(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	alice := insertTestUser(t, store, "alice")
+	bob := insertTestUser(t, store, "bob")
+	buy := insertTestOrder(t, store, alice.UserID, "AAPL", db.OrderSideBuy, 100, 110)
+	sell := insertTestOrder(t, store, bob.UserID, "AAPL", db.OrderSideSell, 100, 90)
+	// Each fill is stored once per side, as the matching engine records it
+	for _, tick := range []struct {
+		qty, price float64
+		at         string
+	}{
+		{1, 100, "2024-01-02 09:30:00"},
+		{2, 103, "2024-01-02 11:00:00"},
+		{1, 98, "2024-01-02 14:00:00"},
+		{3, 101, "2024-01-02 16:00:00"},
+		{1, 120, "2024-01-03 10:00:00"},
+	} {
+		for _, order := range []*db.Order{buy, sell} {
+			trade := &db.Trade{OrderID: order.OrderID, Symbol: "AAPL", Quantity: tick.qty, Price: tick.price}
+			if err := store.Trade.Insert(context.Background(), trade); err != nil {
+				t.Fatalf("Insert trade: %v", err)
+			}
+			if _, err := store.Trade.DB.Exec("UPDATE trades SET executed_at = ? WHERE id = ?", tick.at, trade.TradeID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/prices/aapl/ohlc?interval=1d&from=2024-01-02T00:00:00Z&to=2024-01-02T23:59:59Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp BarsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := db.Bar{Symbol: "AAPL", Timeframe: "1d", Timestamp: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Open: 100, High: 103, Low: 98, Close: 101, Volume: 7}
+	if len(resp.Bars) != 1 || !resp.Bars[0].Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("bars = %+v, want one bar for 2024-01-02", resp.Bars)
+	}
+	got := resp.Bars[0]
+	got.Timestamp = want.Timestamp
+	if got != want {
+		t.Errorf("bar = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		bars   int
+	}{
+		{"default interval", "/v1/prices/AAPL/ohlc", http.StatusOK, 2},
+		{"hourly", "/v1/prices/AAPL/ohlc?interval=1h", http.StatusOK, 5},
+		{"limit", "/v1/prices/AAPL/ohlc?interval=1m&limit=2", http.StatusOK, 2},
+		{"bad interval", "/v1/prices/AAPL/ohlc?interval=5m", http.StatusBadRequest, 0},
+		{"from after to", "/v1/prices/AAPL/ohlc?from=2024-01-03T00:00:00Z&to=2024-01-02T00:00:00Z", http.StatusBadRequest, 0},
+		{"bad limit", "/v1/prices/AAPL/ohlc?limit=0", http.StatusBadRequest, 0},
+		{"unknown symbol", "/v1/prices/NOPE/ohlc", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp BarsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Bars) != tt.bars {
+				t.Errorf("got %d bars, want %d", len(resp.Bars), tt.bars)
+			}
+		})
+	}
+}
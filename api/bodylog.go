@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxLoggedBodyLen caps the body text written to debug logs
+	maxLoggedBodyLen = 4096
+	// maxCapturedBodyLen caps how much of a response is kept for logging;
+	// larger bodies can't be redacted, so they aren't logged
+	maxCapturedBodyLen = 64 << 10
+)
+
+// bodyRecorder passes a response through while keeping up to
+// maxCapturedBodyLen bytes of it for logging
+type bodyRecorder struct {
+	http.ResponseWriter
+	body  bytes.Buffer
+	total int
+}
+
+func (br *bodyRecorder) Write(p []byte) (int, error) {
+	br.total += len(p)
+	if room := maxCapturedBodyLen - br.body.Len(); room > 0 {
+		br.body.Write(p[:min(len(p), room)])
+	}
+	return br.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (br *bodyRecorder) Unwrap() http.ResponseWriter {
+	return br.ResponseWriter
+}
+
+// bodyLogMiddleware logs request and response bodies at debug level, with
+// the configured sensitive JSON fields redacted. The request body is read in
+// full, so it runs after maxBodyBytesMiddleware, and handed on to the handler
+// unchanged. Streaming routes are skipped.
+func (s *Server) bodyLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := LoggerFromContext(r.Context())
+		if !logger.Core().Enabled(zap.DebugLevel) || streamingRoutes[s.routePattern(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Body != nil && r.Body != http.NoBody {
+			data, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			// Replay what was read, then any read error such as the body
+			// limit, so the handler reports it as it would have
+			var rest io.Reader = bytes.NewReader(nil)
+			if err != nil {
+				rest = errReader{err}
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), rest))
+
+			logger.Debug("HTTP request body",
+				zap.String("content_type", r.Header.Get("Content-Type")),
+				zap.Int("bytes", len(data)),
+				zap.String("body", s.loggableBody(r.Header.Get("Content-Type"), data, len(data))),
+			)
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		contentType := w.Header().Get("Content-Type")
+		logger.Debug("HTTP response body",
+			zap.String("content_type", contentType),
+			zap.Int("bytes", recorder.total),
+			zap.String("body", s.loggableBody(contentType, recorder.body.Bytes(), recorder.total)),
+		)
+	})
+}
+
+// errReader fails every read with err
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+// loggableBody returns data, a body of total bytes, as text safe to log.
+// Only JSON is logged, with the sensitive fields redacted and the result
+// truncated. Clients don't always label JSON, so the content type isn't
+// trusted; anything that doesn't parse, or is too large to redact, is
+// summarized instead since it could carry secrets.
+func (s *Server) loggableBody(contentType string, data []byte, total int) string {
+	if total == 0 {
+		return ""
+	}
+	if len(data) < total {
+		return fmt.Sprintf("[%d bytes omitted: too large to redact]", total)
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Sprintf("[%d bytes of %q omitted: not JSON]", total, contentType)
+	}
+	redacted, err := json.Marshal(s.redactJSON(value))
+	if err != nil {
+		return fmt.Sprintf("[%d bytes omitted]", total)
+	}
+
+	text := string(redacted)
+	if len(text) > maxLoggedBodyLen {
+		text = text[:maxLoggedBodyLen] + "...(truncated)"
+	}
+	return text
+}
+
+// redactJSON replaces the values of sensitive object keys, at any depth,
+// with REDACTED
+func (s *Server) redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if s.redactFields[strings.ToLower(key)] {
+				v[key] = "REDACTED"
+			} else {
+				v[key] = s.redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = s.redactJSON(item)
+		}
+	}
+	return value
+}
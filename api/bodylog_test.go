@@ -0,0 +1,72 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBodyLogRedactsSensitiveFields(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	s, _ := newLoggedTestServer(t, Config{LogBodies: true, RedactFields: []string{"password", "Token"}}, zap.New(core))
+
+	var received string
+	s.router.Post("/test/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"issued-secret","ok":true}`))
+	})
+
+	body := `{"user_name":"john","password":"hunter2","nested":{"PASSWORD":"hunter3"}}`
+	serve(s, jsonRequest(http.MethodPost, "/test/echo", body))
+
+	if received != body {
+		t.Errorf("handler received %q, want the full body %q", received, body)
+	}
+
+	logged := func(message string) string {
+		t.Helper()
+		entries := logs.FilterMessage(message).All()
+		if len(entries) != 1 {
+			t.Fatalf("got %d %q entries, want 1", len(entries), message)
+		}
+		return entries[0].ContextMap()["body"].(string)
+	}
+	request := logged("HTTP request body")
+	if strings.Contains(request, "hunter") || !strings.Contains(request, `"user_name":"john"`) {
+		t.Errorf("logged request body = %s, want the passwords redacted and the rest kept", request)
+	}
+	if response := logged("HTTP response body"); strings.Contains(response, "issued-secret") || !strings.Contains(response, `"ok":true`) {
+		t.Errorf("logged response body = %s, want the token redacted", response)
+	}
+}
+
+func TestBodyLogSkipsNonJSON(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	s, _ := newLoggedTestServer(t, Config{LogBodies: true}, zap.New(core))
+
+	serve(s, jsonRequest(http.MethodPost, "/v1/create_user", "password=hunter2"))
+
+	entries := logs.FilterMessage("HTTP request body").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d request body entries, want 1", len(entries))
+	}
+	if body := entries[0].ContextMap()["body"].(string); strings.Contains(body, "hunter2") {
+		t.Errorf("logged body = %s, want non-JSON left out", body)
+	}
+}
+
+func TestBodyLogDisabled(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	s, _ := newLoggedTestServer(t, Config{}, zap.New(core))
+
+	serve(s, jsonRequest(http.MethodPost, "/v1/create_user", `{"user_name":"john","email":"john@example.com"}`))
+	if n := logs.FilterMessage("HTTP request body").Len(); n != 0 {
+		t.Errorf("got %d request body entries, want none when LogBodies is off", n)
+	}
+}
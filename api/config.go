@@ -0,0 +1,85 @@
+package api
+
+import (
+	"io"
+	"net/netip"
+	"time"
+)
+
+// Config holds the settings the API server needs, populated by the caller
+// from the environment
+type Config struct {
+	// Env is the deployment environment, e.g. development or production
+	Env string
+	// AllowDBReset enables POST /admin/reset; it still only runs when Env is
+	// development or test
+	AllowDBReset bool
+	// AdminToken guards the /admin routes; they are disabled when it's empty
+	AdminToken string
+	// JWTSecret signs and verifies HS256 bearer tokens
+	JWTSecret []byte
+	// JWTTTL is how long tokens issued by /v1/login stay valid
+	JWTTTL time.Duration
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// RedactParams lists query parameters whose values are masked in request logs
+	RedactParams []string
+	// LogBodies logs request and response bodies at debug level
+	LogBodies bool
+	// RedactFields lists JSON fields whose values are masked in logged bodies
+	RedactFields []string
+	// AccessLogFormat is json (the default structured request log), common or
+	// combined for Apache/Nginx-style access lines instead
+	AccessLogFormat string
+	// AccessLogOutput receives common/combined access lines; nil means stdout
+	AccessLogOutput io.Writer
+	// MinFreeDiskBytes is the free space below which /readiness reports unhealthy
+	MinFreeDiskBytes uint64
+	// DrainDelay is how long the server keeps serving after a shutdown signal,
+	// with /readiness reporting 503, so load balancers stop routing to it first
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds draining in-flight requests and running the
+	// shutdown phases once the drain delay has passed
+	ShutdownTimeout time.Duration
+	// ReadTimeout bounds reading a whole request, body included, and
+	// ReadHeaderTimeout just its headers; zero uses the defaults
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout bounds writing a response. Streaming routes (price
+	// events, order WebSockets, user export) clear it for their own
+	// connection. Zero uses the default.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection may wait for its next
+	// request; zero uses the default
+	IdleTimeout time.Duration
+	// RequestTimeout applies to every route without an entry in RouteTimeouts
+	RequestTimeout time.Duration
+	// RouteTimeouts maps chi route patterns to their own timeout; 0 disables it
+	RouteTimeouts map[string]time.Duration
+	// CORSAllowedOrigins lists origins allowed to make cross-origin requests;
+	// "*" allows any origin and an empty list disables CORS
+	CORSAllowedOrigins []string
+	// RateLimitRPS is the sustained requests per second allowed per client IP;
+	// 0 disables rate limiting
+	RateLimitRPS float64
+	// RateLimitBurst is how many requests a client may make in a burst
+	RateLimitBurst int
+	// MetricsEnabled registers Prometheus HTTP metrics and serves GET /metrics
+	MetricsEnabled bool
+	// PprofEnabled serves the net/http/pprof profiles under /debug/pprof,
+	// guarded by the admin token. CPU profiles and traces run for as long as
+	// their ?seconds= asks, past the request and write timeouts.
+	PprofEnabled bool
+	// MaxBodyBytes caps request body size; 0 disables the limit
+	MaxBodyBytes int64
+	// StrictQueryParams makes list endpoints reject unknown query parameters
+	StrictQueryParams bool
+	// TrustedProxies are the peers whose X-Forwarded-For and X-Real-IP
+	// headers are believed; with none, the headers are ignored
+	TrustedProxies []netip.Prefix
+	// BasePath, e.g. /api, mounts every route under that prefix for serving
+	// behind a reverse proxy; it must start with a slash and not end with one.
+	// Route patterns in RouteTimeouts, metrics and logs leave it out.
+	BasePath string
+}
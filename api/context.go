@@ -0,0 +1,27 @@
+package api
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// contextKey is an unexported type for context keys defined in this package
+type contextKey string
+
+const loggerContextKey = contextKey("logger")
+
+// contextWithLogger returns a copy of ctx carrying logger
+func contextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// LoggerFromContext returns the request-scoped logger stored by the logging
+// middleware, which has the request id, method and path pre-bound. It returns
+// a no-op logger if none is present.
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*zap.Logger); ok {
+		return logger
+	}
+	return zap.NewNop()
+}
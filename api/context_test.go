@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContextLoggerCarriesRequestFields(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, _ := newLoggedTestServer(t, Config{}, zap.New(core))
+
+	req := httptest.NewRequest(http.MethodGet, "/no/such/route", nil)
+	req.Header.Set("X-Request-Id", "req-123")
+	serve(s, req)
+
+	// notFoundHandler logs through LoggerFromContext
+	entries := logs.FilterMessage("Route not found").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d handler log entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	want := map[string]string{"request_id": "req-123", "method": http.MethodGet, "path": "/no/such/route"}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("handler log %s = %v, want %q", key, fields[key], value)
+		}
+	}
+}
+
+func TestLoggerFromContextWithoutLogger(t *testing.T) {
+	if LoggerFromContext(context.Background()) == nil {
+		t.Error("LoggerFromContext returned nil for a context without a logger")
+	}
+}
+
+func TestHandlerLogsCarryRequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *http.Request
+		message string
+	}{
+		{"health", httptest.NewRequest(http.MethodGet, "/health", nil), "Health check requested"},
+		{"create user", jsonRequest(http.MethodPost, "/v1/create_user",
+			`{"user_name":"john","email":"john@example.com","password":"secret-password"}`), "Create user route"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.DebugLevel)
+			s, _ := newLoggedTestServer(t, Config{}, zap.New(core))
+
+			tt.req.Header.Set("X-Request-Id", "req-"+tt.name)
+			serve(s, tt.req)
+
+			entries := logs.FilterMessage(tt.message).All()
+			if len(entries) != 1 {
+				t.Fatalf("got %d %q entries, want 1", len(entries), tt.message)
+			}
+			if got := entries[0].ContextMap()["request_id"]; got != "req-"+tt.name {
+				t.Errorf("request_id = %v, want %q", got, "req-"+tt.name)
+			}
+		})
+	}
+}
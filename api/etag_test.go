@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	etag := computeETag("1", "2024-01-02T03:04:05Z")
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{etag, true},
+		{"W/" + etag, true},
+		{`"other", ` + etag, true},
+		{"*", true},
+		{`"other"`, false},
+		{computeETag("1", "2024-01-02T03:04:06Z"), false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
+
+func TestGetUserConditional(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "john")
+	target := fmt.Sprintf("/v1/users/%d", user.UserID)
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, target, nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET = %d with ETag %q, want 200 with an ETag", rec.Code, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = serve(s, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("conditional GET = %d with %d bytes, want 304 with no body", rec.Code, rec.Body.Len())
+	}
+
+	user.Email = "john@example.org"
+	if err := store.User.Update(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = serve(s, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("GET after update = %d with ETag %q, want 200 with a new ETag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,345 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// HealthResponse represents the health check response structure
+type HttpResponse struct {
+	HttpStatusCode int       `json:"http_status_code"`
+	Status         string    `json:"status"`
+	Timestamp      time.Time `json:"timestamp"`
+	Version        string    `json:"version"`
+	Uptime         string    `json:"uptime"`
+	// UptimeSeconds is Uptime in whole seconds, for monitoring tools
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	// Checks maps each dependency to "ok" or the reason it failed
+	Checks map[string]string `json:"checks"`
+}
+
+// healthCheckHandler reports the version, uptime and dependency status. It
+// responds 503 with status "unhealthy" when any check fails. A server without
+// a database manager has no database to check.
+func (s *Server) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(s.startTime)
+
+	response := HttpResponse{
+		HttpStatusCode: http.StatusOK,
+		Status:         "healthy",
+		Timestamp:      time.Now(),
+		Version:        s.version,
+		Uptime:         uptime.Round(time.Second).String(),
+		UptimeSeconds:  int64(uptime.Seconds()),
+		Checks:         map[string]string{},
+	}
+
+	if s.dbManager != nil {
+		// Bound the ping so a wedged database can't stall the check
+		ctx, cancel := context.WithTimeout(r.Context(), readinessPingTimeout)
+		defer cancel()
+		if err := s.dbManager.Ping(ctx); err != nil {
+			response.Checks["database"] = err.Error()
+			response.HttpStatusCode = http.StatusServiceUnavailable
+			response.Status = "unhealthy"
+		} else {
+			response.Checks["database"] = "ok"
+		}
+	}
+
+	if err := writeJSON(w, response.HttpStatusCode, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode health check response", zap.Error(err))
+		return
+	}
+
+	if response.HttpStatusCode != http.StatusOK {
+		LoggerFromContext(r.Context()).Warn("Health check failed", zap.Any("checks", response.Checks))
+		return
+	}
+
+	LoggerFromContext(r.Context()).Debug("Health check requested",
+		zap.Int("status_code", response.HttpStatusCode),
+		zap.String("status", response.Status),
+		zap.String("version", response.Version),
+		zap.String("uptime", response.Uptime),
+	)
+}
+
+// ResetResponse lists the tables cleared by an admin reset
+type ResetResponse struct {
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+	Tables    []string  `json:"tables"`
+	Seeded    bool      `json:"seeded"`
+}
+
+// resetEnvs are the environments in which an enabled reset may run
+var resetEnvs = []string{"development", "test"}
+
+// resetAllowed reports whether the config opts in to database resets
+func (c Config) resetAllowed() bool {
+	if !c.AllowDBReset {
+		return false
+	}
+	for _, env := range resetEnvs {
+		if strings.EqualFold(strings.TrimSpace(c.Env), env) {
+			return true
+		}
+	}
+	return false
+}
+
+// resetHandler clears all domain tables and optionally re-seeds sample data.
+// It only runs when ALLOW_DB_RESET is set in a development or test environment.
+func (s *Server) resetHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.config.resetAllowed() {
+		LoggerFromContext(r.Context()).Warn("Refused database reset",
+			zap.String("env", s.config.Env), zap.Bool("allow_db_reset", s.config.AllowDBReset))
+		writeError(w, r, http.StatusForbidden, "Database reset is disabled")
+		return
+	}
+
+	tables, err := s.dbManager.ResetData(r.Context())
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Database reset failed", zap.Error(err))
+		writeError(w, r, http.StatusInternalServerError, "Database reset failed")
+		return
+	}
+
+	seed := r.URL.Query().Get("seed") == "true"
+	if seed {
+		if err := s.dbManager.AddSampleData(); err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to re-seed database", zap.Error(err))
+			writeError(w, r, http.StatusInternalServerError, "Failed to re-seed database")
+			return
+		}
+	}
+
+	response := ResetResponse{
+		Status:    "database reset",
+		Timestamp: time.Now(),
+		Tables:    tables,
+		Seeded:    seed,
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode reset response", zap.Error(err))
+	}
+}
+
+// schemaHandler returns the database schema as plain SQL
+func (s *Server) schemaHandler(w http.ResponseWriter, r *http.Request) {
+	schema, err := s.dbManager.DumpSchema()
+	if errors.Is(err, db.ErrUnsupportedDriver) {
+		writeError(w, r, http.StatusNotImplemented, "Schema dumps are only available on SQLite")
+		return
+	}
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to dump schema", zap.Error(err))
+		writeError(w, r, http.StatusInternalServerError, "Failed to dump schema")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(schema))
+}
+
+// ReadinessResponse reports whether the service can take traffic, with the
+// outcome of each individual check
+type ReadinessResponse struct {
+	HttpStatusCode int               `json:"http_status_code"`
+	Status         string            `json:"status"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Checks         map[string]string `json:"checks"`
+}
+
+// readinessPingTimeout bounds the database ping in readinessHandler
+const readinessPingTimeout = 2 * time.Second
+
+// readinessHandler reports 503 when any dependency check fails or the
+// server is draining for shutdown. Unlike /health, which pings the database
+// on every call, it reuses the health monitor's latest ping when there is
+// one, and it also checks free disk space. Without a database manager only
+// draining is checked.
+func (s *Server) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	response := ReadinessResponse{
+		HttpStatusCode: http.StatusOK,
+		Status:         "ready",
+		Timestamp:      time.Now(),
+		Checks:         map[string]string{},
+	}
+
+	if s.draining.Load() {
+		response.Checks["shutdown"] = "draining"
+		response.HttpStatusCode = http.StatusServiceUnavailable
+		response.Status = "not ready"
+	}
+	if s.dbManager != nil {
+		s.checkDatabaseReadiness(r.Context(), &response)
+	}
+
+	if err := writeJSON(w, response.HttpStatusCode, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode readiness response", zap.Error(err))
+		return
+	}
+
+	if response.HttpStatusCode != http.StatusOK {
+		LoggerFromContext(r.Context()).Warn("Readiness check failed", zap.Any("checks", response.Checks))
+	}
+}
+
+// checkDatabaseReadiness adds the database and disk space checks to response
+func (s *Server) checkDatabaseReadiness(ctx context.Context, response *ReadinessResponse) {
+	// Use the health monitor's latest ping when it is running, so frequent
+	// probes don't each hit the database; otherwise ping now, bounded so a
+	// wedged database can't stall the probe
+	if ok, at := s.dbManager.LastPingStatus(); !at.IsZero() {
+		if ok {
+			response.Checks["database"] = "ok"
+		} else {
+			response.Checks["database"] = "ping failed at " + at.UTC().Format(time.RFC3339)
+			response.HttpStatusCode = http.StatusServiceUnavailable
+			response.Status = "not ready"
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, readinessPingTimeout)
+		defer cancel()
+		if err := s.dbManager.Ping(ctx); err != nil {
+			response.Checks["database"] = err.Error()
+			response.HttpStatusCode = http.StatusServiceUnavailable
+			response.Status = "not ready"
+		} else {
+			response.Checks["database"] = "ok"
+		}
+	}
+
+	if err := s.dbManager.CheckDiskSpace(s.config.MinFreeDiskBytes); err != nil {
+		response.Checks["disk_space"] = err.Error()
+		response.HttpStatusCode = http.StatusServiceUnavailable
+		response.Status = "not ready"
+	} else {
+		response.Checks["disk_space"] = "ok"
+	}
+}
+
+// notFoundHandler handles 404 errors
+func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	LoggerFromContext(r.Context()).Warn("Route not found")
+
+	writeError(w, r, http.StatusNotFound, "The requested resource was not found")
+}
+
+// MigrationInfo identifies a migration in MigrateResponse
+type MigrationInfo struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// MigrateResponse reports the migrations a migrate request applied and any
+// that are still pending afterwards
+type MigrateResponse struct {
+	Status    string          `json:"status"`
+	Timestamp time.Time       `json:"timestamp"`
+	Applied   []MigrationInfo `json:"applied"`
+	Pending   []MigrationInfo `json:"pending"`
+}
+
+// migrationInfos lists the versions and names of migrations
+func migrationInfos(migrations []db.Migration) []MigrationInfo {
+	infos := make([]MigrationInfo, len(migrations))
+	for i, m := range migrations {
+		infos[i] = MigrationInfo{Version: m.Version, Name: m.Name}
+	}
+	return infos
+}
+
+// migrateHandler runs the pending database migrations. It is safe to call
+// when the schema is up to date, in which case nothing is applied.
+func (s *Server) migrateHandler(w http.ResponseWriter, r *http.Request) {
+	applied, err := s.dbManager.ApplyMigrations(r.Context())
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Database migration failed",
+			zap.Int("applied", len(applied)),
+			zap.Error(err),
+		)
+		writeError(w, r, http.StatusInternalServerError, "Database migration failed")
+		return
+	}
+
+	pending, err := s.dbManager.PendingMigrations(r.Context())
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to list pending migrations", zap.Error(err))
+		writeError(w, r, http.StatusInternalServerError, "Failed to list pending migrations")
+		return
+	}
+
+	status := "up to date"
+	if len(applied) > 0 {
+		status = "migrated"
+	}
+	response := MigrateResponse{
+		Status:    status,
+		Timestamp: time.Now(),
+		Applied:   migrationInfos(applied),
+		Pending:   migrationInfos(pending),
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode migrate response", zap.Error(err))
+	}
+}
+
+// MaintenanceResponse reports how long each maintenance step took
+type MaintenanceResponse struct {
+	Status          string    `json:"status"`
+	Timestamp       time.Time `json:"timestamp"`
+	VacuumDuration  string    `json:"vacuum_duration"`
+	AnalyzeDuration string    `json:"analyze_duration"`
+}
+
+// maintenanceHandler runs VACUUM followed by ANALYZE on the database
+func (s *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if err := s.dbManager.Vacuum(); err != nil {
+		LoggerFromContext(r.Context()).Error("Database vacuum failed", zap.Error(err))
+		if errors.Is(err, db.ErrVacuumInTransaction) {
+			writeError(w, r, http.StatusConflict, "Database vacuum refused while a transaction is open")
+			return
+		}
+		writeError(w, r, http.StatusInternalServerError, "Database vacuum failed")
+		return
+	}
+	vacuumDuration := time.Since(start)
+
+	start = time.Now()
+	if err := s.dbManager.Analyze(); err != nil {
+		LoggerFromContext(r.Context()).Error("Database analyze failed", zap.Error(err))
+		writeError(w, r, http.StatusInternalServerError, "Database analyze failed")
+		return
+	}
+	analyzeDuration := time.Since(start)
+
+	response := MaintenanceResponse{
+		Status:          "maintenance completed",
+		Timestamp:       time.Now(),
+		VacuumDuration:  vacuumDuration.String(),
+		AnalyzeDuration: analyzeDuration.String(),
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode maintenance response", zap.Error(err))
+		return
+	}
+
+	LoggerFromContext(r.Context()).Info("Database maintenance completed",
+		zap.Duration("vacuum_duration", vacuumDuration),
+		zap.Duration("analyze_duration", analyzeDuration),
+	)
+}
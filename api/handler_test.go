@@ -0,0 +1,294 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+func TestMaintenanceRunsVacuumAndAnalyze(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, adminRequest(http.MethodPost, "/admin/maintenance", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	var resp MaintenanceResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.VacuumDuration == "" || resp.AnalyzeDuration == "" {
+		t.Errorf("response = %+v, want both durations", resp)
+	}
+}
+
+func TestMaintenanceConflictsWithOpenTransaction(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+
+	tx, err := db.BeginTx(context.Background(), store.User.DB, zap.NewNop())
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	defer tx.Rollback()
+
+	rec := serve(s, adminRequest(http.MethodPost, "/admin/maintenance", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if strings.Contains(rec.Body.String(), "db:") {
+		t.Errorf("body = %s, want a fixed message without driver error text", rec.Body)
+	}
+}
+
+func TestMaintenanceRequiresAdminToken(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, httptest.NewRequest(http.MethodPost, "/admin/maintenance", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestReadinessChecksDatabase(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	check := func(status int, database string) {
+		t.Helper()
+		rec := serve(s, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+		if rec.Code != status {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body)
+		}
+		var resp ReadinessResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !strings.Contains(resp.Checks["database"], database) {
+			t.Errorf("database check = %q, want %q", resp.Checks["database"], database)
+		}
+	}
+
+	check(http.StatusOK, "ok")
+	if err := s.dbManager.(*db.DatabaseManager).Close(); err != nil {
+		t.Fatal(err)
+	}
+	check(http.StatusServiceUnavailable, db.ErrDatabaseUnavailable.Error())
+}
+
+func TestReadinessReportsLowDiskSpace(t *testing.T) {
+	tests := []struct {
+		name    string
+		minFree uint64
+		status  int
+		diskOK  bool
+	}{
+		{"enough space", 1, http.StatusOK, true},
+		{"low space", math.MaxUint64, http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, Config{MinFreeDiskBytes: tt.minFree})
+
+			rec := serve(s, httptest.NewRequest(http.MethodGet, "/readiness", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			var resp ReadinessResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if disk := resp.Checks["disk_space"]; (disk == "ok") != tt.diskOK {
+				t.Errorf("disk_space check = %q, want ok: %v", disk, tt.diskOK)
+			}
+		})
+	}
+}
+
+func TestResetClearsData(t *testing.T) {
+	s, store := newTestServer(t, Config{Env: "development", AllowDBReset: true})
+	user := insertTestUser(t, store, "alice")
+
+	rec := serve(s, adminRequest(http.MethodPost, "/admin/reset", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if _, err := store.User.GetByID(context.Background(), user.UserID); !errors.Is(err, db.ErrNoRecord) {
+		t.Errorf("GetByID after reset = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestResetRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"production", Config{Env: "production", AllowDBReset: true}},
+		{"production mixed case", Config{Env: "Production", AllowDBReset: true}},
+		{"unset env", Config{AllowDBReset: true}},
+		{"unknown env", Config{Env: "staging", AllowDBReset: true}},
+		{"abbreviated env", Config{Env: "prod", AllowDBReset: true}},
+		{"not enabled", Config{Env: "development"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestServer(t, tt.cfg)
+			user := insertTestUser(t, store, "alice")
+
+			rec := serve(s, adminRequest(http.MethodPost, "/admin/reset", nil))
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+			if _, err := store.User.GetByID(context.Background(), user.UserID); err != nil {
+				t.Errorf("GetByID after refused reset = %v, want the user kept", err)
+			}
+		})
+	}
+}
+
+func TestResetAllowedEnvIgnoresCase(t *testing.T) {
+	s, _ := newTestServer(t, Config{Env: "Test", AllowDBReset: true})
+
+	if rec := serve(s, adminRequest(http.MethodPost, "/admin/reset", nil)); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestSchemaEndpoint(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	if rec := serve(s, httptest.NewRequest(http.MethodGet, "/admin/schema", nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without admin token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec := serve(s, adminRequest(http.MethodGet, "/admin/schema", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "CREATE TABLE users") {
+		t.Errorf("schema response doesn't contain the users table: %.200s", rec.Body)
+	}
+}
+
+func TestMigrateUpToDate(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, adminRequest(http.MethodPost, "/admin/migrate", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp MigrateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "up to date" || len(resp.Applied) != 0 || len(resp.Pending) != 0 {
+		t.Errorf("response = %+v, want up to date with nothing applied or pending", resp)
+	}
+}
+
+func TestMigrateAppliesPending(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	// Undo the audit log migration so it is pending again
+	dm := s.dbManager.(*db.DatabaseManager)
+	for _, stmt := range []string{"DROP TABLE audit_log", "DELETE FROM migrations WHERE version = 12"} {
+		if _, err := dm.ExecuteStatement(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	rec := serve(s, adminRequest(http.MethodPost, "/admin/migrate", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp MigrateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := MigrationInfo{Version: 12, Name: "create_audit_log_table"}
+	if resp.Status != "migrated" || len(resp.Applied) != 1 || resp.Applied[0] != want || len(resp.Pending) != 0 {
+		t.Errorf("response = %+v, want %+v applied and nothing pending", resp, want)
+	}
+	if _, err := dm.ExecuteStatement("SELECT COUNT(*) FROM audit_log"); err != nil {
+		t.Errorf("audit_log not recreated: %v", err)
+	}
+}
+
+func TestMigrateRequiresAdminToken(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, httptest.NewRequest(http.MethodPost, "/admin/migrate", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHealthReportsDependencies(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	check := func(status int, health, database string) HttpResponse {
+		t.Helper()
+		rec := serve(s, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code != status {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, status, rec.Body)
+		}
+		var resp HttpResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.HttpStatusCode != status || resp.Status != health {
+			t.Errorf("response status = %d %q, want %d %q", resp.HttpStatusCode, resp.Status, status, health)
+		}
+		if !strings.Contains(resp.Checks["database"], database) {
+			t.Errorf("database check = %q, want %q", resp.Checks["database"], database)
+		}
+		return resp
+	}
+
+	resp := check(http.StatusOK, "healthy", "ok")
+	if resp.Version != s.version || resp.Uptime == "" || resp.Timestamp.IsZero() {
+		t.Errorf("response = %+v, want the version, uptime and timestamp", resp)
+	}
+
+	if err := s.dbManager.(*db.DatabaseManager).Close(); err != nil {
+		t.Fatal(err)
+	}
+	check(http.StatusServiceUnavailable, "unhealthy", db.ErrDatabaseUnavailable.Error())
+}
+
+func TestHeadProbes(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	// A real server, since the recorder keeps bodies written for HEAD
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	for _, path := range []string{"/health", "/readiness"} {
+		resp, err := http.Head(ts.URL + path)
+		if err != nil {
+			t.Fatalf("HEAD %s: %v", path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("HEAD %s = %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("HEAD %s Content-Type = %q, want application/json", path, ct)
+		}
+		if len(body) != 0 {
+			t.Errorf("HEAD %s body = %q, want empty", path, body)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrisp986/trader-backend/apperror"
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// ErrorResponse is the JSON body of every error response. Fields holds
+// per-field problems for validation and constraint errors, and RequestID lets
+// clients quote the request in support tickets.
+type ErrorResponse struct {
+	Error     string            `json:"error"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// writeJSON encodes payload as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(payload)
+}
+
+// writeError writes an ErrorResponse with the given status and message
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) error {
+	return writeJSON(w, status, ErrorResponse{
+		Error:     http.StatusText(status),
+		Message:   msg,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// writeServerError answers a failure the handler has already logged: a 503
+// if the database is unavailable, as in writeAppError, and otherwise a 500
+// with msg
+func writeServerError(w http.ResponseWriter, r *http.Request, err error, msg string) error {
+	if errors.Is(err, db.ErrDatabaseUnavailable) {
+		return writeError(w, r, http.StatusServiceUnavailable, "Service is temporarily unavailable")
+	}
+	return writeError(w, r, http.StatusInternalServerError, msg)
+}
+
+// writeFieldErrors writes a 422 ErrorResponse listing invalid fields
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, fields map[string]string) error {
+	return writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+		Error:     http.StatusText(http.StatusUnprocessableEntity),
+		Message:   "One or more fields are invalid",
+		Fields:    fields,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
+// writeAppError writes err as an ErrorResponse. An *apperror.Error supplies
+// the status, message and fields, and a database constraint violation is
+// reported as by writeConstraintError. Anything else is an unexpected
+// failure: it is logged and answered with a generic 500. Errors caused by a
+// closed database become 503s. 5xx causes are logged too, since clients only
+// see the message.
+func (s *Server) writeAppError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		if cerr, ok := db.AsConstraintError(err); ok {
+			s.writeConstraintError(w, r, cerr)
+			return
+		}
+		appErr = apperror.Internal("Internal server error", err)
+	}
+	// A closed database, e.g. a request racing shutdown, is temporary, so
+	// report it as such instead of as a server fault
+	if appErr.Status == http.StatusInternalServerError && errors.Is(err, db.ErrDatabaseUnavailable) {
+		appErr = apperror.Unavailable("Service is temporarily unavailable", err)
+	}
+
+	if appErr.Status >= http.StatusInternalServerError {
+		LoggerFromContext(r.Context()).Error(appErr.Message, zap.Error(appErr.Err))
+	}
+
+	response := ErrorResponse{
+		Error:     http.StatusText(appErr.Status),
+		Message:   appErr.Message,
+		Fields:    appErr.Fields,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	if err := writeJSON(w, appErr.Status, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode error response", zap.Error(err))
+	}
+}
+
+// decodeJSON decodes a single JSON value from the request body into dst,
+// rejecting unknown fields and trailing data. On failure it writes a 400 with
+// a readable message (413 if the body exceeded the size limit) and returns
+// the error, so handlers only need to return.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		if dec.Decode(&struct{}{}) != io.EOF {
+			err = errors.New("request body must contain a single JSON value")
+		}
+	} else {
+		err = friendlyDecodeError(err)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, r, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+		return err
+	}
+
+	writeError(w, r, http.StatusBadRequest, err.Error())
+	return err
+}
+
+// jsonKind names the JSON kind a Go type decodes from
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return t.String()
+	}
+}
+
+// friendlyDecodeError rewrites encoding/json errors into messages suitable
+// for clients, keeping *http.MaxBytesError reachable through errors.As
+func friendlyDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return err
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("request body contains malformed JSON at position %d", syntaxErr.Offset)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("request body contains malformed JSON")
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Errorf("field %q must be a %s", typeErr.Field, typeErr.Type)
+		}
+		return fmt.Errorf("request body must be a JSON %s", jsonKind(typeErr.Type))
+	case errors.Is(err, io.EOF):
+		return errors.New("request body must not be empty")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("request body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return fmt.Errorf("request body is invalid: %w", err)
+	}
+}
+
+// queryInt reads an integer query parameter, returning def when it's absent
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return n, nil
+}
+
+// queryBool reads a boolean query parameter such as true, false, 1 or 0,
+// returning def when it's absent
+func queryBool(r *http.Request, name string, def bool) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+// queryTime reads an optional RFC 3339 query parameter, returning the zero
+// time when it's absent
+func queryTime(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp, e.g. 2006-01-02T15:04:05Z", name)
+	}
+	return t, nil
+}
+
+// parseListParams reads the limit, offset and sort query parameters shared by
+// list endpoints. limit must be 1-100 and defaults to 20, offset must not be
+// negative, and sort must be one of allowedSorts, optionally prefixed with
+// "-" for descending order; it is empty when not given. The returned error
+// message is suitable for a 400 response.
+func parseListParams(r *http.Request, allowedSorts ...string) (limit, offset int, sort string, err error) {
+	limit, err = queryInt(r, "limit", db.DefaultListLimit)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if limit < 1 || limit > db.MaxListLimit {
+		return 0, 0, "", fmt.Errorf("limit must be between 1 and %d", db.MaxListLimit)
+	}
+
+	offset, err = queryInt(r, "offset", 0)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if offset < 0 {
+		return 0, 0, "", errors.New("offset must be a non-negative integer")
+	}
+
+	sort = r.URL.Query().Get("sort")
+	if sort != "" && !slices.Contains(allowedSorts, strings.TrimPrefix(sort, "-")) {
+		return 0, 0, "", fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(allowedSorts, ", "))
+	}
+
+	return limit, offset, sort, nil
+}
+
+// sqlMetacharacters are rejected in identifier-like filter query parameters
+// such as username and symbol, but not in stored values. Filters are always bound as query parameters; this only turns
+// obvious injection attempts into a 400 before they reach the database.
+var sqlMetacharacters = []string{"'", "\"", ";", "--", "/*", "*/", "\\", "\x00"}
+
+// containsSQLMetachars reports whether v contains any of sqlMetacharacters
+func containsSQLMetachars(v string) bool {
+	for _, m := range sqlMetacharacters {
+		if strings.Contains(v, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryFilter reads an identifier-like filter parameter, trimmed, returning
+// an error if it contains SQL metacharacters
+func queryFilter(r *http.Request, name string) (string, error) {
+	v := strings.TrimSpace(r.URL.Query().Get(name))
+	if containsSQLMetachars(v) {
+		return "", fmt.Errorf("%s contains characters that are not allowed", name)
+	}
+	return v, nil
+}
+
+// writeConstraintError maps a database constraint violation to a field-level
+// error: 409 for duplicates, 422 for invalid values and broken references
+func (s *Server) writeConstraintError(w http.ResponseWriter, r *http.Request, cerr *db.ConstraintError) {
+	status := http.StatusUnprocessableEntity
+	if cerr.Kind == db.ConstraintDuplicate {
+		status = http.StatusConflict
+	}
+
+	response := ErrorResponse{
+		Error:     http.StatusText(status),
+		Message:   cerr.Error(),
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	if len(cerr.Fields) > 0 {
+		response.Fields = make(map[string]string, len(cerr.Fields))
+		for _, field := range cerr.Fields {
+			response.Fields[field] = string(cerr.Kind)
+		}
+	}
+
+	if err := writeJSON(w, status, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode constraint error response", zap.Error(err))
+	}
+}
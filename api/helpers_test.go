@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrisp986/trader-backend/apperror"
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+func TestWriteConstraintError(t *testing.T) {
+	s := &Server{logger: zap.NewNop()}
+
+	tests := []struct {
+		name   string
+		cerr   *db.ConstraintError
+		status int
+		fields map[string]string
+	}{
+		{
+			name:   "duplicate",
+			cerr:   &db.ConstraintError{Kind: db.ConstraintDuplicate, Table: "users", Fields: []string{"email"}},
+			status: http.StatusConflict,
+			fields: map[string]string{"email": "duplicate"},
+		},
+		{
+			name:   "invalid",
+			cerr:   &db.ConstraintError{Kind: db.ConstraintInvalid, Table: "orders", Fields: []string{"quantity"}},
+			status: http.StatusUnprocessableEntity,
+			fields: map[string]string{"quantity": "invalid"},
+		},
+		{
+			name:   "foreign key",
+			cerr:   &db.ConstraintError{Kind: db.ConstraintForeignKey},
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.writeConstraintError(rec, httptest.NewRequest(http.MethodPost, "/", nil), tt.cerr)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !reflect.DeepEqual(resp.Fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", resp.Fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type payload struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		message string
+	}{
+		{"valid", `{"name":"a","count":1}`, http.StatusOK, ""},
+		{"empty", ``, http.StatusBadRequest, "request body must not be empty"},
+		{"syntax", `{"name":}`, http.StatusBadRequest, "request body contains malformed JSON at position 9"},
+		{"truncated", `{"name":"a"`, http.StatusBadRequest, "request body contains malformed JSON"},
+		{"field type", `{"count":"one"}`, http.StatusBadRequest, `field "count" must be a int`},
+		{"body type", `[1,2]`, http.StatusBadRequest, "request body must be a JSON object"},
+		{"unknown field", `{"name":"a","extra":1}`, http.StatusBadRequest, `request body contains unknown field "extra"`},
+		{"trailing value", `{"name":"a"}{"name":"b"}`, http.StatusBadRequest, "request body must contain a single JSON value"},
+		{"trailing garbage", `{"name":"a"} x`, http.StatusBadRequest, "request body must contain a single JSON value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			var dst payload
+			err := decodeJSON(rec, req, &dst)
+			if (err != nil) != (tt.status != http.StatusOK) {
+				t.Fatalf("decodeJSON error = %v, want status %d", err, tt.status)
+			}
+			if err == nil {
+				if dst != (payload{Name: "a", Count: 1}) {
+					t.Errorf("decoded %+v", dst)
+				}
+				return
+			}
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Message != tt.message {
+				t.Errorf("message = %q, want %q", resp.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseListParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		limit   int
+		offset  int
+		sort    string
+		wantErr string
+	}{
+		{"defaults", "", db.DefaultListLimit, 0, "", ""},
+		{"explicit", "limit=5&offset=10&sort=-created_at", 5, 10, "-created_at", ""},
+		{"limit too low", "limit=0", 0, 0, "", "limit must be between"},
+		{"limit too high", "limit=101", 0, 0, "", "limit must be between"},
+		{"limit not a number", "limit=abc", 0, 0, "", "limit must be an integer"},
+		{"negative offset", "offset=-1", 0, 0, "", "offset must be a non-negative integer"},
+		{"invalid sort", "sort=password", 0, 0, "", "sort must be one of username, created_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			limit, offset, sort, err := parseListParams(req, "username", "created_at")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListParams: %v", err)
+			}
+			if limit != tt.limit || offset != tt.offset || sort != tt.sort {
+				t.Errorf("got (%d, %d, %q), want (%d, %d, %q)", limit, offset, sort, tt.limit, tt.offset, tt.sort)
+			}
+		})
+	}
+}
+
+func TestWriteAppError(t *testing.T) {
+	s := &Server{logger: zap.NewNop()}
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"bad request", apperror.BadRequest("id must be an integer"), http.StatusBadRequest, "id must be an integer"},
+		{"unauthorized", apperror.Unauthorized("A bearer token is required"), http.StatusUnauthorized, "A bearer token is required"},
+		{"forbidden", apperror.Forbidden("Not yours"), http.StatusForbidden, "Not yours"},
+		{"not found", fmt.Errorf("lookup: %w", apperror.NotFound("User not found")), http.StatusNotFound, "User not found"},
+		{"conflict", apperror.Conflict("Already exists"), http.StatusConflict, "Already exists"},
+		{"validation", apperror.Validation(map[string]string{"email": "is required"}), http.StatusUnprocessableEntity, "One or more fields are invalid"},
+		{"unknown", errors.New("secret internals"), http.StatusInternalServerError, "Internal server error"},
+		{"closed database", fmt.Errorf("query: %w", db.ErrDatabaseUnavailable), http.StatusServiceUnavailable, "Service is temporarily unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.writeAppError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Message != tt.message || resp.Error != http.StatusText(tt.status) {
+				t.Errorf("response = %+v, want %q", resp, tt.message)
+			}
+			if tt.status == http.StatusUnprocessableEntity && resp.Fields["email"] == "" {
+				t.Errorf("fields = %v, want the email problem", resp.Fields)
+			}
+		})
+	}
+}
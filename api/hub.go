@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+const (
+	// hubBroadcastBuffer bounds the order updates waiting for the hub loop;
+	// PublishOrder drops updates rather than block once it is full
+	hubBroadcastBuffer = 256
+	// subscriberBuffer bounds the messages queued for one client. A client
+	// that falls this far behind is disconnected instead of stalling the hub.
+	subscriberBuffer = 16
+)
+
+// OrderEvent is the message streamed to clients when an order changes
+type OrderEvent struct {
+	Type  string    `json:"type"`
+	Order *db.Order `json:"order"`
+	// CorrelationID is the id of the request that caused the change, also
+	// logged with it; it is omitted when the change didn't come from one
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// orderUpdate is an order queued for broadcast with its correlation id
+type orderUpdate struct {
+	order         *db.Order
+	correlationID string
+}
+
+// orderEventStatus is the OrderEvent type for status changes
+const orderEventStatus = "order.status"
+
+// subscriber receives the order updates for one user's connection. The hub
+// closes send when it drops the subscriber.
+type subscriber struct {
+	userID int
+	send   chan []byte
+}
+
+// Hub fans order updates out to the subscribed connections of the order's
+// owner. All subscriber bookkeeping happens on the Run goroutine, fed by the
+// register, unregister and broadcast channels.
+type Hub struct {
+	logger *zap.Logger
+
+	register   chan *subscriber
+	unregister chan *subscriber
+	broadcast  chan orderUpdate
+
+	// subscribers is owned by Run
+	subscribers map[int]map[*subscriber]struct{}
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewHub returns a hub; start it with Run before serving connections
+func NewHub(logger *zap.Logger) *Hub {
+	return &Hub{
+		logger:      logger,
+		register:    make(chan *subscriber),
+		unregister:  make(chan *subscriber),
+		broadcast:   make(chan orderUpdate, hubBroadcastBuffer),
+		subscribers: make(map[int]map[*subscriber]struct{}),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Run processes subscriptions and broadcasts until Close is called
+func (h *Hub) Run() {
+	defer close(h.stopped)
+
+	for {
+		select {
+		case sub := <-h.register:
+			if h.subscribers[sub.userID] == nil {
+				h.subscribers[sub.userID] = make(map[*subscriber]struct{})
+			}
+			h.subscribers[sub.userID][sub] = struct{}{}
+
+		case sub := <-h.unregister:
+			h.remove(sub)
+
+		case update := <-h.broadcast:
+			order := update.order
+			logger := h.logger.With(
+				zap.Int("order_id", order.OrderID),
+				zap.String("correlation_id", update.correlationID))
+
+			msg, err := json.Marshal(OrderEvent{Type: orderEventStatus, Order: order, CorrelationID: update.correlationID})
+			if err != nil {
+				logger.Error("Failed to encode order event", zap.Error(err))
+				continue
+			}
+			subs := h.subscribers[order.UserID]
+			logger.Info("Broadcasting order event",
+				zap.Int("user_id", order.UserID),
+				zap.String("status", order.Status),
+				zap.Int("subscribers", len(subs)))
+			for sub := range subs {
+				select {
+				case sub.send <- msg:
+				default:
+					logger.Warn("Dropping slow order stream subscriber", zap.Int("user_id", sub.userID))
+					h.remove(sub)
+				}
+			}
+
+		case <-h.done:
+			for _, subs := range h.subscribers {
+				for sub := range subs {
+					close(sub.send)
+				}
+			}
+			h.subscribers = nil
+			return
+		}
+	}
+}
+
+// remove drops sub and closes its channel; removing it twice is a no-op
+func (h *Hub) remove(sub *subscriber) {
+	subs, ok := h.subscribers[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.userID)
+	}
+	close(sub.send)
+}
+
+// subscribe registers a subscriber for userID's orders. It returns false
+// once the hub is closed.
+func (h *Hub) subscribe(userID int) (*subscriber, bool) {
+	sub := &subscriber{userID: userID, send: make(chan []byte, subscriberBuffer)}
+	select {
+	case h.register <- sub:
+		return sub, true
+	case <-h.done:
+		return nil, false
+	}
+}
+
+// unsubscribe removes sub; it is safe to call after the hub dropped it
+func (h *Hub) unsubscribe(sub *subscriber) {
+	select {
+	case h.unregister <- sub:
+	case <-h.done:
+	}
+}
+
+// PublishOrder queues an order update for its owner's subscribers, tagged
+// with ctx's correlation id. It never blocks the caller: the update is
+// dropped if the hub is backed up or closed.
+func (h *Hub) PublishOrder(ctx context.Context, order *db.Order) {
+	update := orderUpdate{order: order, correlationID: db.CorrelationID(ctx)}
+	select {
+	case h.broadcast <- update:
+	case <-h.done:
+	default:
+		h.logger.Warn("Order hub is full, dropping update",
+			zap.Int("order_id", order.OrderID),
+			zap.String("correlation_id", update.correlationID))
+	}
+}
+
+// Close stops Run, disconnecting every subscriber, and waits for it to exit
+// or ctx to end
+func (h *Hub) Close(ctx context.Context) error {
+	h.closeOnce.Do(func() { close(h.done) })
+
+	select {
+	case <-h.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
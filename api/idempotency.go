@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// maxIdempotencyKeyLength bounds Idempotency-Key header values
+const maxIdempotencyKeyLength = 255
+
+// idempotencyRecorder passes a response through while keeping a copy of its
+// status and body for storage
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// idempotencyRequestHash identifies a request by its method, path, caller
+// and body, so a key can't be replayed against a different request
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	if userID, ok := GetUserID(r.Context()); ok {
+		io.WriteString(h, "user "+strconv.Itoa(userID)+"\n")
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyMiddleware makes creation endpoints safe to retry. When a
+// request carries an Idempotency-Key header, the response to the first
+// request with that key is stored and returned for every identical retry
+// without running the handler again; Idempotent-Replayed is set on replays.
+// Reusing a key for a different request is rejected with 422. Server errors
+// are not stored, so the client can retry them with the same key. Requests
+// without the header are unaffected.
+func (s *Server) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || s.idempotency == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, r, http.StatusBadRequest, "Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+			return
+		}
+
+		logger := LoggerFromContext(r.Context()).With(zap.String("idempotency_key", key))
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, err := s.idempotency.Reserve(r.Context(), key, idempotencyRequestHash(r, body))
+		switch {
+		case errors.Is(err, db.ErrIdempotencyMismatch):
+			writeError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			return
+		case errors.Is(err, db.ErrIdempotencyInProgress):
+			writeError(w, r, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			return
+		case err != nil:
+			logger.Error("Failed to reserve idempotency key", zap.Error(err))
+			writeServerError(w, r, err, "Failed to process Idempotency-Key")
+			return
+		case stored != nil:
+			logger.Info("Replaying idempotent response", zap.Int("status_code", stored.StatusCode))
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			// Free the key if the handler panicked or failed on our side
+			if !completed {
+				if err := s.idempotency.Release(context.WithoutCancel(r.Context()), key); err != nil {
+					logger.Error("Failed to release idempotency key", zap.Error(err))
+				}
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+		if err := s.idempotency.Complete(context.WithoutCancel(r.Context()), key, rec.status, rec.body.Bytes()); err != nil {
+			logger.Error("Failed to store idempotent response", zap.Error(err))
+			return
+		}
+		completed = true
+	})
+}
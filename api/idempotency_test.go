@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// createUserWithKey returns a create user request carrying an Idempotency-Key
+func createUserWithKey(key, body string) *http.Request {
+	req := jsonRequest(http.MethodPost, "/v1/create_user", body)
+	req.Header.Set("Idempotency-Key", key)
+	return req
+}
+
+func TestIdempotencyKeyReplaysResponse(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	body := `{"user_name":"john","email":"john@example.com","password":"secret-password"}`
+
+	first := serve(s, createUserWithKey("key-1", body))
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first status = %d, want %d: %s", first.Code, http.StatusCreated, first.Body)
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first response is marked as replayed")
+	}
+
+	replay := serve(s, createUserWithKey("key-1", body))
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want the first response %d %s", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay is not marked as replayed")
+	}
+
+	count, err := store.User.Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("stored %d users, want 1", count)
+	}
+}
+
+func TestIdempotencyKeyRejectsDifferentRequest(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	serve(s, createUserWithKey("key-1", `{"user_name":"john","email":"john@example.com","password":"secret-password"}`))
+	rec := serve(s, createUserWithKey("key-1", `{"user_name":"jane","email":"jane@example.com","password":"secret-password"}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusUnprocessableEntity, rec.Body)
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// InstrumentsResponse lists the tradable instruments
+type InstrumentsResponse struct {
+	Instruments []*db.Instrument `json:"instruments"`
+}
+
+// listInstrumentsHandler returns all instruments, including inactive ones
+func (s *Server) listInstrumentsHandler(w http.ResponseWriter, r *http.Request) {
+	instruments, err := s.instrument.List(r.Context())
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to list instruments", zap.Error(err))
+		writeServerError(w, r, err, "Failed to list instruments")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, InstrumentsResponse{Instruments: instruments}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode instruments response", zap.Error(err))
+	}
+}
+
+// orderBookHandler returns the open limit orders for the {symbol} URL
+// parameter aggregated by price level, or 404 if it isn't a known instrument
+func (s *Server) orderBookHandler(w http.ResponseWriter, r *http.Request) {
+	symbol := db.NormalizeSymbol(chi.URLParam(r, "symbol"))
+
+	if _, err := s.instrument.GetBySymbol(r.Context(), symbol); err != nil {
+		if errors.Is(err, db.ErrNoRecord) {
+			writeError(w, r, http.StatusNotFound, "Instrument not found")
+			return
+		}
+		LoggerFromContext(r.Context()).Error("Failed to get instrument", zap.String("symbol", symbol), zap.Error(err))
+		writeServerError(w, r, err, "Failed to get order book")
+		return
+	}
+
+	book, err := s.order.AggregateBook(r.Context(), symbol)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to get order book", zap.String("symbol", symbol), zap.Error(err))
+		writeServerError(w, r, err, "Failed to get order book")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, book); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode order book response", zap.Error(err))
+	}
+}
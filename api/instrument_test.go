@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	db "github.com/chrisp986/trader-backend/database"
+)
+
+func TestOrderBookEndpoint(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "alice")
+
+	insertTestOrder(t, store, user.UserID, "AAPL", db.OrderSideBuy, 2, 99)
+	insertTestOrder(t, store, user.UserID, "AAPL", db.OrderSideBuy, 2, 99)
+	insertTestOrder(t, store, user.UserID, "AAPL", db.OrderSideSell, 2, 101)
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/symbols/aapl/orderbook", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var book db.OrderBook
+	if err := json.NewDecoder(rec.Body).Decode(&book); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := db.OrderBook{
+		Symbol: "AAPL",
+		Bids:   []db.BookLevel{{Price: 99, Quantity: 4, Orders: 2}},
+		Asks:   []db.BookLevel{{Price: 101, Quantity: 2, Orders: 1}},
+	}
+	if !reflect.DeepEqual(book, want) {
+		t.Errorf("order book = %+v, want %+v", book, want)
+	}
+}
+
+func TestOrderBookUnknownSymbol(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/symbols/NOPE/orderbook", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestListInstruments(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/instruments", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp InstrumentsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Instruments) == 0 || resp.Instruments[0].Symbol != "AAPL" {
+		t.Errorf("instruments = %+v, want the seeded instruments starting with AAPL", resp.Instruments)
+	}
+}
+
+func TestCreateOrderValidatesSymbol(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "alice")
+
+	tests := []struct {
+		symbol string
+		status int
+	}{
+		{"aapl", http.StatusCreated},
+		{"NOPE", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			body := strings.NewReader(`{"symbol":"` + tt.symbol + `","side":"buy","type":"limit","quantity":1,"price":100}`)
+			req := authorize(t, s, httptest.NewRequest(http.MethodPost, "/v1/orders", body), user.UserID)
+
+			rec := serve(s, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusUnprocessableEntity {
+				return
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Fields["symbol"] == "" {
+				t.Errorf("fields = %v, want a symbol error", resp.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics holds the Prometheus collectors for HTTP requests and database
+// queries
+type metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	queryDuration *prometheus.HistogramVec
+	queryErrors   *prometheus.CounterVec
+}
+
+// register registers c with reg, returning the collector already registered
+// under the same description if there is one
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return c, err
+		}
+		return are.ExistingCollector.(C), nil
+	}
+	return c, nil
+}
+
+// newMetrics registers the HTTP and database collectors with reg. Collectors
+// that are already registered, e.g. by an earlier server in the same process,
+// are reused so their series keep accumulating.
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	requests, err := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "path", "status"}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register request counter: %w", err)
+	}
+
+	duration, err := register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route pattern and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path", "status"}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register request histogram: %w", err)
+	}
+
+	queryDuration, err := register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency by operation.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register query histogram: %w", err)
+	}
+
+	queryErrors, err := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Number of failed database queries by operation.",
+	}, []string{"operation"}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register query error counter: %w", err)
+	}
+
+	return &metrics{
+		requests:      requests,
+		duration:      duration,
+		queryDuration: queryDuration,
+		queryErrors:   queryErrors,
+	}, nil
+}
+
+// ObserveQuery implements db.QueryObserver
+func (m *metrics) ObserveQuery(operation string, duration time.Duration, err error) {
+	m.queryDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		m.queryErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// metricsMiddleware counts requests and records their latency, labelled by
+// the chi route pattern rather than the raw path to keep cardinality low
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Resolve the pattern up front: the request's own route context is
+		// filled in by the handler goroutine that timeoutMiddleware may abandon
+		path := s.routePattern(r)
+		if path == "" {
+			path = "unmatched"
+		}
+
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+
+		status := strconv.Itoa(wrapped.statusCode)
+		s.metrics.requests.WithLabelValues(r.Method, path, status).Inc()
+		s.metrics.duration.WithLabelValues(r.Method, path, status).Observe(time.Since(start).Seconds())
+	})
+}
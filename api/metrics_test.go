@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatheredValue returns the value of the default registry's counter, or the
+// sample count of its histogram, named name with the given labels, or 0 if
+// there is no such series yet
+func gatheredValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	series:
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if want, ok := labels[pair.GetName()]; ok && want != pair.GetValue() {
+					continue series
+				}
+			}
+			if m.GetHistogram() != nil {
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMetricsCountRequestsByRoutePattern(t *testing.T) {
+	s, store := newTestServer(t, Config{MetricsEnabled: true})
+	user := insertTestUser(t, store, "alice")
+
+	labels := map[string]string{"method": http.MethodGet, "path": "/v1/users/{id}", "status": "200"}
+	before := gatheredValue(t, "http_requests_total", labels)
+
+	for range 2 {
+		serve(s, httptest.NewRequest(http.MethodGet, "/v1/users/"+strconv.Itoa(user.UserID), nil))
+	}
+
+	if got := gatheredValue(t, "http_requests_total", labels) - before; got != 2 {
+		t.Errorf("http_requests_total increased by %v, want 2", got)
+	}
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `path="/v1/users/{id}"`) {
+		t.Errorf("/metrics = %d without the route's series", rec.Code)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	if rec := serve(s, httptest.NewRequest(http.MethodGet, "/metrics", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("/metrics status = %d, want %d when metrics are disabled", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMetricsObserveQueries(t *testing.T) {
+	s, _ := newTestServer(t, Config{MetricsEnabled: true})
+
+	labels := map[string]string{"operation": "users.insert"}
+	observed := gatheredValue(t, "db_query_duration_seconds", labels)
+	failed := gatheredValue(t, "db_query_errors_total", labels)
+
+	body := `{"user_name":"john","email":"john@example.com","password":"secret-password"}`
+	if rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user", body)); rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if got := gatheredValue(t, "db_query_duration_seconds", labels) - observed; got != 1 {
+		t.Errorf("db_query_duration_seconds observed %v inserts, want 1", got)
+	}
+	if got := gatheredValue(t, "db_query_errors_total", labels) - failed; got != 0 {
+		t.Errorf("db_query_errors_total increased by %v, want 0", got)
+	}
+
+	if rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user", body)); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate create status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if got := gatheredValue(t, "db_query_errors_total", labels) - failed; got != 1 {
+		t.Errorf("db_query_errors_total increased by %v, want 1", got)
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// requireAdminToken rejects requests whose X-Admin-Token header doesn't match
+// the configured admin token. Admin routes are disabled when no token is set.
+func (s *Server) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Admin-Token")
+		if s.config.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			LoggerFromContext(r.Context()).Warn("Rejected admin request")
+
+			writeError(w, r, http.StatusUnauthorized, "A valid admin token is required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoveryMiddleware turns a handler panic into a 500 JSON response and logs
+// the panic value and stack, keeping internals out of the response body.
+// http.ErrAbortHandler is re-panicked so net/http aborts the connection.
+func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			s.logger.Error("Recovered from panic",
+				zap.String("request_id", middleware.GetReqID(r.Context())),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Any("panic", p),
+				zap.ByteString("stack", debug.Stack()),
+			)
+
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{
+				Error:     http.StatusText(http.StatusInternalServerError),
+				Message:   "An unexpected error occurred",
+				RequestID: middleware.GetReqID(r.Context()),
+			})
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowQueryParams returns a middleware that, in strict query mode, rejects
+// requests carrying query parameters outside allowed with a 400 naming them.
+// In the default lenient mode unknown parameters are ignored.
+func (s *Server) allowQueryParams(allowed ...string) func(http.Handler) http.Handler {
+	known := make(map[string]bool, len(allowed))
+	for _, param := range allowed {
+		known[param] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.config.StrictQueryParams {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var unknown []string
+			for param := range r.URL.Query() {
+				if !known[param] {
+					unknown = append(unknown, param)
+				}
+			}
+
+			if len(unknown) > 0 {
+				sort.Strings(unknown)
+				writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Unknown query parameters: %s", strings.Join(unknown, ", ")))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxBodyBytesMiddleware returns a middleware that caps request bodies at n
+// bytes; reads past the limit fail with *http.MaxBytesError
+func maxBodyBytesMiddleware(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowedHeaders = "Accept, Authorization, Content-Type, Idempotency-Key, If-None-Match, X-Admin-Token, X-Request-Id"
+	// corsExposedHeaders are the response headers scripts may read besides
+	// the CORS-safelisted ones
+	corsExposedHeaders = "ETag, Idempotent-Replayed"
+)
+
+// corsMiddleware adds CORS headers for requests from allowed origins and
+// answers preflight OPTIONS requests with 204 without reaching the router
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(s.config.CORSAllowedOrigins))
+	for _, origin := range s.config.CORSAllowedOrigins {
+		allowed[origin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && (allowed[origin] || allowed["*"]) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStrictQueryParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		strict bool
+		target string
+		status int
+	}{
+		{"strict rejects unknown", true, "/v1/users?limit=5&lmit=5&zz=1", http.StatusBadRequest},
+		{"strict allows known", true, "/v1/users?limit=5&offset=0", http.StatusOK},
+		{"lenient ignores unknown", false, "/v1/users?limit=5&lmit=5", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, Config{StrictQueryParams: tt.strict})
+
+			rec := serve(s, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "lmit, zz") {
+				t.Errorf("error doesn't list the unknown parameters: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	s, _ := newTestServer(t, Config{CORSAllowedOrigins: []string{"https://app.example.com"}})
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+	}{
+		{"allowed origin", http.MethodGet, "https://app.example.com", false, http.StatusOK, "https://app.example.com"},
+		{"disallowed origin", http.MethodGet, "https://evil.example.com", false, http.StatusOK, ""},
+		{"preflight", http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/health", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+
+			rec := serve(s, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			allowMethods := rec.Header().Get("Access-Control-Allow-Methods")
+			if (allowMethods != "") != (tt.allowOrigin != "") {
+				t.Errorf("Access-Control-Allow-Methods = %q", allowMethods)
+			}
+			if tt.allowOrigin != "" && rec.Header().Get("Access-Control-Allow-Headers") == "" {
+				t.Error("Access-Control-Allow-Headers not set")
+			}
+		})
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	const limit = 256
+	s, _ := newTestServer(t, Config{MaxBodyBytes: limit})
+
+	// body pads a create user request with whitespace to exactly size bytes
+	body := func(name string, size int) string {
+		b := `{"user_name":"` + name + `","email":"` + name + `@example.com"}`
+		return b[:len(b)-1] + strings.Repeat(" ", size-len(b)) + "}"
+	}
+
+	tests := []struct {
+		name   string
+		size   int
+		status int
+	}{
+		{"under", limit, http.StatusCreated},
+		{"over", limit + 1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user", body(tt.name, tt.size)))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	s := &Server{router: chi.NewRouter(), logger: zap.New(core)}
+	s.router.Use(middleware.RequestID, s.recoveryMiddleware)
+	s.router.Get("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	s.router.Get("/abort", func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want JSON", ct)
+	}
+	if strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("response leaks the stack: %s", rec.Body)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != "Internal Server Error" || resp.RequestID == "" {
+		t.Errorf("response = %+v, want Internal Server Error with a request id", resp)
+	}
+
+	entries := logs.FilterMessage("Recovered from panic").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d panic log entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "goroutine") {
+		t.Errorf("panic log stack = %q, want a stack trace", stack)
+	}
+	if fields["request_id"] != resp.RequestID {
+		t.Errorf("panic log request_id = %v, want %q", fields["request_id"], resp.RequestID)
+	}
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-panicked", p)
+		}
+	}()
+	s.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/chrisp986/trader-backend/engine"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CreateOrderRequest is the expected body for placing an order
+type CreateOrderRequest struct {
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Type     string  `json:"type"`
+	Quantity float64 `json:"quantity"`
+	// Price is required for limit orders and must be omitted for market orders
+	Price *float64 `json:"price"`
+}
+
+// validate returns a map of field name to problem, empty when input is valid.
+// Whether the symbol is a known instrument is checked separately.
+func (input CreateOrderRequest) validate() map[string]string {
+	fields := make(map[string]string)
+
+	if strings.TrimSpace(input.Symbol) == "" {
+		fields["symbol"] = "must not be empty"
+	}
+	if input.Side != db.OrderSideBuy && input.Side != db.OrderSideSell {
+		fields["side"] = fmt.Sprintf("must be %q or %q", db.OrderSideBuy, db.OrderSideSell)
+	}
+	if input.Quantity <= 0 {
+		fields["quantity"] = "must be positive"
+	}
+
+	switch input.Type {
+	case db.OrderTypeMarket:
+		if input.Price != nil {
+			fields["price"] = "must be omitted for market orders"
+		}
+	case db.OrderTypeLimit:
+		if input.Price == nil || *input.Price <= 0 {
+			fields["price"] = "must be positive for limit orders"
+		}
+	default:
+		fields["type"] = fmt.Sprintf("must be %q or %q", db.OrderTypeMarket, db.OrderTypeLimit)
+	}
+
+	return fields
+}
+
+// MatchingFailed marks a created order whose matching returned an error
+const MatchingFailed = "failed"
+
+// CreateOrderResponse is the stored order. Matching is MatchingFailed when
+// the order was stored but the matching engine failed on it; the order is
+// then left open, with any fills recorded before the failure.
+type CreateOrderResponse struct {
+	*db.Order
+	Matching string `json:"matching,omitempty"`
+}
+
+// createOrderHandler places an open order for the authenticated user,
+// answering 422 when the symbol isn't an active instrument. Once the order
+// is stored it answers 201 even if matching fails, so an Idempotency-Key
+// retry replays it instead of placing a second order.
+func (s *Server) createOrderHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserID(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, "A bearer token is required")
+		return
+	}
+
+	var input CreateOrderRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		return
+	}
+
+	if fields := input.validate(); len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	instrument, err := s.instrument.GetBySymbol(r.Context(), input.Symbol)
+	if err != nil {
+		if errors.Is(err, db.ErrNoRecord) {
+			writeFieldErrors(w, r, map[string]string{"symbol": "is not a known instrument"})
+			return
+		}
+		LoggerFromContext(r.Context()).Error("Failed to get instrument", zap.String("symbol", input.Symbol), zap.Error(err))
+		writeServerError(w, r, err, "Failed to create order")
+		return
+	}
+	if !instrument.Active {
+		writeFieldErrors(w, r, map[string]string{"symbol": "is not currently tradable"})
+		return
+	}
+
+	order := &db.Order{
+		UserID:   userID,
+		Symbol:   instrument.Symbol,
+		Side:     input.Side,
+		Type:     input.Type,
+		Quantity: input.Quantity,
+		Price:    input.Price,
+	}
+	if err := s.order.Insert(r.Context(), order); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to create order", zap.Int("user_id", userID), zap.Error(err))
+		writeServerError(w, r, err, "Failed to create order")
+		return
+	}
+
+	response := CreateOrderResponse{Order: order}
+	if s.matcher != nil {
+		if _, err := s.matcher.Submit(r.Context(), order); err != nil {
+			LoggerFromContext(r.Context()).Error("Failed to match order", zap.Int("order_id", order.OrderID), zap.Error(err))
+			response.Matching = MatchingFailed
+			// Report what was persisted rather than the engine's view of it
+			if stored, err := s.order.GetByID(r.Context(), order.OrderID); err == nil {
+				response.Order = stored
+			}
+		}
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s/orders/%d", s.config.BasePath, APIVersion, order.OrderID))
+	if err := writeJSON(w, http.StatusCreated, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode order response", zap.Error(err))
+	}
+}
+
+// getOrderHandler returns one of the authenticated user's orders
+func (s *Server) getOrderHandler(w http.ResponseWriter, r *http.Request) {
+	order := s.ownedOrder(w, r)
+	if order == nil {
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, order); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode order response", zap.Error(err))
+	}
+}
+
+// UpdateOrderStatusRequest is the expected body for changing an order's status
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// ownedOrder parses the {id} URL parameter and loads the order, checking it
+// belongs to the authenticated user. It writes the error response and
+// returns nil if not.
+func (s *Server) ownedOrder(w http.ResponseWriter, r *http.Request) *db.Order {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		writeError(w, r, http.StatusBadRequest, "Order id must be a positive integer")
+		return nil
+	}
+
+	order, err := s.order.GetByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNoRecord) {
+			writeError(w, r, http.StatusNotFound, "Order not found")
+			return nil
+		}
+		LoggerFromContext(r.Context()).Error("Failed to get order", zap.Int("order_id", id), zap.Error(err))
+		writeServerError(w, r, err, "Failed to get order")
+		return nil
+	}
+
+	if userID, ok := GetUserID(r.Context()); !ok || userID != order.UserID {
+		writeError(w, r, http.StatusForbidden, "Users may only access their own orders")
+		return nil
+	}
+	return order
+}
+
+// errFilledByMatcher rejects manual fills while the matching engine owns fills
+var errFilledByMatcher = errors.New("orders are filled by the matching engine")
+
+// changeOrderStatus applies a client-requested status change. With the
+// matching engine enabled, cancelling a resting order goes through the
+// engine so it leaves the book, and manual fills are refused.
+func (s *Server) changeOrderStatus(r *http.Request, orderID int, status string) error {
+	if s.matcher != nil {
+		switch status {
+		case db.OrderStatusFilled:
+			return errFilledByMatcher
+		case db.OrderStatusCancelled:
+			err := s.matcher.Cancel(r.Context(), orderID)
+			if !errors.Is(err, engine.ErrNotResting) {
+				return err
+			}
+		}
+	}
+	return s.order.UpdateStatus(r.Context(), orderID, status)
+}
+
+// updateOrderStatusHandler moves one of the authenticated user's orders to a
+// new status, answering 409 for transitions that aren't allowed
+func (s *Server) updateOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	order := s.ownedOrder(w, r)
+	if order == nil {
+		return
+	}
+
+	var input UpdateOrderStatusRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		return
+	}
+
+	if err := s.changeOrderStatus(r, order.OrderID, input.Status); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNoRecord):
+			writeError(w, r, http.StatusNotFound, "Order not found")
+		case errors.Is(err, errFilledByMatcher):
+			writeError(w, r, http.StatusConflict, "Orders are filled by the matching engine")
+		case errors.Is(err, db.ErrInvalidTransition):
+			writeError(w, r, http.StatusConflict, fmt.Sprintf("Order can't move from %s to %q", order.Status, input.Status))
+		default:
+			LoggerFromContext(r.Context()).Error("Failed to update order status", zap.Int("order_id", order.OrderID), zap.Error(err))
+			writeServerError(w, r, err, "Failed to update order status")
+		}
+		return
+	}
+
+	updated, err := s.order.GetByID(r.Context(), order.OrderID)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to reload order", zap.Int("order_id", order.OrderID), zap.Error(err))
+		writeServerError(w, r, err, "Failed to get order")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, updated); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode order response", zap.Error(err))
+	}
+}
+
+// CancelAllOrdersResponse reports the orders cancelled by a cancel-all
+type CancelAllOrdersResponse struct {
+	Symbol    string `json:"symbol"`
+	Cancelled int    `json:"cancelled"`
+	OrderIDs  []int  `json:"order_ids"`
+}
+
+// cancelAllOrdersHandler cancels every open order the authenticated user has
+// for ?symbol= and reports how many there were, 0 if none
+func (s *Server) cancelAllOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserID(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, "A bearer token is required")
+		return
+	}
+
+	symbol := db.NormalizeSymbol(r.URL.Query().Get("symbol"))
+	if symbol == "" {
+		writeError(w, r, http.StatusBadRequest, "symbol is required")
+		return
+	}
+
+	var ids []int
+	var err error
+	if s.matcher != nil {
+		// Resting orders must leave the engine's book as well
+		ids, err = s.matcher.CancelAll(r.Context(), userID, symbol)
+	} else {
+		ids, err = s.order.CancelOpen(r.Context(), userID, symbol)
+	}
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to cancel orders",
+			zap.Int("user_id", userID),
+			zap.String("symbol", symbol),
+			zap.Error(err))
+		writeServerError(w, r, err, "Failed to cancel orders")
+		return
+	}
+
+	for _, id := range ids {
+		s.recordAudit(r, userID, db.AuditActionCancel, "order", id, map[string]string{"symbol": symbol, "status": db.OrderStatusCancelled})
+	}
+
+	response := CancelAllOrdersResponse{Symbol: symbol, Cancelled: len(ids), OrderIDs: ids}
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode cancel orders response", zap.Error(err))
+	}
+}
+
+// PositionsResponse lists a user's open positions
+type PositionsResponse struct {
+	Positions []*db.Position `json:"positions"`
+}
+
+// listPositionsHandler returns the authenticated user's open positions
+func (s *Server) listPositionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := authorizedUserID(r)
+	if err != nil {
+		s.writeAppError(w, r, err)
+		return
+	}
+
+	positions, err := s.position.ListByUser(r.Context(), id)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to list positions", zap.Int("user_id", id), zap.Error(err))
+		writeServerError(w, r, err, "Failed to list positions")
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, PositionsResponse{Positions: positions}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode positions response", zap.Error(err))
+	}
+}
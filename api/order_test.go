@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/chrisp986/trader-backend/engine"
+	"go.uber.org/zap"
+)
+
+// insertTestOrder stores an open limit order for userID and returns it
+func insertTestOrder(t *testing.T, store *db.SQLStore, userID int, symbol, side string, quantity, price float64) *db.Order {
+	t.Helper()
+
+	order := &db.Order{UserID: userID, Symbol: symbol, Side: side, Type: db.OrderTypeLimit, Quantity: quantity, Price: &price}
+	if err := store.Order.Insert(context.Background(), order); err != nil {
+		t.Fatalf("Insert order: %v", err)
+	}
+	return order
+}
+
+func TestCreateOrderRetryAfterMatchFailure(t *testing.T) {
+	var matcher *engine.MatchingEngine
+	s, store := newServicesTestServer(t, Config{}, zap.NewNop(), func(store *db.SQLStore) Services {
+		matcher = engine.New(store.User.DB, store.Order, store.Trade, store.Position, zap.NewNop())
+		return Services{Matcher: matcher}
+	})
+	alice := insertTestUser(t, store, "alice")
+	bob := insertTestUser(t, store, "bob")
+	if _, err := matcher.Submit(context.Background(), insertTestOrder(t, store, bob.UserID, "AAPL", db.OrderSideSell, 1, 100)); err != nil {
+		t.Fatalf("Submit resting order: %v", err)
+	}
+	// Recording the fill fails, after the incoming order has been stored
+	if _, err := store.User.DB.Exec(`CREATE TRIGGER fail_trades BEFORE INSERT ON trades
+		BEGIN SELECT RAISE(ABORT, 'trades unavailable'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	place := func() *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/v1/orders", `{"symbol":"AAPL","side":"buy","type":"limit","quantity":1,"price":100}`)
+		req.Header.Set("Idempotency-Key", "order-1")
+		return serve(s, authorize(t, s, req, alice.UserID))
+	}
+
+	first := place()
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first status = %d, want %d: %s", first.Code, http.StatusCreated, first.Body)
+	}
+	var resp CreateOrderResponse
+	if err := json.NewDecoder(strings.NewReader(first.Body.String())).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Matching != MatchingFailed || resp.Status != db.OrderStatusOpen {
+		t.Errorf("response = %+v, want an open order with matching failed", resp)
+	}
+
+	retry := place()
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d, replayed %q, want the first response replayed", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+
+	var count int
+	if err := store.User.DB.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = ?", alice.UserID).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("stored %d orders for the retried request, want 1", count)
+	}
+}
+
+func TestCancelAllOrders(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	alice := insertTestUser(t, store, "alice")
+	bob := insertTestUser(t, store, "bob")
+	insertTestOrder(t, store, alice.UserID, "AAPL", db.OrderSideBuy, 1, 99)
+	insertTestOrder(t, store, alice.UserID, "AAPL", db.OrderSideSell, 1, 101)
+	bobs := insertTestOrder(t, store, bob.UserID, "AAPL", db.OrderSideBuy, 1, 99)
+
+	cancelAll := func(symbol string) CancelAllOrdersResponse {
+		t.Helper()
+		req := authorize(t, s, httptest.NewRequest(http.MethodPost, "/v1/orders/cancel-all?symbol="+symbol, nil), alice.UserID)
+		rec := serve(s, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var resp CancelAllOrdersResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := cancelAll("aapl"); resp.Cancelled != 2 || resp.Symbol != "AAPL" {
+		t.Errorf("first cancel-all = %+v, want 2 AAPL orders cancelled", resp)
+	}
+	if resp := cancelAll("AAPL"); resp.Cancelled != 0 {
+		t.Errorf("second cancel-all = %+v, want none cancelled", resp)
+	}
+
+	order, err := store.Order.GetByID(context.Background(), bobs.OrderID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if order.Status != db.OrderStatusOpen {
+		t.Errorf("another user's order status = %s, want open", order.Status)
+	}
+}
+
+func TestCancelAllOrdersRequiresSymbol(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	alice := insertTestUser(t, store, "alice")
+
+	rec := serve(s, authorize(t, s, httptest.NewRequest(http.MethodPost, "/v1/orders/cancel-all", nil), alice.UserID))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	alice := insertTestUser(t, store, "alice")
+	bob := insertTestUser(t, store, "bob")
+	order := insertTestOrder(t, store, alice.UserID, "AAPL", db.OrderSideBuy, 1, 99)
+
+	patch := func(userID int, status string) *httptest.ResponseRecorder {
+		t.Helper()
+		body := strings.NewReader(`{"status":"` + status + `"}`)
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/orders/%d/status", order.OrderID), body)
+		return serve(s, authorize(t, s, req, userID))
+	}
+
+	if rec := patch(bob.UserID, db.OrderStatusCancelled); rec.Code != http.StatusForbidden {
+		t.Errorf("another user's patch status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec := patch(alice.UserID, db.OrderStatusCancelled)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var updated db.Order
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if updated.Status != db.OrderStatusCancelled {
+		t.Errorf("response status = %s, want cancelled", updated.Status)
+	}
+
+	if rec := patch(alice.UserID, db.OrderStatusOpen); rec.Code != http.StatusConflict {
+		t.Errorf("reopen status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+}
+
+func TestListPositions(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	alice := insertTestUser(t, store, "alice")
+	bob := insertTestUser(t, store, "bob")
+	ctx := context.Background()
+	if err := store.Position.Upsert(ctx, alice.UserID, "AAPL", 4, 100); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if err := store.Position.Upsert(ctx, alice.UserID, "AAPL", 4, 110); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	target := fmt.Sprintf("/v1/users/%d/positions", alice.UserID)
+	rec := serve(s, authorize(t, s, httptest.NewRequest(http.MethodGet, target, nil), alice.UserID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp PositionsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Positions) != 1 || resp.Positions[0].Quantity != 8 || resp.Positions[0].AvgPrice != 105 {
+		t.Errorf("positions = %+v, want 8 AAPL at 105", resp.Positions)
+	}
+
+	rec = serve(s, authorize(t, s, httptest.NewRequest(http.MethodGet, target, nil), bob.UserID))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("another user's positions status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// pprofGrace is added to a profile's duration to write it out
+const pprofGrace = 10 * time.Second
+
+// longProfile lets a pprof handler that samples for ?seconds= (default def)
+// outlast the server's write timeout. It extends the connection's write
+// deadline to cover the profile, and hides the server's WriteTimeout from
+// the handler, which would otherwise refuse longer durations.
+func (s *Server) longProfile(h http.HandlerFunc, def time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := def
+		if sec, err := strconv.ParseFloat(r.URL.Query().Get("seconds"), 64); err == nil && sec > 0 {
+			d = time.Duration(sec * float64(time.Second))
+		}
+
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(d + pprofGrace)); err != nil {
+			LoggerFromContext(r.Context()).Warn("Failed to extend write deadline for profile", zap.Error(err))
+		}
+
+		ctx := context.WithValue(r.Context(), http.ServerContextKey, &http.Server{})
+		h(w, r.WithContext(ctx))
+	}
+}
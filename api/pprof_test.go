@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPprofRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		req     *http.Request
+		status  int
+	}{
+		{"disabled", false, adminRequest(http.MethodGet, "/debug/pprof/", nil), http.StatusNotFound},
+		{"enabled", true, adminRequest(http.MethodGet, "/debug/pprof/", nil), http.StatusOK},
+		{"named profile", true, adminRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil), http.StatusOK},
+		{"without admin token", true, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, Config{PprofEnabled: tt.enabled})
+
+			if rec := serve(s, tt.req); rec.Code != tt.status {
+				t.Errorf("%s = %d, want %d", tt.req.URL, rec.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+const (
+	// priceSubscriberBuffer bounds the ticks queued for one stream; ticks
+	// for a stream that falls behind are dropped, since only the latest
+	// price matters
+	priceSubscriberBuffer = 64
+	// maxStreamSymbols bounds the symbols one price stream may request
+	maxStreamSymbols = 50
+	// sseKeepAliveInterval spaces the comments that keep idle streams open
+	// through proxies
+	sseKeepAliveInterval = 15 * time.Second
+)
+
+// PriceTick is a single price update for a symbol
+type PriceTick struct {
+	Symbol    string    `json:"symbol"`
+	Price     float64   `json:"price"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// priceSubscription receives the ticks for a set of symbols. The broker
+// closes ch when it shuts down.
+type priceSubscription struct {
+	symbols map[string]bool
+	ch      chan PriceTick
+}
+
+// PriceBroker fans price ticks out to the streams subscribed to their symbol
+type PriceBroker struct {
+	logger *zap.Logger
+
+	mu            sync.RWMutex
+	subscriptions map[*priceSubscription]struct{}
+	closed        bool
+}
+
+// NewPriceBroker returns an empty broker
+func NewPriceBroker(logger *zap.Logger) *PriceBroker {
+	return &PriceBroker{
+		logger:        logger,
+		subscriptions: make(map[*priceSubscription]struct{}),
+	}
+}
+
+// Subscribe returns a channel of ticks for symbols and a function that ends
+// the subscription. The channel is closed when the broker shuts down.
+func (b *PriceBroker) Subscribe(symbols []string) (<-chan PriceTick, func()) {
+	sub := &priceSubscription{
+		symbols: make(map[string]bool, len(symbols)),
+		ch:      make(chan PriceTick, priceSubscriberBuffer),
+	}
+	for _, symbol := range symbols {
+		sub.symbols[db.NormalizeSymbol(symbol)] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	b.subscriptions[sub] = struct{}{}
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscriptions[sub]; ok {
+			delete(b.subscriptions, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Publish sends tick to every subscription for its symbol without blocking;
+// subscriptions whose buffer is full miss the tick. A zero timestamp is set
+// to the current time.
+func (b *PriceBroker) Publish(tick PriceTick) {
+	tick.Symbol = db.NormalizeSymbol(tick.Symbol)
+	if tick.Timestamp.IsZero() {
+		tick.Timestamp = time.Now().UTC()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscriptions {
+		if !sub.symbols[tick.Symbol] {
+			continue
+		}
+		select {
+		case sub.ch <- tick:
+		default:
+			b.logger.Debug("Dropping price tick for slow stream", zap.String("symbol", tick.Symbol))
+		}
+	}
+}
+
+// PublishPrice publishes a tick for symbol at price stamped with the current
+// time, letting the matching engine feed the stream
+func (b *PriceBroker) PublishPrice(symbol string, price float64) {
+	b.Publish(PriceTick{Symbol: symbol, Price: price})
+}
+
+// Close ends every subscription and rejects new ones, letting open streams
+// finish so the HTTP server can drain
+func (b *PriceBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subscriptions {
+		close(sub.ch)
+	}
+	b.subscriptions = nil
+}
+
+// Prices returns the broker feeding the price stream, for producers to
+// publish ticks to
+func (s *Server) Prices() *PriceBroker {
+	return s.prices
+}
+
+// parseStreamSymbols reads the comma-separated ?symbols= list, normalizing
+// and de-duplicating it
+func parseStreamSymbols(r *http.Request) ([]string, error) {
+	raw, err := queryFilter(r, "symbols")
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		symbol := db.NormalizeSymbol(part)
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+
+	switch {
+	case len(symbols) == 0:
+		return nil, errors.New("symbols must list at least one symbol")
+	case len(symbols) > maxStreamSymbols:
+		return nil, fmt.Errorf("symbols must list at most %d symbols", maxStreamSymbols)
+	}
+	return symbols, nil
+}
+
+// priceStreamHandler streams ticks for ?symbols= as server-sent events until
+// the client disconnects or the server shuts down
+func (s *Server) priceStreamHandler(w http.ResponseWriter, r *http.Request) {
+	symbols, err := parseStreamSymbols(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout would otherwise cut the stream off
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to clear write deadline for price stream", zap.Error(err))
+		writeError(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	ticks, unsubscribe := s.prices.Subscribe(symbols)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Send the headers right away so clients know the stream is open
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case tick, ok := <-ticks:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(tick)
+			if err != nil {
+				LoggerFromContext(r.Context()).Error("Failed to encode price tick", zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// subscriptionCount returns how many streams are subscribed to b
+func subscriptionCount(b *PriceBroker) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscriptions)
+}
+
+func TestPriceStream(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/v1/stream/prices?symbols=aapl,MSFT", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	lines := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		t.Helper()
+		var event strings.Builder
+		for {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			if line == "\n" {
+				return event.String()
+			}
+			event.WriteString(line)
+		}
+	}
+
+	if event := readEvent(); event != ": connected\n" {
+		t.Fatalf("first event = %q, want the connected comment", event)
+	}
+
+	// The stream subscribes before sending the connected comment
+	s.Prices().Publish(PriceTick{Symbol: "AAPL", Price: 101})
+	s.Prices().Publish(PriceTick{Symbol: "TSLA", Price: 5})
+	s.Prices().Publish(PriceTick{Symbol: "msft", Price: 202})
+
+	for _, want := range []PriceTick{{Symbol: "AAPL", Price: 101}, {Symbol: "MSFT", Price: 202}} {
+		event := readEvent()
+		data, ok := strings.CutPrefix(event, "data: ")
+		if !ok || strings.Count(event, "\n") != 1 {
+			t.Fatalf("event = %q, want a single data line", event)
+		}
+		var tick PriceTick
+		if err := json.Unmarshal([]byte(data), &tick); err != nil {
+			t.Fatalf("decode tick: %v", err)
+		}
+		if tick.Symbol != want.Symbol || tick.Price != want.Price || tick.Timestamp.IsZero() {
+			t.Errorf("tick = %+v, want %s at %v with a timestamp", tick, want.Symbol, want.Price)
+		}
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for subscriptionCount(s.Prices()) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream still subscribed after the client disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPriceStreamRequiresSymbols(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/stream/prices?symbols=,", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPriceStreamOutlivesWriteTimeout(t *testing.T) {
+	s, _ := newTestServer(t, Config{WriteTimeout: 50 * time.Millisecond})
+	ts := httptest.NewUnstartedServer(s.handler)
+	ts.Config = s.httpServer("")
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/stream/prices?symbols=AAPL")
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewReader(resp.Body)
+	if line, err := lines.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("first line = %q, %v, want the connected comment", line, err)
+	}
+
+	// Well past the server's write timeout, the stream still delivers
+	time.Sleep(150 * time.Millisecond)
+	s.Prices().Publish(PriceTick{Symbol: "AAPL", Price: 101})
+	for {
+		line, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream after the write timeout: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			break
+		}
+	}
+}
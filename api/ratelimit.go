@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rateLimitCleanupInterval is how often idle client limiters are purged
+	rateLimitCleanupInterval = time.Minute
+	// rateLimitIdleTTL is how long a client may be idle before it's purged
+	rateLimitIdleTTL = 3 * time.Minute
+)
+
+// clientLimiter is the token bucket for a single client IP
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per client IP
+type rateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+	limit   rate.Limit
+	burst   int
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		clients: make(map[string]*clientLimiter),
+		limit:   rate.Limit(rps),
+		burst:   burst,
+	}
+}
+
+// allow reports whether the client may proceed and, if not, how long it
+// should wait before retrying
+func (rl *rateLimiter) allow(ip string) (bool, time.Duration) {
+	rl.mu.Lock()
+	client, ok := rl.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.clients[ip] = client
+	}
+	client.lastSeen = time.Now()
+	rl.mu.Unlock()
+
+	reservation := client.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// cleanup drops limiters for clients idle longer than ttl
+func (rl *rateLimiter) cleanup(ttl time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for ip, client := range rl.clients {
+		if time.Since(client.lastSeen) > ttl {
+			delete(rl.clients, ip)
+		}
+	}
+}
+
+// runCleanup purges idle clients periodically until ctx is cancelled
+func (rl *rateLimiter) runCleanup(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rl.cleanup(rateLimitIdleTTL)
+		}
+	}
+}
+
+// clientIP returns the client address without its port. RemoteAddr has
+// already been rewritten by realIPMiddleware for requests from trusted proxies.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitMiddleware rejects clients exceeding their request rate with 429
+// and a Retry-After header
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := s.limiter.allow(clientIP(r)); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeError(w, r, http.StatusTooManyRequests, "Rate limit exceeded, retry later")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
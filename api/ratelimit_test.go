@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitRejectsBurstOverflow(t *testing.T) {
+	const burst = 3
+	s, _ := newTestServer(t, Config{RateLimitRPS: 0.1, RateLimitBurst: burst})
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = remoteAddr
+		return serve(s, req)
+	}
+
+	for i := range burst {
+		if rec := request("192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := request("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request %d status = %d, want %d", burst+1, rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("429 response has no Retry-After header")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	if rec := request("192.0.2.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("another client's status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimiterCleanupDropsIdleClients(t *testing.T) {
+	rl := newRateLimiter(1, 1)
+	rl.allow("192.0.2.1")
+	rl.allow("192.0.2.2")
+	rl.clients["192.0.2.1"].lastSeen = time.Now().Add(-time.Hour)
+
+	rl.cleanup(time.Minute)
+
+	if _, ok := rl.clients["192.0.2.1"]; ok {
+		t.Error("idle client kept")
+	}
+	if _, ok := rl.clients["192.0.2.2"]; !ok {
+		t.Error("active client dropped")
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// realIPMiddleware rewrites RemoteAddr to the client address from
+// X-Forwarded-For or X-Real-IP, but only when the direct peer is one of the
+// trusted proxies. Anyone else could set those headers to dodge the per-IP
+// rate limit, so for them, and when no proxies are trusted, RemoteAddr is
+// left alone.
+func (s *Server) realIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if peer, ok := parseRemoteAddr(r.RemoteAddr); ok && s.trustedProxy(peer) {
+			if ip, ok := s.forwardedClientIP(r.Header); ok {
+				r.RemoteAddr = ip.String()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedProxy reports whether addr is in one of the trusted proxy ranges
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	for _, prefix := range s.config.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedClientIP returns the client address a trusted proxy passed on.
+// X-Forwarded-For is read from the right, since proxies append to it, and
+// the first address that isn't itself a trusted proxy is the client; entries
+// further left were supplied by the client and can't be trusted. Without
+// X-Forwarded-For, X-Real-IP is used.
+func (s *Server) forwardedClientIP(h http.Header) (netip.Addr, bool) {
+	if forwarded := h.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		var client netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+			if !s.trustedProxy(client) {
+				break
+			}
+		}
+		return client, client.IsValid()
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// parseRemoteAddr parses the IP out of an http.Request RemoteAddr, which is
+// host:port for real connections
+func parseRemoteAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	s := &Server{config: Config{TrustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+	}}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"trusted proxy", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"trusted single address", "192.0.2.1:4567", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"untrusted peer", "198.51.100.7:4567", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "198.51.100.7:4567"},
+		{"spoofed hop before the proxy", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.9"}, "203.0.113.9"},
+		{"chain of trusted proxies", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "203.0.113.9, 10.9.9.9"}, "203.0.113.9"},
+		{"X-Real-IP", "10.1.2.3:4567", map[string]string{"X-Real-IP": "203.0.113.9"}, "203.0.113.9"},
+		{"invalid forwarded address", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "not-an-ip"}, "10.1.2.3:4567"},
+		{"no header", "10.1.2.3:4567", nil, "10.1.2.3:4567"},
+		{"IPv4-mapped peer", "[::ffff:10.1.2.3]:4567", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := s.realIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRealIPIgnoresHeadersWithoutTrustedProxies(t *testing.T) {
+	s := &Server{}
+	var got string
+	h := s.realIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.RemoteAddr
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "10.1.2.3:4567" {
+		t.Errorf("RemoteAddr = %q, want the peer unchanged", got)
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// APIVersion prefixes the versioned resource routes and is reported in the
+// API-Version response header
+const APIVersion = "v1"
+
+// apiVersionMiddleware sets the API-Version header on versioned responses
+func apiVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", APIVersion)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setupRoutes configures all the API routes. It fails if the configuration
+// refers to routes that don't exist.
+func (s *Server) setupRoutes() error {
+
+	// CORS runs first so preflight requests are answered before anything else
+	s.router.Use(s.corsMiddleware)
+
+	// Add built-in Chi middleware
+	s.router.Use(middleware.RequestID)
+	s.router.Use(s.realIPMiddleware)
+
+	// Add custom logging middleware
+	s.router.Use(s.loggingMiddleware)
+	s.router.Use(s.otelMiddleware)
+	if s.metrics != nil {
+		s.router.Use(s.metricsMiddleware)
+	}
+	// Recovery runs inside logging, tracing and metrics so a panicking
+	// request is still logged, traced and counted as the 500 it becomes.
+	// timeoutMiddleware re-panics handler panics on this goroutine.
+	s.router.Use(s.recoveryMiddleware)
+	s.router.Use(s.timeoutMiddleware)
+
+	if s.limiter != nil {
+		s.router.Use(s.rateLimitMiddleware)
+	}
+	if s.config.MaxBodyBytes > 0 {
+		s.router.Use(maxBodyBytesMiddleware(s.config.MaxBodyBytes))
+	}
+	// Body logging reads the whole request body, so it goes after the limit
+	if s.config.LogBodies {
+		s.router.Use(s.bodyLogMiddleware)
+	}
+
+	// Health check endpoints; monitors may probe with HEAD, for which
+	// net/http sends the same status and headers without the body
+	s.router.Get("/health", s.healthCheckHandler)
+	s.router.Head("/health", s.healthCheckHandler)
+	s.router.Get("/readiness", s.readinessHandler)
+	s.router.Head("/readiness", s.readinessHandler)
+	if s.metrics != nil {
+		s.router.Method("GET", "/metrics", promhttp.Handler())
+	}
+
+	// Resource endpoints are versioned; probes and ops routes stay at the root
+	s.router.Route("/"+APIVersion, func(r chi.Router) {
+		r.Use(apiVersionMiddleware)
+
+		r.With(s.idempotencyMiddleware).Post("/create_user", s.createUserHandler)
+		r.With(s.allowQueryParams("atomic"), s.idempotencyMiddleware).Post("/users/batch", s.createUsersBatchHandler)
+		r.With(s.allowQueryParams("limit", "offset", "after_id", "sort", "username", "created_from", "created_to")).Get("/users", s.listUsersHandler)
+		// Search is for the admin UI and would let anyone enumerate emails
+		r.With(s.requireAdminToken, s.allowQueryParams("q", "limit", "offset")).Get("/users/search", s.searchUsersHandler)
+		// Bulk export and import are for operators, so they need the admin token
+		r.With(s.requireAdminToken, s.allowQueryParams("created_from", "created_to")).Get("/users/export", s.exportUsersHandler)
+		r.With(s.requireAdminToken).Post("/users/import", s.importUsersHandler)
+		r.Get("/users/{id}", s.getUserHandler)
+		r.Post("/login", s.loginHandler)
+		r.Get("/instruments", s.listInstrumentsHandler)
+		r.Get("/symbols/{symbol}/orderbook", s.orderBookHandler)
+		r.With(s.allowQueryParams("symbols")).Get("/stream/prices", s.priceStreamHandler)
+
+		r.With(s.allowQueryParams("symbol", "timeframe", "from", "to", "limit")).Get("/bars", s.queryBarsHandler)
+		// Market data ingestion is an operator task, guarded by the admin token
+		r.With(s.requireAdminToken).Post("/bars", s.insertBarsHandler)
+		if s.trade != nil {
+			r.With(s.allowQueryParams("interval", "from", "to", "limit")).Get("/prices/{symbol}/ohlc", s.ohlcHandler)
+		}
+		// The audit trail spans all users, so it is an operator view too
+		if s.audit != nil {
+			r.With(s.requireAdminToken, s.allowQueryParams("user_id", "limit", "offset")).Get("/audit", s.listAuditHandler)
+		}
+
+		// Account and order endpoints require a bearer token for that user
+		r.Group(func(r chi.Router) {
+			r.Use(s.authMiddleware)
+			r.Put("/users/{id}", s.updateUserHandler)
+			r.Delete("/users/{id}", s.deleteUserHandler)
+			r.Get("/users/{id}/positions", s.listPositionsHandler)
+			r.With(s.idempotencyMiddleware).Post("/orders", s.createOrderHandler)
+			r.With(s.allowQueryParams("symbol")).Post("/orders/cancel-all", s.cancelAllOrdersHandler)
+			r.Get("/orders/{id}", s.getOrderHandler)
+			r.Patch("/orders/{id}/status", s.updateOrderStatusHandler)
+			if s.hub != nil {
+				r.Get("/ws/orders", s.orderUpdatesHandler)
+			}
+		})
+	})
+
+	// Admin endpoints, guarded by the admin token
+	s.router.Route("/admin", func(r chi.Router) {
+		r.Use(s.requireAdminToken)
+		if s.dbManager != nil {
+			r.Post("/maintenance", s.maintenanceHandler)
+			r.Post("/migrate", s.migrateHandler)
+			r.Post("/reset", s.resetHandler)
+			r.Get("/schema", s.schemaHandler)
+		}
+		r.Post("/users/{id}/restore", s.restoreUserHandler)
+	})
+
+	// Profiling exposes internals, so it is opt-in and needs the admin token
+	if s.config.PprofEnabled {
+		s.router.Route("/debug/pprof", func(r chi.Router) {
+			r.Use(s.requireAdminToken)
+			r.Get("/", pprof.Index)
+			r.Get("/cmdline", pprof.Cmdline)
+			// Profiles and traces sample for ?seconds=, by default 30 and 1
+			r.Get("/profile", s.longProfile(pprof.Profile, 30*time.Second))
+			r.HandleFunc("/symbol", pprof.Symbol)
+			r.Get("/trace", s.longProfile(pprof.Trace, time.Second))
+			// Index serves the named profiles: heap, goroutine, allocs, ...
+			r.Get("/{profile}", pprof.Index)
+		})
+	}
+
+	// Add a catch-all for 404s
+	s.router.NotFound(s.notFoundHandler)
+
+	// Behind a reverse proxy every route, health checks included, moves under
+	// the base path and the bare paths are not found
+	s.handler = s.router
+	if s.config.BasePath != "" {
+		root := chi.NewRouter()
+		root.Mount(s.config.BasePath, s.router)
+		root.NotFound(s.notFoundHandler)
+		s.handler = root
+	}
+
+	return s.validateRouteTimeouts()
+}
+
+// routePattern returns the pattern of the route matching r, without the base
+// path, or "" if none matches
+func (s *Server) routePattern(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, s.config.BasePath)
+	return s.router.Find(chi.NewRouteContext(), r.Method, path)
+}
@@ -0,0 +1,426 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/chrisp986/trader-backend/engine"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Server holds the server configuration and dependencies
+type Server struct {
+	router chi.Router
+	// handler serves requests: router, or a router mounting it at BasePath
+	handler    http.Handler
+	startTime  time.Time
+	version    string
+	config     Config
+	logger     *zap.Logger
+	user       db.UserModelInterface
+	order      db.OrderModelInterface
+	position   db.PositionModelInterface
+	instrument db.InstrumentModelInterface
+	bar        db.BarModelInterface
+	// trade aggregates fills into price bars; nil disables the OHLC endpoint
+	trade db.TradeModelInterface
+	// idempotency stores responses for Idempotency-Key retries; nil ignores
+	// the header
+	idempotency db.IdempotencyModelInterface
+	// audit records mutating operations; nil disables the audit trail
+	audit db.AuditModelInterface
+	// hub streams order updates; nil disables the WebSocket endpoint
+	hub *Hub
+	// matcher matches orders in demo exchange mode; nil leaves them open
+	matcher *engine.MatchingEngine
+	// prices feeds the server-sent price stream
+	prices *PriceBroker
+	// dbManager runs health checks and admin operations; nil, for stores
+	// without a database, skips the database checks and admin endpoints
+	dbManager db.DatabaseManagerInterface
+
+	// limiter tracks per-client request rates when rate limiting is enabled
+	limiter *rateLimiter
+
+	// accessLog writes CLF access lines when a non-JSON format is configured
+	accessLog *accessLogger
+
+	// metrics records Prometheus HTTP metrics when they are enabled
+	metrics *metrics
+
+	// redactParams holds lowercased query parameter names masked in request logs
+	redactParams map[string]bool
+	// redactFields holds lowercased JSON field names masked in logged bodies
+	redactFields map[string]bool
+
+	// draining is set once a shutdown signal arrives so /readiness fails
+	// while the server keeps serving for the drain delay
+	draining atomic.Bool
+
+	// shutdownPhases run in order once the HTTP server has drained
+	shutdownPhases []shutdownPhase
+}
+
+// responseWriter wraps http.ResponseWriter to capture status code and the
+// number of body bytes written
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += n
+	return n, err
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack lets WebSocket upgrades take over the connection
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Defaults for the Config durations left unset
+const (
+	defaultShutdownTimeout   = 30 * time.Second
+	defaultReadTimeout       = 15 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultWriteTimeout      = 15 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+)
+
+// Services bundles the server's optional dependencies besides storage
+type Services struct {
+	// Hub, if set, streams order updates over /v1/ws/orders; it should also
+	// be the Order model's Publisher
+	Hub *Hub
+	// Matcher, if set, matches new orders and handles cancellations of
+	// resting ones (demo exchange mode)
+	Matcher *engine.MatchingEngine
+}
+
+// NewServer creates a new server instance whose handlers use the models in
+// store. The store's idempotency model, if any, lets creation endpoints
+// replay responses for requests retried with the same Idempotency-Key, and
+// its audit model, if any, records user changes and serves them at
+// /v1/audit. dbManager may be nil when store has no database. NewServer
+// returns an error if the routes can't be set up from cfg, so callers can
+// fail fast.
+func NewServer(cfg Config, logger *zap.Logger, store db.Store, services Services, dbManager db.DatabaseManagerInterface) (*Server, error) {
+
+	server := &Server{
+		router:      chi.NewRouter(),
+		startTime:   time.Now(),
+		version:     getVersion(),
+		config:      cfg,
+		logger:      logger,
+		user:        store.Users(),
+		order:       store.Orders(),
+		position:    store.Positions(),
+		instrument:  store.Instruments(),
+		bar:         store.Bars(),
+		trade:       store.Trades(),
+		idempotency: store.Idempotency(),
+		audit:       store.Audit(),
+		hub:         services.Hub,
+		matcher:     services.Matcher,
+		prices:      NewPriceBroker(logger),
+		dbManager:   dbManager,
+	}
+
+	server.redactParams = make(map[string]bool, len(cfg.RedactParams))
+	for _, param := range cfg.RedactParams {
+		server.redactParams[strings.ToLower(param)] = true
+	}
+	server.redactFields = make(map[string]bool, len(cfg.RedactFields))
+	for _, field := range cfg.RedactFields {
+		server.redactFields[strings.ToLower(field)] = true
+	}
+
+	out := cfg.AccessLogOutput
+	if out == nil {
+		out = os.Stdout
+	}
+	accessLog, err := newAccessLogger(cfg.AccessLogFormat, out)
+	if err != nil {
+		return nil, err
+	}
+	server.accessLog = accessLog
+
+	if server.config.ShutdownTimeout <= 0 {
+		server.config.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if server.config.ReadTimeout <= 0 {
+		server.config.ReadTimeout = defaultReadTimeout
+	}
+	if server.config.ReadHeaderTimeout <= 0 {
+		server.config.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if server.config.WriteTimeout <= 0 {
+		server.config.WriteTimeout = defaultWriteTimeout
+	}
+	if server.config.IdleTimeout <= 0 {
+		server.config.IdleTimeout = defaultIdleTimeout
+	}
+
+	if cfg.MetricsEnabled {
+		m, err := newMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+		server.metrics = m
+		if dbManager != nil {
+			dbManager.SetQueryObserver(m)
+		}
+	}
+
+	if cfg.RateLimitRPS > 0 {
+		server.limiter = newRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	}
+
+	if err := server.setupRoutes(); err != nil {
+		return nil, fmt.Errorf("failed to set up routes: %w", err)
+	}
+
+	if server.limiter != nil {
+		// Stop purging idle clients before the later phases (logs, database) run
+		ctx, cancel := context.WithCancel(context.Background())
+		go server.limiter.runCleanup(ctx)
+		server.OnShutdown("stop rate limiter cleanup", func(context.Context) error {
+			cancel()
+			return nil
+		})
+	}
+
+	logger.Info("Trader backend version:", zap.String("version", server.version))
+
+	return server, nil
+}
+
+// getVersion returns the application version from environment or default
+func getVersion() string {
+	version := os.Getenv("APP_VERSION")
+	if version == "" {
+		return "1.0.0"
+	}
+	return version
+}
+
+// loggingMiddleware logs all incoming requests
+func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Create a response writer wrapper to capture status code
+		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		// Give handlers a logger with the request context pre-bound; requests
+		// that arrive during shutdown are marked as draining
+		fields := []zap.Field{
+			zap.String("request_id", middleware.GetReqID(r.Context())),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		}
+		if s.draining.Load() {
+			fields = append(fields, zap.Bool("draining", true))
+		}
+		reqLogger := s.logger.With(fields...)
+		r = r.WithContext(contextWithLogger(r.Context(), reqLogger))
+		// Work the request sets off in the background, such as order
+		// updates pushed to streams, logs the request id as correlation_id
+		r = r.WithContext(db.WithCorrelationID(r.Context(), middleware.GetReqID(r.Context())))
+
+		// Process request
+		next.ServeHTTP(wrapped, r)
+
+		query := s.redactQuery(r.URL.RawQuery)
+		if s.accessLog != nil {
+			uri := r.URL.Path
+			if query != "" {
+				uri += "?" + query
+			}
+			s.accessLog.log(r, uri, wrapped.statusCode, wrapped.bytes, start)
+			return
+		}
+
+		// Log request details
+		duration := time.Since(start)
+		s.logger.Info("HTTP request processed",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Bool("draining", s.draining.Load()),
+			zap.String("query", query),
+			zap.Int("status_code", wrapped.statusCode),
+			zap.Int64("duration_ms", duration.Milliseconds()),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+			zap.String("request_id", middleware.GetReqID(r.Context())),
+		)
+	})
+}
+
+// maxLoggedQueryLen caps the query string length written to request logs
+const maxLoggedQueryLen = 512
+
+// redactQuery masks the values of sensitive query parameters and truncates
+// the result so a huge query string can't bloat the logs
+func (s *Server) redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if s.redactParams[strings.ToLower(name)] {
+			pairs[i] = key + "=REDACTED"
+		}
+	}
+
+	query := strings.Join(pairs, "&")
+	if len(query) > maxLoggedQueryLen {
+		query = query[:maxLoggedQueryLen] + "...(truncated)"
+	}
+	return query
+}
+
+// httpServer returns the http.Server that Run serves s with on addr
+func (s *Server) httpServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s.handler,
+		ReadTimeout:       s.config.ReadTimeout,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+	}
+}
+
+// Run serves HTTP on addr until ctx is cancelled, then drains and shuts
+// down. It returns the listen error if the server can't start.
+func (s *Server) Run(ctx context.Context, addr string) error {
+	srv := s.httpServer(addr)
+
+	// End open price streams when shutdown starts so the drain can finish
+	srv.RegisterOnShutdown(s.prices.Close)
+
+	useTLS := s.config.TLSCertFile != "" && s.config.TLSKeyFile != ""
+	if useTLS {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if useTLS {
+			s.logger.Info("Starting HTTPS server", zap.String("address", addr), zap.String("cert_file", s.config.TLSCertFile))
+			err = srv.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			s.logger.Info("Starting HTTP server", zap.String("address", addr))
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to serve on %s: %w", addr, err)
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down server...")
+
+	// Keep serving while /readiness reports 503 so load balancers drain us
+	s.draining.Store(true)
+	if s.config.DrainDelay > 0 {
+		s.logger.Info("Draining before shutdown", zap.Duration("delay", s.config.DrainDelay))
+		time.Sleep(s.config.DrainDelay)
+	}
+
+	// Create a deadline for shutdown
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting new connections and drain in-flight requests first, then
+	// run the registered phases (background jobs, logs, database) in order
+	phases := append([]shutdownPhase{
+		{name: "drain http server", run: srv.Shutdown},
+	}, s.shutdownPhases...)
+
+	if err := runShutdownPhases(shutdownCtx, s.logger, phases); err != nil {
+		s.logger.Error("Server forced to shutdown", zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("Server stopped gracefully")
+	return nil
+}
+
+// Start runs the HTTP server until SIGINT or SIGTERM. A second signal during
+// a slow shutdown forces an immediate exit.
+func (s *Server) Start(addr string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	quit := make(chan os.Signal, 2)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	go s.handleSignals(ctx, quit, cancel, os.Exit)
+
+	return s.Run(ctx, addr)
+}
+
+// handleSignals calls cancel on the first signal from quit, starting the
+// shutdown, and exit(1) on a second one. It returns if ctx ends first.
+func (s *Server) handleSignals(ctx context.Context, quit <-chan os.Signal, cancel context.CancelFunc, exit func(code int)) {
+	select {
+	case <-quit:
+		cancel()
+	case <-ctx.Done():
+		return
+	}
+
+	sig := <-quit
+	s.logger.Warn("Received second shutdown signal, forcing exit", zap.String("signal", sig.String()))
+	s.logger.Sync()
+	exit(1)
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// testAdminToken is the admin token newTestServer configures
+const testAdminToken = "test-admin-token"
+
+// newTestServer returns a server on a migrated SQLite database in a temporary
+// directory, using cfg with test defaults filled in for the JWT and admin
+// settings and access log output. It also returns the server's store, for
+// seeding data. The database is closed when the test ends.
+func newTestServer(t *testing.T, cfg Config) (*Server, *db.SQLStore) {
+	t.Helper()
+	return newLoggedTestServer(t, cfg, zap.NewNop())
+}
+
+// newLoggedTestServer is newTestServer with the server logging to logger
+func newLoggedTestServer(t *testing.T, cfg Config, logger *zap.Logger) (*Server, *db.SQLStore) {
+	t.Helper()
+	return newServicesTestServer(t, cfg, logger, func(*db.SQLStore) Services { return Services{} })
+}
+
+// newServicesTestServer is newLoggedTestServer with the services returned by
+// services, which may wire them to the store
+func newServicesTestServer(t *testing.T, cfg Config, logger *zap.Logger, services func(store *db.SQLStore) Services) (*Server, *db.SQLStore) {
+	t.Helper()
+
+	dm, err := db.NewDatabaseManager(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	if err := dm.InitializeDatabase(); err != nil {
+		t.Fatalf("InitializeDatabase: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+
+	if cfg.JWTSecret == nil {
+		cfg.JWTSecret = []byte("0123456789abcdef0123456789abcdef")
+	}
+	if cfg.JWTTTL == 0 {
+		cfg.JWTTTL = time.Hour
+	}
+	if cfg.AdminToken == "" {
+		cfg.AdminToken = testAdminToken
+	}
+	if cfg.AccessLogOutput == nil {
+		cfg.AccessLogOutput = io.Discard
+	}
+
+	store := db.NewSQLStore(dm, zap.NewNop())
+	s, err := NewServer(cfg, logger, store, services(store), dm)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s, store
+}
+
+// serve sends req through the server's handler and returns the recorded
+// response
+func serve(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// adminRequest returns a request carrying the test admin token
+func adminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	return req
+}
+
+// insertTestUser stores a user named username and returns it
+func insertTestUser(t *testing.T, store *db.SQLStore, username string) *db.User {
+	t.Helper()
+
+	user := &db.User{Username: username, Email: username + "@example.com"}
+	if err := store.User.Insert(context.Background(), user); err != nil {
+		t.Fatalf("Insert user %s: %v", username, err)
+	}
+	return user
+}
+
+func TestRequestLogRedactsQuery(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, _ := newLoggedTestServer(t, Config{RedactParams: []string{"token", "Key"}}, zap.New(core))
+
+	serve(s, httptest.NewRequest(http.MethodGet, "/health?verbose=1&TOKEN=secret&key=abc", nil))
+
+	entries := logs.FilterMessage("HTTP request processed").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d request log entries, want 1", len(entries))
+	}
+	want := "verbose=1&TOKEN=REDACTED&key=REDACTED"
+	if got := entries[0].ContextMap()["query"]; got != want {
+		t.Errorf("logged query = %q, want %q", got, want)
+	}
+}
+
+func TestRedactQueryTruncatesLongQueries(t *testing.T) {
+	s := &Server{}
+
+	got := s.redactQuery("q=" + strings.Repeat("x", 2*maxLoggedQueryLen))
+	if !strings.HasSuffix(got, "...(truncated)") || len(got) != maxLoggedQueryLen+len("...(truncated)") {
+		t.Errorf("redactQuery returned %d bytes ending %q, want it truncated", len(got), got[len(got)-20:])
+	}
+}
+
+func TestNewServerReturnsSetupErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"unknown route timeout", Config{RouteTimeouts: map[string]time.Duration{"/v1/nope": time.Second}}, `failed to set up routes: route timeout configured for unknown route "/v1/nope"`},
+		{"unknown access log format", Config{AccessLogFormat: "xml"}, "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.AccessLogOutput = io.Discard
+			s, err := NewServer(tt.cfg, zap.NewNop(), db.NewMemoryStore(), Services{}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewServer = %v, want an error containing %q", err, tt.want)
+			}
+			if s != nil {
+				t.Error("NewServer returned a server along with the error")
+			}
+		})
+	}
+}
+
+func TestRequestLogIncludesRequestIDAndUserAgent(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, _ := newLoggedTestServer(t, Config{}, zap.New(core))
+
+	req := httptest.NewRequest(http.MethodGet, "/no/such/route", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	rec := serve(s, req)
+
+	entries := logs.FilterMessage("HTTP request processed").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d request log entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	requestID, _ := fields["request_id"].(string)
+	if requestID == "" {
+		t.Error("request log has no request_id")
+	}
+	if fields["user_agent"] != "test-agent" {
+		t.Errorf("request log user_agent = %v, want test-agent", fields["user_agent"])
+	}
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.RequestID != requestID {
+		t.Errorf("error response request_id = %q, want the logged %q", resp.RequestID, requestID)
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	tests := []struct {
+		target  string
+		status  int
+		version string
+	}{
+		{"/" + APIVersion + "/users", http.StatusOK, APIVersion},
+		{"/users", http.StatusNotFound, ""},
+		{"/health", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serve(s, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if v := rec.Header().Get("API-Version"); v != tt.version {
+				t.Errorf("API-Version = %q, want %q", v, tt.version)
+			}
+		})
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, _ := newLoggedTestServer(t, Config{BasePath: "/api"}, zap.New(core))
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/api/health", http.StatusOK},
+		{"/api/v1/users", http.StatusOK},
+		{"/health", http.StatusNotFound},
+		{"/v1/users", http.StatusNotFound},
+		{"/apiv1/users", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if rec := serve(s, httptest.NewRequest(http.MethodGet, tt.target, nil)); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+
+	logs.TakeAll()
+	rec := serve(s, jsonRequest(http.MethodPost, "/api/v1/create_user", `{"user_name":"john","email":"john@example.com"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if loc := rec.Header().Get("Location"); !strings.HasPrefix(loc, "/api/v1/users/") {
+		t.Errorf("Location = %q, want it under the base path", loc)
+	}
+	entries := logs.FilterMessage("HTTP request processed").All()
+	if len(entries) != 1 || entries[0].ContextMap()["path"] != "/api/v1/create_user" {
+		t.Errorf("request logs = %v, want one with the full path", entries)
+	}
+}
+
+// newMemoryTestServer returns a server on a MemoryStore, with no database
+func newMemoryTestServer(t *testing.T) (*Server, *db.MemoryStore) {
+	t.Helper()
+
+	store := db.NewMemoryStore()
+	cfg := Config{
+		JWTSecret:       []byte("0123456789abcdef0123456789abcdef"),
+		JWTTTL:          time.Hour,
+		AdminToken:      testAdminToken,
+		AccessLogOutput: io.Discard,
+	}
+	s, err := NewServer(cfg, zap.NewNop(), store, Services{}, nil)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s, store
+}
+
+func TestMemoryStoreServer(t *testing.T) {
+	s, store := newMemoryTestServer(t)
+	store.Instrument.Add(&db.Instrument{Symbol: "aapl", Name: "Apple Inc.", Exchange: "NASDAQ", TickSize: 0.01, Active: true})
+
+	rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user", `{"user_name":"john","email":"john@example.com"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	user, err := store.User.GetByEmail(context.Background(), "john@example.com")
+	if err != nil {
+		t.Fatalf("user not in the memory store: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"duplicate", jsonRequest(http.MethodPost, "/v1/create_user", `{"user_name":"other","email":"JOHN@example.com"}`), http.StatusConflict},
+		{"get", httptest.NewRequest(http.MethodGet, "/v1/users/"+strconv.Itoa(user.UserID), nil), http.StatusOK},
+		{"list", httptest.NewRequest(http.MethodGet, "/v1/users", nil), http.StatusOK},
+		{"order", authorize(t, s, jsonRequest(http.MethodPost, "/v1/orders", `{"symbol":"AAPL","side":"buy","type":"limit","quantity":1,"price":100}`), user.UserID), http.StatusCreated},
+		{"unknown symbol", authorize(t, s, jsonRequest(http.MethodPost, "/v1/orders", `{"symbol":"NOPE","side":"buy","type":"limit","quantity":1,"price":100}`), user.UserID), http.StatusUnprocessableEntity},
+		{"health", httptest.NewRequest(http.MethodGet, "/health", nil), http.StatusOK},
+		{"no database admin routes", adminRequest(http.MethodPost, "/admin/migrate", nil), http.StatusNotFound},
+		{"no audit trail", adminRequest(http.MethodGet, "/v1/audit", nil), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(s, tt.req); rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	cfg := Config{
+		ReadTimeout:       7 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      11 * time.Second,
+		IdleTimeout:       90 * time.Second,
+	}
+	s, _ := newTestServer(t, cfg)
+
+	srv := s.httpServer("127.0.0.1:8080")
+	if srv.Addr != "127.0.0.1:8080" || srv.Handler == nil {
+		t.Errorf("server = %q with handler %v, want the address and the server's handler", srv.Addr, srv.Handler)
+	}
+	if srv.ReadTimeout != cfg.ReadTimeout || srv.ReadHeaderTimeout != cfg.ReadHeaderTimeout ||
+		srv.WriteTimeout != cfg.WriteTimeout || srv.IdleTimeout != cfg.IdleTimeout {
+		t.Errorf("timeouts = read %v, header %v, write %v, idle %v, want %+v",
+			srv.ReadTimeout, srv.ReadHeaderTimeout, srv.WriteTimeout, srv.IdleTimeout, cfg)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shutdownPhase is a single named step of the graceful shutdown sequence
+type shutdownPhase struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// OnShutdown registers a phase to run after the HTTP server has drained.
+// Phases run in the order they were registered.
+func (s *Server) OnShutdown(name string, run func(ctx context.Context) error) {
+	s.shutdownPhases = append(s.shutdownPhases, shutdownPhase{name: name, run: run})
+}
+
+// runShutdownPhases executes each phase in order, logging its progress. A
+// failing phase doesn't stop later ones from running; all errors are returned.
+func runShutdownPhases(ctx context.Context, logger *zap.Logger, phases []shutdownPhase) error {
+	var errs []error
+
+	for i, phase := range phases {
+		start := time.Now()
+		logger.Info("Shutdown phase started",
+			zap.Int("phase", i+1),
+			zap.String("name", phase.name),
+		)
+
+		if err := phase.run(ctx); err != nil {
+			logger.Error("Shutdown phase failed",
+				zap.Int("phase", i+1),
+				zap.String("name", phase.name),
+				zap.Duration("duration", time.Since(start)),
+				zap.Error(err),
+			)
+			errs = append(errs, err)
+			continue
+		}
+
+		logger.Info("Shutdown phase completed",
+			zap.Int("phase", i+1),
+			zap.String("name", phase.name),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// freeAddr returns a loopback address with a port that was free just now
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestShutdownPhasesRunInOrder(t *testing.T) {
+	var ran []string
+	record := func(name string, err error) shutdownPhase {
+		return shutdownPhase{name: name, run: func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	failure := errors.New("flush failed")
+	phases := []shutdownPhase{
+		record("drain http server", nil),
+		record("stop background jobs", nil),
+		record("flush logs", failure),
+		record("close database", nil),
+	}
+
+	err := runShutdownPhases(context.Background(), zap.NewNop(), phases)
+
+	want := []string{"drain http server", "stop background jobs", "flush logs", "close database"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("phases ran %v, want %v", ran, want)
+	}
+	if !errors.Is(err, failure) {
+		t.Errorf("error = %v, want %v", err, failure)
+	}
+}
+
+func TestRunDrainsBeforeRegisteredPhases(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	addr := freeAddr(t)
+
+	var ran []string
+	s.OnShutdown("stop background jobs", func(context.Context) error {
+		ran = append(ran, "stop background jobs")
+		// The HTTP server must have stopped listening by now
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			t.Error("server still accepting connections in a later phase")
+		}
+		return nil
+	})
+	s.OnShutdown("close database", func(context.Context) error {
+		ran = append(ran, "close database")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, addr) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	want := []string{"stop background jobs", "close database"}
+	if !slices.Equal(ran, want) {
+		t.Errorf("phases ran %v, want %v", ran, want)
+	}
+	if !s.draining.Load() {
+		t.Error("server not marked draining after shutdown")
+	}
+}
+
+func TestSecondSignalForcesExit(t *testing.T) {
+	s := &Server{logger: zap.NewNop()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	quit := make(chan os.Signal, 2)
+	exited := make(chan int, 1)
+	done := make(chan struct{})
+	go func() {
+		s.handleSignals(ctx, quit, cancel, func(code int) { exited <- code })
+		close(done)
+	}()
+
+	quit <- syscall.SIGTERM
+	<-ctx.Done()
+	select {
+	case <-exited:
+		t.Fatal("first signal forced an exit")
+	default:
+	}
+
+	quit <- syscall.SIGTERM
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second signal did not force an exit")
+	}
+	<-done
+}
+
+func TestDrainDelayFailsReadinessButKeepsServing(t *testing.T) {
+	s, _ := newTestServer(t, Config{DrainDelay: 500 * time.Millisecond})
+
+	addr := freeAddr(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, addr) }()
+
+	get := func(path string) int {
+		t.Helper()
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	var status int
+	for range 50 {
+		if resp, err := http.Get("http://" + addr + "/readiness"); err == nil {
+			resp.Body.Close()
+			status = resp.StatusCode
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if status != http.StatusOK {
+		t.Fatalf("readiness before shutdown = %d, want %d", status, http.StatusOK)
+	}
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if status := get("/readiness"); status != http.StatusServiceUnavailable {
+		t.Errorf("readiness while draining = %d, want %d", status, http.StatusServiceUnavailable)
+	}
+	if status := get("/health"); status != http.StatusOK {
+		t.Errorf("health while draining = %d, want %d", status, http.StatusOK)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func TestRunDrainsInFlightRequests(t *testing.T) {
+	s, _ := newTestServer(t, Config{ShutdownTimeout: 5 * time.Second})
+	started := make(chan struct{})
+	s.router.Get("/test/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("finished"))
+	})
+	addr := freeAddr(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, addr) }()
+
+	type result struct {
+		status int
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		for range 50 {
+			resp, err := http.Get("http://" + addr + "/test/slow")
+			if err == nil {
+				resp.Body.Close()
+				results <- result{status: resp.StatusCode}
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		results <- result{err: errors.New("server never accepted the request")}
+	}()
+
+	<-started
+	cancel()
+
+	if res := <-results; res.err != nil || res.status != http.StatusOK {
+		t.Errorf("in-flight request = %d, %v, want it to finish with 200", res.status, res.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func TestRunGivesUpAfterShutdownTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	s, _ := newTestServer(t, Config{ShutdownTimeout: timeout})
+	s.OnShutdown("stuck job", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, freeAddr(t)) }()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Run = %v, want context.DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > timeout+time.Second {
+			t.Errorf("shutdown took %v, want about %v", elapsed, timeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the shutdown timeout")
+	}
+}
+
+func TestRunReturnsListenErrors(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background(), ln.Addr().String()) }()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "failed to serve on "+ln.Addr().String()) {
+			t.Errorf("Run on a port in use = %v, want a listen error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run on a port in use did not return")
+	}
+}
+
+func TestDrainingMarksRequestLogs(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, _ := newLoggedTestServer(t, Config{}, zap.New(core))
+
+	draining := func() any {
+		t.Helper()
+		logs.TakeAll()
+		serve(s, httptest.NewRequest(http.MethodGet, "/health", nil))
+		entries := logs.FilterMessage("HTTP request processed").All()
+		if len(entries) != 1 {
+			t.Fatalf("got %d request log entries, want 1", len(entries))
+		}
+		return entries[0].ContextMap()["draining"]
+	}
+
+	if got := draining(); got != false {
+		t.Errorf("draining before shutdown = %v, want false", got)
+	}
+	s.draining.Store(true)
+	if got := draining(); got != true {
+		t.Errorf("draining during shutdown = %v, want true", got)
+	}
+	if rec := serve(s, httptest.NewRequest(http.MethodGet, "/readiness", nil)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readiness while draining = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// timeoutWriter buffers a handler's response so it can be discarded and
+// replaced with a 503 if the handler runs past its deadline
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
+
+// streamingRoutes hold their connection open indefinitely or stream a
+// response of unbounded size, or, for pprof, for as long as the client asks,
+// so no request timeout applies to them
+var streamingRoutes = map[string]bool{
+	"/" + APIVersion + "/ws/orders":     true,
+	"/" + APIVersion + "/stream/prices": true,
+	"/" + APIVersion + "/users/export":  true,
+	"/debug/pprof/profile":              true,
+	"/debug/pprof/trace":                true,
+}
+
+// defaultRouteTimeouts are the timeouts of routes that need longer than
+// RequestTimeout; ROUTE_TIMEOUTS entries override them
+var defaultRouteTimeouts = map[string]time.Duration{
+	// Every user in a batch has its password hashed with bcrypt
+	"/" + APIVersion + "/users/batch": time.Minute,
+	// So is every imported row with a password
+	"/" + APIVersion + "/users/import": 5 * time.Minute,
+}
+
+// partialRoutes work through their input item by item and, once their
+// timeout elapses, stop and report what they completed. timeoutMiddleware
+// only sets their context deadline instead of replacing the response with a
+// 503, which would hide the items already committed.
+var partialRoutes = map[string]bool{
+	"/" + APIVersion + "/users/batch":  true,
+	"/" + APIVersion + "/users/import": true,
+}
+
+// timeoutWriteGrace is the time allowed past a route's timeout to write the
+// response
+const timeoutWriteGrace = 5 * time.Second
+
+// routeTimeout returns the timeout for the route matching r, falling back to
+// the server-wide default. Streaming routes get no timeout.
+func (s *Server) routeTimeout(r *http.Request) time.Duration {
+	pattern := s.routePattern(r)
+	if streamingRoutes[pattern] {
+		return 0
+	}
+	if d, ok := s.config.RouteTimeouts[pattern]; ok {
+		return d
+	}
+	if d, ok := defaultRouteTimeouts[pattern]; ok {
+		return d
+	}
+	return s.config.RequestTimeout
+}
+
+// validateRouteTimeouts checks that every configured route timeout refers to
+// a registered route pattern, catching typos at startup
+func (s *Server) validateRouteTimeouts() error {
+	patterns := make(map[string]bool)
+	err := chi.Walk(s.router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		patterns[route] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+
+	for pattern := range s.config.RouteTimeouts {
+		if !patterns[pattern] {
+			return fmt.Errorf("route timeout configured for unknown route %q", pattern)
+		}
+	}
+	return nil
+}
+
+// timeoutMiddleware cancels the request context once the route's timeout
+// elapses and responds with 503 if the handler hasn't finished by then.
+// Handlers pass r.Context() to the model methods so in-flight queries are
+// cancelled along with it. Routes whose timeout outlasts the server's write
+// timeout get their write deadline extended to match.
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.routeTimeout(r)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if timeout+timeoutWriteGrace > s.config.WriteTimeout {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + timeoutWriteGrace)); err != nil {
+				LoggerFromContext(r.Context()).Warn("Failed to extend write deadline", zap.Error(err))
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		if partialRoutes[s.routePattern(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan any, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			dst := w.Header()
+			for k, vv := range tw.header {
+				dst[k] = vv
+			}
+			if !tw.wroteHeader {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+
+			LoggerFromContext(r.Context()).Warn("Request timed out", zap.Duration("timeout", timeout))
+
+			writeError(w, r, http.StatusServiceUnavailable, "The request timed out")
+		}
+	})
+}
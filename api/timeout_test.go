@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// newTimeoutTestServer returns a server whose router only has the timeout
+// middleware and the given routes, each answering after delay
+func newTimeoutTestServer(cfg Config, delay time.Duration, patterns ...string) *Server {
+	s := &Server{router: chi.NewRouter(), config: cfg, logger: zap.NewNop()}
+	s.router.Use(s.timeoutMiddleware)
+	for _, pattern := range patterns {
+		s.router.Get(pattern, func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(delay):
+				w.Write([]byte("done"))
+			case <-r.Context().Done():
+			}
+		})
+	}
+	return s
+}
+
+func TestRouteTimeouts(t *testing.T) {
+	cfg := Config{
+		RequestTimeout: 20 * time.Millisecond,
+		RouteTimeouts:  map[string]time.Duration{"/slow/{id}": time.Second, "/unbounded": 0},
+	}
+	s := newTimeoutTestServer(cfg, 100*time.Millisecond, "/slow/{id}", "/fast", "/unbounded")
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/slow/1", http.StatusOK},
+		{"/fast", http.StatusServiceUnavailable},
+		{"/unbounded", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddlewareCancelsSlowHandler(t *testing.T) {
+	s := &Server{router: chi.NewRouter(), config: Config{RequestTimeout: 20 * time.Millisecond}, logger: zap.NewNop()}
+	cancelled := make(chan error, 1)
+	s.router.Use(s.timeoutMiddleware)
+	s.router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- r.Context().Err()
+		case <-time.After(time.Second):
+			cancelled <- nil
+		}
+		w.Write([]byte("too late"))
+	})
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if strings.Contains(rec.Body.String(), "too late") {
+		t.Error("response includes what the handler wrote after the timeout")
+	}
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("handler context error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStreamingRoutesHaveNoTimeout(t *testing.T) {
+	cfg := Config{
+		RequestTimeout: 20 * time.Millisecond,
+		RouteTimeouts:  map[string]time.Duration{"/" + APIVersion + "/stream/prices": 20 * time.Millisecond},
+	}
+	s := newTimeoutTestServer(cfg, 100*time.Millisecond, "/"+APIVersion+"/stream/prices")
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+APIVersion+"/stream/prices", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
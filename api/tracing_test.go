@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorderOnce sync.Once
+	spanRecorder     *tracetest.SpanRecorder
+)
+
+// recordSpans installs a global tracer provider that records every span.
+// The package tracers bind to the first provider installed, so it is shared
+// by all tests; pick out a test's spans by their trace id.
+func recordSpans() *tracetest.SpanRecorder {
+	spanRecorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+func TestTracingRequestAndQuerySpans(t *testing.T) {
+	recorder := recordSpans()
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "john")
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	parentID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/"+strconv.Itoa(user.UserID), nil)
+	req.Header.Set("traceparent", "00-"+traceID.String()+"-"+parentID.String()+"-01")
+	if rec := serve(s, req); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var server, query sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != traceID {
+			continue
+		}
+		switch span.Name() {
+		case "/v1/users/{id}":
+			server = span
+		case "users.get":
+			query = span
+		}
+	}
+	if server == nil || query == nil {
+		t.Fatalf("server span %v, query span %v, want both in the caller's trace", server, query)
+	}
+	if server.SpanKind() != trace.SpanKindServer || server.Parent().SpanID() != parentID {
+		t.Errorf("server span kind %v, parent %v, want a server span under the caller's %v", server.SpanKind(), server.Parent().SpanID(), parentID)
+	}
+	if query.SpanKind() != trace.SpanKindClient || query.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("query span kind %v, parent %v, want a client span under the server span", query.SpanKind(), query.Parent().SpanID())
+	}
+}
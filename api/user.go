@@ -1 +1,494 @@
 package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrisp986/trader-backend/apperror"
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// CreateUserRequest is the expected body for creating a user
+type CreateUserRequest struct {
+	Username string `json:"user_name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// CreateUserResponse returns the created user, including its id and
+// timestamps, so clients don't need a follow-up GET
+type CreateUserResponse struct {
+	HttpStatusCode int       `json:"http_status_code"`
+	Status         string    `json:"status"`
+	Timestamp      time.Time `json:"timestamp"`
+	User           *db.User  `json:"user"`
+}
+
+// maxUsernameLength bounds usernames accepted by createUserHandler
+const maxUsernameLength = 50
+
+// validate returns a map of field name to problem, empty when input is valid
+func (input CreateUserRequest) validate() map[string]string {
+	return validateUserFields(input.Username, input.Email)
+}
+
+// validateUserFields checks the username and email shared by create and update
+func validateUserFields(username, email string) map[string]string {
+	fields := make(map[string]string)
+
+	username = strings.TrimSpace(username)
+	switch {
+	case username == "":
+		fields["user_name"] = "must not be empty"
+	case len(username) > maxUsernameLength:
+		fields["user_name"] = fmt.Sprintf("must be at most %d characters", maxUsernameLength)
+	}
+
+	if strings.TrimSpace(email) == "" {
+		fields["email"] = "must not be empty"
+	} else if _, ok := parseEmail(email); !ok {
+		fields["email"] = "must be a valid email address"
+	}
+
+	return fields
+}
+
+// parseEmail returns the address in email. mail.ParseAddress also accepts
+// forms such as "Bob <bob@example.com>", which a user record shouldn't hold,
+// so anything other than a bare address, give or take surrounding space, is
+// rejected.
+func parseEmail(email string) (string, bool) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != strings.TrimSpace(email) {
+		return "", false
+	}
+	return addr.Address, true
+}
+
+// emailAddress returns the address in an email that passed validation
+func emailAddress(email string) string {
+	addr, _ := parseEmail(email)
+	return addr
+}
+
+// createUserHandler handles creating a new user
+func (s *Server) createUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input CreateUserRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		return
+	}
+
+	if fields := input.validate(); len(fields) > 0 {
+		s.writeAppError(w, r, apperror.Validation(fields))
+		return
+	}
+
+	user := &db.User{Username: strings.TrimSpace(input.Username), Email: emailAddress(input.Email), Password: input.Password}
+	if err := s.user.Insert(r.Context(), user); err != nil {
+		s.writeAppError(w, r, userError(err, "Failed to create user"))
+		return
+	}
+	// A user registers themselves, so they are also the actor
+	s.recordAudit(r, user.UserID, db.AuditActionCreate, "user", user.UserID, userAuditDetail(user))
+
+	response := CreateUserResponse{
+		HttpStatusCode: http.StatusCreated,
+		Status:         "New user created",
+		Timestamp:      time.Now(),
+		User:           user,
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s/users/%d", s.config.BasePath, APIVersion, user.UserID))
+	if err := writeJSON(w, http.StatusCreated, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode create user response", zap.Error(err))
+		return
+	}
+
+	LoggerFromContext(r.Context()).Debug("Create user route",
+		zap.Int("status_code", response.HttpStatusCode),
+		zap.String("status", response.Status),
+		zap.Int("user_id", user.UserID),
+	)
+}
+
+// userAuditDetail is the audit detail for a created or updated user: its
+// new username and email, never the password
+func userAuditDetail(user *db.User) map[string]string {
+	return map[string]string{"user_name": user.Username, "email": user.Email}
+}
+
+// getUserHandler returns the user identified by the {id} URL parameter. It
+// sets an ETag and answers 304 when If-None-Match already has it.
+func (s *Server) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := userIDParam(r)
+	if err != nil {
+		s.writeAppError(w, r, err)
+		return
+	}
+
+	user, err := s.user.GetByID(r.Context(), id)
+	if err != nil {
+		s.writeAppError(w, r, userError(err, "Failed to get user"))
+		return
+	}
+
+	// The ETag changes whenever the user is updated
+	etag := computeETag(strconv.Itoa(user.UserID), user.UpdatedAt.UTC().Format(time.RFC3339Nano), user.Username, user.Email)
+	if notModified(w, r, etag) {
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode user response", zap.Error(err))
+	}
+}
+
+// ListUsersResponse is a page of users plus the total count for paging
+type ListUsersResponse struct {
+	Users []*db.User `json:"users"`
+	Total int        `json:"total"`
+	// NextCursor, in responses to ?after_id=, is the after_id of the next
+	// page; it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// listUsersHandler returns a page of users selected by ?limit=, ?offset= and
+// ?sort=, or by ?after_id= and ?limit= in id order, optionally created
+// between ?created_from= and ?created_to=, or the user matching ?username=
+// exactly
+func (s *Server) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	username, err := queryFilter(r, "username")
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+	if username != "" {
+		s.findUserByUsername(w, r, username)
+		return
+	}
+
+	limit, offset, sort, err := parseListParams(r, db.UserSortColumns...)
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+
+	filter, err := userFilterParams(r)
+	if err != nil {
+		s.writeAppError(w, r, err)
+		return
+	}
+
+	// The total counts every matching user, not just those after the cursor
+	total, err := s.user.CountFiltered(r.Context(), filter)
+	if err != nil {
+		s.writeAppError(w, r, apperror.Internal("Failed to count users", err))
+		return
+	}
+
+	keyset := r.URL.Query().Has("after_id")
+	if keyset {
+		if filter.AfterID, err = afterIDParam(r, offset, sort); err != nil {
+			s.writeAppError(w, r, err)
+			return
+		}
+		sort = "id"
+	}
+
+	users, err := s.user.ListFiltered(r.Context(), filter, limit, offset, sort)
+	if err != nil {
+		s.writeAppError(w, r, apperror.Internal("Failed to list users", err))
+		return
+	}
+
+	response := ListUsersResponse{Users: users, Total: total}
+	if keyset && len(users) == limit {
+		// A full page may be the last one; look one row ahead to tell
+		next := filter
+		next.AfterID = users[len(users)-1].UserID
+		more, err := s.user.ListFiltered(r.Context(), next, 1, 0, "id")
+		if err != nil {
+			s.writeAppError(w, r, apperror.Internal("Failed to list users", err))
+			return
+		}
+		if len(more) > 0 {
+			response.NextCursor = strconv.Itoa(next.AfterID)
+		}
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode list users response", zap.Error(err))
+	}
+}
+
+// maxSearchTermLength bounds the ?q= term accepted by searchUsersHandler
+const maxSearchTermLength = 100
+
+// SearchUsersResponse is a page of users matching a search term
+type SearchUsersResponse struct {
+	Query string     `json:"query"`
+	Users []*db.User `json:"users"`
+}
+
+// searchUsersHandler returns a page of users whose username or email
+// contains ?q=, selected by ?limit= and ?offset=. It backs the admin UI, so
+// the route requires the admin token.
+func (s *Server) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	term := strings.TrimSpace(r.URL.Query().Get("q"))
+	if term == "" {
+		s.writeAppError(w, r, apperror.BadRequest("q must not be empty"))
+		return
+	}
+	if len(term) > maxSearchTermLength {
+		s.writeAppError(w, r, apperror.BadRequest(fmt.Sprintf("q must be at most %d characters", maxSearchTermLength)))
+		return
+	}
+
+	limit, offset, _, err := parseListParams(r)
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+
+	users, err := s.user.Search(r.Context(), term, limit, offset)
+	if err != nil {
+		s.writeAppError(w, r, apperror.Internal("Failed to search users", err))
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, SearchUsersResponse{Query: term, Users: users}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode search users response", zap.Error(err))
+	}
+}
+
+// userFilterParams reads the optional ?created_from= and ?created_to= RFC
+// 3339 bounds of a user listing
+func userFilterParams(r *http.Request) (db.UserFilter, error) {
+	from, err := queryTime(r, "created_from")
+	if err != nil {
+		return db.UserFilter{}, apperror.BadRequest(err.Error())
+	}
+	to, err := queryTime(r, "created_to")
+	if err != nil {
+		return db.UserFilter{}, apperror.BadRequest(err.Error())
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return db.UserFilter{}, apperror.BadRequest("created_from must not be after created_to")
+	}
+	return db.UserFilter{CreatedFrom: from, CreatedTo: to}, nil
+}
+
+// afterIDParam reads the ?after_id= cursor, a non-negative user id, where 0
+// starts from the first page. Cursor pages are always in id order, so it
+// can't be combined with an offset or another sort.
+func afterIDParam(r *http.Request, offset int, sort string) (int, error) {
+	afterID, err := queryInt(r, "after_id", 0)
+	if err != nil {
+		return 0, apperror.BadRequest(err.Error())
+	}
+	if afterID < 0 {
+		return 0, apperror.BadRequest("after_id must be a non-negative integer")
+	}
+	if offset != 0 {
+		return 0, apperror.BadRequest("after_id cannot be combined with offset")
+	}
+	if sort != "" && sort != "id" {
+		return 0, apperror.BadRequest("after_id pages are ordered by id and cannot be combined with sort")
+	}
+	return afterID, nil
+}
+
+// findUserByUsername responds with a list holding the matching user, or an
+// empty list when there is none
+func (s *Server) findUserByUsername(w http.ResponseWriter, r *http.Request, username string) {
+	response := ListUsersResponse{Users: []*db.User{}}
+
+	user, err := s.user.GetByUsername(r.Context(), username)
+	switch {
+	case err == nil:
+		response.Users = append(response.Users, user)
+		response.Total = 1
+	case !errors.Is(err, db.ErrNoRecord):
+		s.writeAppError(w, r, apperror.Internal("Failed to list users", err))
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode list users response", zap.Error(err))
+	}
+}
+
+// UpdateUserRequest is the expected body for updating a user
+type UpdateUserRequest struct {
+	Username string `json:"user_name"`
+	Email    string `json:"email"`
+}
+
+// userIDParam parses the {id} URL parameter as a user id
+func userIDParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		return 0, apperror.BadRequest("User id must be a positive integer")
+	}
+	return id, nil
+}
+
+// authorizedUserID parses the {id} URL parameter and checks it belongs to the
+// authenticated user
+func authorizedUserID(r *http.Request) (int, error) {
+	id, err := userIDParam(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if authID, ok := GetUserID(r.Context()); !ok || authID != id {
+		return 0, apperror.Forbidden("Users may only access their own account")
+	}
+	return id, nil
+}
+
+// userError translates a user model error into an apperror, using failure as
+// the message for unexpected errors
+func userError(err error, failure string) error {
+	switch {
+	case errors.Is(err, db.ErrNoRecord):
+		return apperror.NotFound("User not found")
+	case errors.Is(err, db.ErrDuplicateEmail):
+		return apperror.Conflict("Email is already in use")
+	case errors.Is(err, db.ErrDuplicateUsername):
+		return apperror.Conflict("Username is already in use")
+	case errors.Is(err, db.ErrReferenced):
+		return apperror.Conflict("User still has dependent records")
+	case errors.Is(err, db.ErrInvalidCredentials):
+		return apperror.Unauthorized("Invalid email or password")
+	case errors.Is(err, db.ErrInvalidTimestamp):
+		return apperror.Validation(map[string]string{"created_at": "must be between 1970 and now"})
+	}
+	if _, ok := db.AsConstraintError(err); ok {
+		// writeAppError reports these field by field
+		return err
+	}
+	return apperror.Internal(failure, err)
+}
+
+// updateUserHandler changes the username and email of the authenticated user
+func (s *Server) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := authorizedUserID(r)
+	if err != nil {
+		s.writeAppError(w, r, err)
+		return
+	}
+
+	var input UpdateUserRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		return
+	}
+
+	if fields := validateUserFields(input.Username, input.Email); len(fields) > 0 {
+		s.writeAppError(w, r, apperror.Validation(fields))
+		return
+	}
+
+	user := &db.User{UserID: id, Username: strings.TrimSpace(input.Username), Email: emailAddress(input.Email)}
+	if err := s.user.Update(r.Context(), user); err != nil {
+		s.writeAppError(w, r, userError(err, "Failed to update user"))
+		return
+	}
+	s.recordAudit(r, id, db.AuditActionUpdate, "user", id, userAuditDetail(user))
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode user response", zap.Error(err))
+	}
+}
+
+// deleteUserHandler soft-deletes the authenticated user's account; an admin
+// can restore it
+func (s *Server) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := authorizedUserID(r)
+	if err != nil {
+		s.writeAppError(w, r, err)
+		return
+	}
+
+	if err := s.user.Delete(r.Context(), id); err != nil {
+		s.writeAppError(w, r, userError(err, "Failed to delete user"))
+		return
+	}
+	s.recordAudit(r, id, db.AuditActionDelete, "user", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restoreUserHandler brings back a soft-deleted user; it is an admin
+// operation since deleted users can't authenticate
+func (s *Server) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := userIDParam(r)
+	if err != nil {
+		s.writeAppError(w, r, err)
+		return
+	}
+
+	if err := s.user.Restore(r.Context(), id); err != nil {
+		if errors.Is(err, db.ErrNoRecord) {
+			s.writeAppError(w, r, apperror.NotFound("No deleted user with that id"))
+			return
+		}
+		s.writeAppError(w, r, userError(err, "Failed to restore user"))
+		return
+	}
+	s.recordAudit(r, 0, db.AuditActionRestore, "user", id, nil)
+
+	user, err := s.user.GetByID(r.Context(), id)
+	if err != nil {
+		s.writeAppError(w, r, userError(err, "Failed to get user"))
+		return
+	}
+
+	if err := writeJSON(w, http.StatusOK, user); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode user response", zap.Error(err))
+	}
+}
+
+// LoginRequest is the expected body for logging in
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// LoginResponse carries the bearer token issued on a successful login
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// loginHandler exchanges an email and password for a bearer token
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var input LoginRequest
+	if err := decodeJSON(w, r, &input); err != nil {
+		return
+	}
+
+	id, err := s.user.Authenticate(r.Context(), input.Email, input.Password)
+	if err != nil {
+		s.writeAppError(w, r, userError(err, "Failed to log in"))
+		return
+	}
+
+	token, err := s.IssueToken(id, s.config.JWTTTL)
+	if err != nil {
+		s.writeAppError(w, r, apperror.Internal("Failed to log in", err))
+		return
+	}
+
+	response := LoginResponse{Token: token, ExpiresAt: time.Now().Add(s.config.JWTTTL)}
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode login response", zap.Error(err))
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chrisp986/trader-backend/apperror"
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// maxUserBatch caps the users in one batch request. Each password is hashed
+// with bcrypt, which is why the route has a one-minute default timeout.
+const maxUserBatch = 50
+
+// rolledBackMessage explains batch items that were undone, or never
+// attempted, because another item in an atomic batch failed
+const rolledBackMessage = "Not created: another user in the atomic batch failed"
+
+// timedOutMessage explains items that weren't attempted because the
+// request's timeout elapsed first
+const timedOutMessage = "Not created: the request timed out first"
+
+// BatchUserResult reports the outcome for one user in a batch. Status is the
+// HTTP status the item would have had as a single create request.
+type BatchUserResult struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	ID     int               `json:"id,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// BatchCreateUsersResponse lists a result for every submitted user, in
+// request order
+type BatchCreateUsersResponse struct {
+	Atomic  bool `json:"atomic"`
+	Created int  `json:"created"`
+	Failed  int  `json:"failed"`
+	// TimedOut is set when the timeout cut the batch short; Results shows
+	// which users were created before it did
+	TimedOut bool              `json:"timed_out,omitempty"`
+	Results  []BatchUserResult `json:"results"`
+}
+
+// createUsersBatchHandler creates every user in a JSON array and responds
+// 207 Multi-Status with a result per item. By default each user is inserted
+// on its own, so one failure doesn't affect the others. With ?atomic=true the
+// users are inserted in one transaction and nothing is created unless all of
+// them are; the items that didn't fail then report 424 Failed Dependency.
+// Once the route's timeout elapses the remaining users are reported with 503
+// instead of being attempted.
+func (s *Server) createUsersBatchHandler(w http.ResponseWriter, r *http.Request) {
+	atomic, err := queryBool(r, "atomic", false)
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+
+	var inputs []CreateUserRequest
+	if err := decodeJSON(w, r, &inputs); err != nil {
+		return
+	}
+	if len(inputs) == 0 {
+		s.writeAppError(w, r, apperror.BadRequest("Request body must contain at least one user"))
+		return
+	}
+	if len(inputs) > maxUserBatch {
+		s.writeAppError(w, r, apperror.BadRequest(fmt.Sprintf("A batch may contain at most %d users", maxUserBatch)))
+		return
+	}
+
+	// Validate everything first so an atomic batch fails before touching the
+	// database
+	results := make([]BatchUserResult, len(inputs))
+	valid := true
+	for i, input := range inputs {
+		results[i] = BatchUserResult{Index: i}
+		if fields := input.validate(); len(fields) > 0 {
+			s.setBatchError(r.Context(), &results[i], apperror.Validation(fields))
+			valid = false
+		}
+	}
+
+	users := make([]*db.User, len(inputs))
+	for i, input := range inputs {
+		users[i] = &db.User{Username: strings.TrimSpace(input.Username), Email: emailAddress(input.Email), Password: input.Password}
+	}
+	// created records that user i was created
+	created := func(i int) {
+		results[i].Status = http.StatusCreated
+		results[i].ID = users[i].UserID
+	}
+
+	switch {
+	case atomic && !valid:
+		markRolledBack(results)
+	case atomic:
+		failed, err := s.user.InsertAll(r.Context(), users)
+		if err != nil {
+			if failed >= 0 {
+				s.setBatchError(r.Context(), &results[failed], err)
+			} else {
+				LoggerFromContext(r.Context()).Error("Failed to create atomic user batch", zap.Error(err))
+			}
+			markRolledBack(results)
+			break
+		}
+		for i := range users {
+			created(i)
+		}
+	default:
+		for i := range inputs {
+			if results[i].Status != 0 {
+				continue
+			}
+			if r.Context().Err() != nil {
+				results[i].Status = http.StatusServiceUnavailable
+				results[i].Error = timedOutMessage
+				continue
+			}
+			if err := s.user.Insert(r.Context(), users[i]); err != nil {
+				s.setBatchError(r.Context(), &results[i], err)
+				continue
+			}
+			created(i)
+		}
+	}
+
+	response := BatchCreateUsersResponse{Atomic: atomic, TimedOut: r.Context().Err() != nil, Results: results}
+	for i, result := range results {
+		if result.Status == http.StatusCreated {
+			response.Created++
+			s.recordAudit(r, result.ID, db.AuditActionCreate, "user", result.ID, userAuditDetail(users[i]))
+		} else {
+			response.Failed++
+		}
+	}
+
+	if err := writeJSON(w, http.StatusMultiStatus, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode batch create users response", zap.Error(err))
+		return
+	}
+
+	LoggerFromContext(r.Context()).Info("Batch user creation completed",
+		zap.Bool("atomic", atomic),
+		zap.Int("created", response.Created),
+		zap.Int("failed", response.Failed),
+		zap.Bool("timed_out", response.TimedOut),
+	)
+}
+
+// setBatchError records err on result the way writeAppError would report it
+// for a single create request
+func (s *Server) setBatchError(ctx context.Context, result *BatchUserResult, err error) {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		result.Status = http.StatusServiceUnavailable
+		result.Error = timedOutMessage
+		return
+	}
+
+	// Model errors still need mapping; validation errors already are
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) {
+		err = userError(err, "Failed to create user")
+	}
+
+	if cerr, ok := db.AsConstraintError(err); ok {
+		result.Status = http.StatusUnprocessableEntity
+		if cerr.Kind == db.ConstraintDuplicate {
+			result.Status = http.StatusConflict
+		}
+		result.Error = cerr.Error()
+		return
+	}
+
+	if !errors.As(err, &appErr) {
+		appErr = apperror.Internal("Failed to create user", err)
+	}
+	if appErr.Status >= http.StatusInternalServerError {
+		LoggerFromContext(ctx).Error(appErr.Message, zap.Int("index", result.Index), zap.Error(appErr.Err))
+	}
+	result.Status = appErr.Status
+	result.Error = appErr.Message
+	result.Fields = appErr.Fields
+}
+
+// markRolledBack reports 424 for every item of a failed atomic batch that
+// has no error of its own
+func markRolledBack(results []BatchUserResult) {
+	for i := range results {
+		if results[i].Status == 0 || results[i].Status == http.StatusCreated {
+			results[i] = BatchUserResult{
+				Index:  results[i].Index,
+				Status: http.StatusFailedDependency,
+				Error:  rolledBackMessage,
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// batchUsers is a batch of three users; the second has ann's email when
+// ann already exists
+const batchUsers = `[
+	{"user_name":"bob","email":"bob@example.com","password":"secret-password"},
+	{"user_name":"ann2","email":"ann@example.com","password":"secret-password"},
+	{"user_name":"cid","email":"cid@example.com","password":"secret-password"}
+]`
+
+func TestCreateUsersBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		existing bool
+		statuses []int
+		users    int
+	}{
+		{"all succeed", "/v1/users/batch", false, []int{201, 201, 201}, 3},
+		{"partial failure", "/v1/users/batch", true, []int{201, 409, 201}, 3},
+		{"atomic rollback", "/v1/users/batch?atomic=true", true, []int{424, 409, 424}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestServer(t, Config{})
+			if tt.existing {
+				insertTestUser(t, store, "ann")
+			}
+
+			rec := serve(s, jsonRequest(http.MethodPost, tt.target, batchUsers))
+			if rec.Code != http.StatusMultiStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusMultiStatus, rec.Body)
+			}
+			var resp BatchCreateUsersResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Results) != len(tt.statuses) {
+				t.Fatalf("got %d results, want %d", len(resp.Results), len(tt.statuses))
+			}
+			created := 0
+			for i, result := range resp.Results {
+				if result.Index != i || result.Status != tt.statuses[i] {
+					t.Errorf("result %d = %+v, want status %d", i, result, tt.statuses[i])
+				}
+				if result.Status == http.StatusCreated {
+					created++
+					if result.ID == 0 {
+						t.Errorf("result %d has no id", i)
+					}
+				} else if result.Error == "" {
+					t.Errorf("result %d has no error", i)
+				}
+			}
+			if resp.Created != created || resp.Failed != len(tt.statuses)-created {
+				t.Errorf("created, failed = %d, %d, want %d, %d", resp.Created, resp.Failed, created, len(tt.statuses)-created)
+			}
+
+			count, err := store.User.Count(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.users {
+				t.Errorf("stored %d users, want %d", count, tt.users)
+			}
+		})
+	}
+}
+
+func TestCreateUsersBatchRejectsEmptyBatch(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	if rec := serve(s, jsonRequest(http.MethodPost, "/v1/users/batch", `[]`)); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
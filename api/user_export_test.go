@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	db "github.com/chrisp986/trader-backend/database"
+)
+
+func TestExportUsersCSV(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	// More than one batch, with a name a spreadsheet would run as a formula
+	users := []*db.User{insertTestUser(t, store, "=cmd")}
+	for i := range db.MaxListLimit + 1 {
+		users = append(users, insertTestUser(t, store, "user"+strconv.Itoa(i+1)))
+	}
+
+	rec := serve(s, adminRequest(http.MethodGet, "/v1/users/export", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("response is not CSV: %v", err)
+	}
+	if len(records) != len(users)+1 {
+		t.Fatalf("got %d records, want a header and %d users", len(records), len(users))
+	}
+	if got := records[0]; len(got) != 4 || got[0] != "id" || got[3] != "created_at" {
+		t.Errorf("header = %v, want %v", got, userCSVHeader)
+	}
+	if got := records[1]; got[0] != strconv.Itoa(users[0].UserID) || got[1] != "'=cmd" || got[2] != "'=cmd@example.com" {
+		t.Errorf("first row = %v, want the formula escaped", got)
+	}
+	last := records[len(records)-1]
+	if last[0] != strconv.Itoa(users[len(users)-1].UserID) || last[1] != users[len(users)-1].Username {
+		t.Errorf("last row = %v, want %s", last, users[len(users)-1].Username)
+	}
+}
+
+func TestExportUsersCSVErrors(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	if rec := serve(s, adminRequest(http.MethodGet, "/v1/users/export?created_from=yesterday", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid created_from = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users/export", nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without admin token = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
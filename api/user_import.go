@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chrisp986/trader-backend/apperror"
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// maxUserImportRows caps the data rows in one import. Rows with a password
+// are hashed with bcrypt; the cap keeps a full import well inside the
+// route's five-minute default timeout.
+const maxUserImportRows = 500
+
+// Import row outcomes
+const (
+	importCreated = "created"
+	importSkipped = "skipped"
+	importError   = "error"
+)
+
+// ImportRowResult describes what happened to a CSV row. Row is the line's
+// record number in the file, counting the header as 1.
+type ImportRowResult struct {
+	Row int `json:"row"`
+	// Outcome is "created", "skipped" for users that already exist, or
+	// "error" for rows that are invalid, failed to insert or weren't
+	// attempted before the timeout
+	Outcome string            `json:"outcome"`
+	Status  int               `json:"status"`
+	ID      int               `json:"id,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// ImportUsersResponse summarizes an import and details every row
+type ImportUsersResponse struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+	Errored int `json:"errored"`
+	// TimedOut is set when the timeout cut the import short; Rows shows
+	// which users were created before it did
+	TimedOut bool              `json:"timed_out,omitempty"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// userCSVColumns maps the accepted header names to userImportRow fields
+var userCSVColumns = map[string]string{
+	"username":   "user_name",
+	"user_name":  "user_name",
+	"email":      "email",
+	"password":   "password",
+	"created_at": "created_at",
+}
+
+// userImportRow is one row of a users CSV
+type userImportRow struct {
+	CreateUserRequest
+	// CreatedAt is the RFC 3339 creation time to keep, if the file has one
+	CreatedAt string
+}
+
+// createdAt parses the row's creation time; the zero time means none was given
+func (row userImportRow) createdAt() (time.Time, error) {
+	if row.CreatedAt == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, row.CreatedAt)
+}
+
+// readUserCSV reads a users CSV, either the raw body or the "file" part of a
+// multipart upload. The header must name username (or user_name) and email
+// columns and may name password and created_at; other columns, such as the
+// id in an export, are ignored. It returns an error suitable for a 400 if
+// the file is malformed.
+func readUserCSV(r *http.Request) ([]userImportRow, error) {
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New(`multipart upload must include the CSV as a "file" part`)
+		}
+		defer file.Close()
+		body = file
+	}
+
+	cr := csv.NewReader(body)
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, errors.New("CSV file is too large")
+		}
+		return nil, fmt.Errorf("CSV is malformed: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV must have a header row")
+	}
+	if len(records)-1 > maxUserImportRows {
+		return nil, fmt.Errorf("CSV may contain at most %d users", maxUserImportRows)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		// Tolerate the byte order mark some spreadsheet tools write
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := userCSVColumns[name]; ok {
+			if _, dup := columns[field]; dup {
+				return nil, fmt.Errorf("CSV header names %s more than once", field)
+			}
+			columns[field] = i
+		}
+	}
+	for _, required := range []string{"user_name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header must include a %s column", strings.ReplaceAll(required, "_", ""))
+		}
+	}
+
+	get := func(record []string, field string) string {
+		if i, ok := columns[field]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	rows := make([]userImportRow, len(records)-1)
+	for i, record := range records[1:] {
+		rows[i] = userImportRow{
+			CreateUserRequest: CreateUserRequest{
+				Username: get(record, "user_name"),
+				Email:    get(record, "email"),
+				Password: get(record, "password"),
+			},
+			CreatedAt: get(record, "created_at"),
+		}
+	}
+	return rows, nil
+}
+
+// importUsersHandler creates users from an uploaded CSV. The whole file is
+// parsed first, so a malformed file is rejected with 400 before anything is
+// inserted. Rows are then validated and inserted one by one: users whose
+// email or username already exists are skipped, and invalid rows are
+// reported without affecting the rest. A created_at column, as written by
+// the export, keeps the users' original creation times. Once the route's
+// timeout elapses the remaining rows are reported with 503 instead of being
+// attempted.
+func (s *Server) importUsersHandler(w http.ResponseWriter, r *http.Request) {
+	inputs, err := readUserCSV(r)
+	if err != nil {
+		s.writeAppError(w, r, apperror.BadRequest(err.Error()))
+		return
+	}
+
+	response := ImportUsersResponse{Rows: []ImportRowResult{}}
+	for i, input := range inputs {
+		// Record numbers start at 1 for the header
+		result := BatchUserResult{Index: i + 2}
+
+		if r.Context().Err() != nil {
+			response.TimedOut = true
+			response.Errored++
+			response.Rows = append(response.Rows, ImportRowResult{
+				Row:     result.Index,
+				Outcome: importError,
+				Status:  http.StatusServiceUnavailable,
+				Error:   timedOutMessage,
+			})
+			continue
+		}
+
+		fields := input.validate()
+		createdAt, err := input.createdAt()
+		if err != nil {
+			fields["created_at"] = "must be an RFC 3339 timestamp, e.g. 2021-03-04T05:06:07Z"
+		}
+
+		if len(fields) > 0 {
+			s.setBatchError(r.Context(), &result, apperror.Validation(fields))
+		} else {
+			user := &db.User{Username: input.Username, Email: emailAddress(input.Email), Password: input.Password, CreatedAt: createdAt}
+			if err := s.user.InsertWithTimestamps(r.Context(), user); err != nil {
+				s.setBatchError(r.Context(), &result, err)
+			} else {
+				response.Created++
+				response.Rows = append(response.Rows, ImportRowResult{
+					Row:     result.Index,
+					Outcome: importCreated,
+					Status:  http.StatusCreated,
+					ID:      user.UserID,
+				})
+				s.recordAudit(r, user.UserID, db.AuditActionCreate, "user", user.UserID, userAuditDetail(user))
+				continue
+			}
+		}
+
+		row := ImportRowResult{Row: result.Index, Outcome: importError, Status: result.Status, Error: result.Error, Fields: result.Fields}
+		switch result.Status {
+		case http.StatusConflict:
+			row.Outcome = importSkipped
+			response.Skipped++
+		case http.StatusServiceUnavailable:
+			response.TimedOut = true
+			response.Errored++
+		default:
+			response.Errored++
+		}
+		response.Rows = append(response.Rows, row)
+	}
+
+	if err := writeJSON(w, http.StatusOK, response); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to encode import users response", zap.Error(err))
+		return
+	}
+
+	LoggerFromContext(r.Context()).Info("Users imported",
+		zap.Int("created", response.Created),
+		zap.Int("skipped", response.Skipped),
+		zap.Int("errored", response.Errored),
+		zap.Bool("timed_out", response.TimedOut),
+	)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// importRequest returns an admin request uploading csv as the raw body
+func importRequest(csv string) *http.Request {
+	req := adminRequest(http.MethodPost, "/v1/users/import", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	return req
+}
+
+// decodeImport decodes an import response, failing unless it is a 200
+func decodeImport(t *testing.T, s *Server, req *http.Request) ImportUsersResponse {
+	t.Helper()
+
+	rec := serve(s, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp ImportUsersResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestImportUsersCSV(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+
+	// Columns in any order, with an ignored id column as in an export
+	resp := decodeImport(t, s, importRequest(
+		"id,email,username,created_at\n"+
+			"7,ann@example.com,ann,2021-03-04T05:06:07Z\n"+
+			"8,bob@example.com,bob,\n"))
+	if resp.Created != 2 || resp.Skipped != 0 || resp.Errored != 0 || len(resp.Rows) != 2 {
+		t.Fatalf("response = %+v, want 2 created", resp)
+	}
+	if resp.Rows[0].Row != 2 || resp.Rows[0].Outcome != importCreated || resp.Rows[0].ID == 0 {
+		t.Errorf("first row = %+v, want row 2 created", resp.Rows[0])
+	}
+
+	ann, err := store.User.GetByID(context.Background(), resp.Rows[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC); !ann.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v kept", ann.CreatedAt, want)
+	}
+}
+
+func TestImportUsersCSVReportsRows(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	insertTestUser(t, store, "taken")
+
+	resp := decodeImport(t, s, importRequest(
+		"username,email\n"+
+			"ann,ann@example.com\n"+
+			"bob,not-an-email\n"+
+			"other,taken@example.com\n"+
+			"cid,cid@example.com\n"))
+	if resp.Created != 2 || resp.Skipped != 1 || resp.Errored != 1 {
+		t.Fatalf("response = %+v, want 2 created, 1 skipped, 1 errored", resp)
+	}
+	invalid := resp.Rows[1]
+	if invalid.Row != 3 || invalid.Outcome != importError || invalid.Status != http.StatusUnprocessableEntity || invalid.Fields["email"] == "" {
+		t.Errorf("invalid row = %+v, want row 3 with an email error", invalid)
+	}
+	if skipped := resp.Rows[2]; skipped.Row != 4 || skipped.Outcome != importSkipped {
+		t.Errorf("duplicate row = %+v, want row 4 skipped", skipped)
+	}
+}
+
+func TestImportUsersCSVMultipart(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "users.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("username,email\nann,ann@example.com\n"))
+	mw.Close()
+
+	req := adminRequest(http.MethodPost, "/v1/users/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if resp := decodeImport(t, s, req); resp.Created != 1 {
+		t.Errorf("response = %+v, want 1 created", resp)
+	}
+}
+
+func TestImportUsersCSVRejectsMalformedFile(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"unbalanced quote", "username,email\n\"ann,ann@example.com\n"},
+		{"ragged rows", "username,email\nann,ann@example.com,extra\n"},
+		{"missing email column", "username,password\nann,secret-password\n"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestServer(t, Config{})
+
+			rec := serve(s, importRequest(tt.csv))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+			if count, err := store.User.Count(context.Background()); err != nil || count != 0 {
+				t.Errorf("Count = %d, %v, want nothing inserted", count, err)
+			}
+		})
+	}
+}
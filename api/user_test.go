@@ -0,0 +1,343 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+)
+
+// jsonRequest returns a request with body as its JSON payload
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestCreateUserReturnsCreatedUser(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user",
+		`{"user_name":"john","email":"John@Example.com","password":"secret-password"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "password") || strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("response exposes the password: %s", rec.Body)
+	}
+
+	var resp struct {
+		User struct {
+			UserID    int       `json:"user_id"`
+			Username  string    `json:"user_name"`
+			Email     string    `json:"email"`
+			CreatedAt time.Time `json:"created_at"`
+			UpdatedAt time.Time `json:"updated_at"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	user := resp.User
+	if user.UserID == 0 || user.Username != "john" || user.Email != "john@example.com" {
+		t.Errorf("created user = %+v", user)
+	}
+	if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+		t.Errorf("created user timestamps = %v, %v, want both set", user.CreatedAt, user.UpdatedAt)
+	}
+	if want := "/v1/users/" + strconv.Itoa(user.UserID); rec.Header().Get("Location") != want {
+		t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), want)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	for _, name := range []string{"alice", "bob", "carol"} {
+		insertTestUser(t, store, name)
+	}
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users?limit=2&offset=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp ListUsersResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Total != 3 {
+		t.Errorf("total = %d, want 3", resp.Total)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Username != "carol" {
+		t.Errorf("users = %+v, want just carol", resp.Users)
+	}
+}
+
+func TestCreateUserStatusCodes(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	insertTestUser(t, store, "taken")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"created", `{"user_name":"john","email":"john@example.com"}`, http.StatusCreated},
+		{"duplicate email", `{"user_name":"other","email":"Taken@example.com"}`, http.StatusConflict},
+		{"duplicate username", `{"user_name":"taken","email":"other@example.com"}`, http.StatusConflict},
+		{"invalid email", `{"user_name":"jane","email":"not-an-email"}`, http.StatusUnprocessableEntity},
+		{"missing username", `{"email":"jane@example.com"}`, http.StatusUnprocessableEntity},
+		{"malformed JSON", `{"user_name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user", tt.body))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "john")
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"existing", "/v1/users/" + strconv.Itoa(user.UserID), http.StatusOK},
+		{"non-numeric id", "/v1/users/abc", http.StatusBadRequest},
+		{"missing", "/v1/users/" + strconv.Itoa(user.UserID+1), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+
+	rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users/"+strconv.Itoa(user.UserID), nil))
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got["user_name"] != "john" || got["email"] != "john@example.com" {
+		t.Errorf("user = %v", got)
+	}
+	for _, field := range []string{"created_at", "updated_at"} {
+		value, _ := got[field].(string)
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			t.Errorf("%s = %q, want an RFC3339 timestamp", field, value)
+		}
+	}
+}
+
+func TestListUsersRejectsInjectionFilters(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	insertTestUser(t, store, "alice")
+
+	for _, username := range []string{"' OR '1'='1", "alice'; DROP TABLE users; --", "alice/*", `alice\`} {
+		t.Run(username, func(t *testing.T) {
+			rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users?username="+url.QueryEscape(username), nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	if count, err := store.User.Count(context.Background()); err != nil || count != 1 {
+		t.Errorf("Count = %d, %v, want the user kept", count, err)
+	}
+}
+
+func TestCreateUserAllowsQuotesInUsername(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+
+	rec := serve(s, jsonRequest(http.MethodPost, "/v1/create_user", `{"user_name":"O'Brien","email":"obrien@example.com","password":"secret-password"}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if _, err := store.User.GetByUsername(context.Background(), "O'Brien"); err != nil {
+		t.Errorf("GetByUsername = %v, want the user stored as given", err)
+	}
+}
+
+func TestDeleteAndRestoreUser(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "john")
+	target := "/v1/users/" + strconv.Itoa(user.UserID)
+
+	rec := serve(s, authorize(t, s, httptest.NewRequest(http.MethodDelete, target, nil), user.UserID))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body)
+	}
+	if rec := serve(s, httptest.NewRequest(http.MethodGet, target, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	restore := "/admin/users/" + strconv.Itoa(user.UserID) + "/restore"
+	if rec := serve(s, adminRequest(http.MethodPost, restore, nil)); rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if rec := serve(s, httptest.NewRequest(http.MethodGet, target, nil)); rec.Code != http.StatusOK {
+		t.Errorf("get after restore = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(s, adminRequest(http.MethodPost, restore, nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("second restore = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	insertTestUser(t, store, "johnny")
+	insertTestUser(t, store, "alice")
+
+	tests := []struct {
+		query  string
+		status int
+		users  int
+	}{
+		{"q=john", http.StatusOK, 1},
+		{"q=li", http.StatusOK, 1},
+		{"q=%25", http.StatusOK, 0},
+		{"q=", http.StatusBadRequest, 0},
+		{"q=+", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := serve(s, adminRequest(http.MethodGet, "/v1/users/search?"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp SearchUsersResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Users) != tt.users {
+				t.Errorf("got %d users, want %d", len(resp.Users), tt.users)
+			}
+		})
+	}
+}
+
+func TestListUsersCreatedRange(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	for i, created := range []string{"2024-01-15T12:00:00Z", "2024-02-15T12:00:00Z", "2024-03-15T12:00:00Z"} {
+		at, _ := time.Parse(time.RFC3339, created)
+		user := &db.User{Username: "user" + strconv.Itoa(i+1), Email: "user" + strconv.Itoa(i+1) + "@example.com", CreatedAt: at}
+		if err := store.User.InsertWithTimestamps(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		total  int
+	}{
+		{"bounded", "created_from=2024-02-01T00:00:00Z&created_to=2024-03-31T00:00:00Z", http.StatusOK, 2},
+		{"from only", "created_from=2024-03-01T00:00:00%2B02:00", http.StatusOK, 1},
+		{"not RFC 3339", "created_from=2024-02-01", http.StatusBadRequest, 0},
+		{"reversed", "created_from=2024-03-01T00:00:00Z&created_to=2024-02-01T00:00:00Z", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users?"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp ListUsersResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Total != tt.total || len(resp.Users) != tt.total {
+				t.Errorf("got %d users, total %d, want %d", len(resp.Users), resp.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestClosedDatabaseResponds503(t *testing.T) {
+	s, store := newTestServer(t, Config{})
+	user := insertTestUser(t, store, "john")
+	if err := s.dbManager.(*db.DatabaseManager).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/users/"+strconv.Itoa(user.UserID), nil),
+		httptest.NewRequest(http.MethodGet, "/v1/users", nil),
+		jsonRequest(http.MethodPost, "/v1/create_user", `{"user_name":"jane","email":"jane@example.com"}`),
+	} {
+		if rec := serve(s, req); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s = %d, want %d: %s", req.Method, req.URL, rec.Code, http.StatusServiceUnavailable, rec.Body)
+		}
+	}
+}
+
+func TestListUsersCursorPages(t *testing.T) {
+	for _, n := range []int{7, 6} {
+		t.Run(strconv.Itoa(n)+" users", func(t *testing.T) {
+			s, store := newTestServer(t, Config{})
+			var want []string
+			for i := range n {
+				want = append(want, insertTestUser(t, store, "user"+strconv.Itoa(i+1)).Username)
+			}
+
+			var seen []string
+			cursor := "0"
+			for pages := 0; cursor != ""; pages++ {
+				if pages > n {
+					t.Fatal("next_cursor never ran out")
+				}
+				rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users?limit=3&after_id="+cursor, nil))
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+				}
+				var resp ListUsersResponse
+				if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Total != n {
+					t.Errorf("total = %d, want %d", resp.Total, n)
+				}
+				for _, user := range resp.Users {
+					seen = append(seen, user.Username)
+				}
+				cursor = resp.NextCursor
+			}
+
+			if strings.Join(seen, ",") != strings.Join(want, ",") {
+				t.Errorf("pages = %v, want %v with none skipped or repeated", seen, want)
+			}
+		})
+	}
+}
+
+func TestListUsersCursorErrors(t *testing.T) {
+	s, _ := newTestServer(t, Config{})
+
+	for _, query := range []string{"after_id=-1", "after_id=abc", "after_id=0&offset=3", "after_id=0&sort=username"} {
+		if rec := serve(s, httptest.NewRequest(http.MethodGet, "/v1/users?"+query, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// wsWriteWait bounds each write to a WebSocket client
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long a client may stay silent before it is
+	// considered gone; pings are sent well within it
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	// wsMaxMessageSize bounds client messages, which the stream ignores
+	wsMaxMessageSize = 512
+)
+
+// checkWebSocketOrigin accepts non-browser clients, same-origin pages and
+// the origins allowed by CORS
+func (s *Server) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if slices.Contains(s.config.CORSAllowedOrigins, origin) || slices.Contains(s.config.CORSAllowedOrigins, "*") {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// orderUpdatesHandler upgrades to a WebSocket and streams an OrderEvent
+// whenever one of the authenticated user's orders changes status
+func (s *Server) orderUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserID(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, "A bearer token is required")
+		return
+	}
+
+	// The hijacked connection keeps the server's write deadline, which would
+	// cut the stream off; writeOrderUpdates sets its own per message
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to clear write deadline for order stream", zap.Error(err))
+		writeError(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkWebSocketOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already answered with an HTTP error
+		LoggerFromContext(r.Context()).Debug("WebSocket upgrade failed", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	sub, ok := s.hub.subscribe(userID)
+	if !ok {
+		closeWebSocket(conn, websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+
+	go readWebSocket(conn, func() { s.hub.unsubscribe(sub) })
+
+	LoggerFromContext(r.Context()).Info("Order stream connected", zap.Int("user_id", userID))
+	writeOrderUpdates(conn, sub)
+	LoggerFromContext(r.Context()).Info("Order stream disconnected", zap.Int("user_id", userID))
+}
+
+// readWebSocket discards client messages, keeping the read deadline fresh
+// from pongs, and calls onClose once the client goes away
+func readWebSocket(conn *websocket.Conn, onClose func()) {
+	defer onClose()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeOrderUpdates sends the subscriber's messages and periodic pings until
+// the hub drops the subscriber or a write fails
+func writeOrderUpdates(conn *websocket.Conn, sub *subscriber) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.send:
+			if !ok {
+				closeWebSocket(conn, websocket.CloseGoingAway, "")
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeWebSocket sends a close frame; the connection itself is closed by
+// the caller
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+}
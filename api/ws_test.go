@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newHubTestServer returns a test server whose order changes are published
+// to a running hub
+func newHubTestServer(t *testing.T, logger *zap.Logger) (*Server, *db.SQLStore) {
+	t.Helper()
+
+	hub := NewHub(logger)
+	go hub.Run()
+	t.Cleanup(func() { hub.Close(context.Background()) })
+
+	return newServicesTestServer(t, Config{}, logger, func(store *db.SQLStore) Services {
+		store.Order.Publisher = hub
+		return Services{Hub: hub}
+	})
+}
+
+// dialOrderStream connects to the order stream of ts as userID and returns
+// once the server is reading from the connection, so it is subscribed
+func dialOrderStream(t *testing.T, s *Server, ts *httptest.Server, userID int) (*websocket.Conn, <-chan OrderEvent) {
+	t.Helper()
+
+	token, err := s.IssueToken(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/ws/orders", header)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// The server subscribes before it starts reading, so a pong proves the
+	// subscription is in place
+	ponged := make(chan struct{})
+	conn.SetPongHandler(func(string) error {
+		close(ponged)
+		return nil
+	})
+	events := make(chan OrderEvent, 1)
+	go func() {
+		defer close(events)
+		for {
+			var event OrderEvent
+			if err := conn.ReadJSON(&event); err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+	if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	select {
+	case <-ponged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pong from the order stream")
+	}
+	return conn, events
+}
+
+func TestOrderStreamReceivesStatusChanges(t *testing.T) {
+	s, store := newHubTestServer(t, zap.NewNop())
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	alice := insertTestUser(t, store, "alice")
+	bob := insertTestUser(t, store, "bob")
+	order := insertTestOrder(t, store, alice.UserID, "AAPL", db.OrderSideBuy, 1, 99)
+	_, aliceEvents := dialOrderStream(t, s, ts, alice.UserID)
+	_, bobEvents := dialOrderStream(t, s, ts, bob.UserID)
+
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/orders/%d/status", order.OrderID), strings.NewReader(`{"status":"cancelled"}`))
+	if rec := serve(s, authorize(t, s, req, alice.UserID)); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	select {
+	case event := <-aliceEvents:
+		if event.Type != orderEventStatus || event.Order.OrderID != order.OrderID || event.Order.Status != db.OrderStatusCancelled {
+			t.Errorf("event = %+v, want order %d cancelled", event, order.OrderID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no order event received")
+	}
+
+	select {
+	case event := <-bobEvents:
+		t.Errorf("another user received %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOrderStreamRequiresToken(t *testing.T) {
+	s, _ := newHubTestServer(t, zap.NewNop())
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/ws/orders", nil)
+	if err == nil {
+		t.Fatal("Dial without a token succeeded")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("response = %v, want 401", resp)
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := NewHub(zap.NewNop())
+	go hub.Run()
+	defer hub.Close(context.Background())
+
+	slow, ok := hub.subscribe(1)
+	if !ok {
+		t.Fatal("subscribe failed on a running hub")
+	}
+	other, ok := hub.subscribe(2)
+	if !ok {
+		t.Fatal("subscribe failed on a running hub")
+	}
+	for i := range subscriberBuffer + 1 {
+		hub.PublishOrder(context.Background(), &db.Order{OrderID: i + 1, UserID: 1})
+	}
+	// Broadcasts are handled in order, so once the other subscriber has its
+	// update the slow one has seen every update meant for it
+	hub.PublishOrder(context.Background(), &db.Order{OrderID: 100, UserID: 2})
+	select {
+	case <-other.send:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update for the other subscriber")
+	}
+
+	received := 0
+	for msg := range slow.send {
+		var event OrderEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before being dropped, want %d", received, subscriberBuffer)
+	}
+}
+
+func TestOrderEventsCarryRequestCorrelationID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s, store := newHubTestServer(t, zap.New(core))
+	ts := httptest.NewServer(s.handler)
+	defer ts.Close()
+
+	alice := insertTestUser(t, store, "alice")
+	order := insertTestOrder(t, store, alice.UserID, "AAPL", db.OrderSideBuy, 1, 99)
+	_, events := dialOrderStream(t, s, ts, alice.UserID)
+
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/orders/%d/status", order.OrderID), strings.NewReader(`{"status":"cancelled"}`))
+	req.Header.Set("X-Request-Id", "req-789")
+	if rec := serve(s, authorize(t, s, req, alice.UserID)); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	select {
+	case event := <-events:
+		if event.CorrelationID != "req-789" {
+			t.Errorf("event correlation_id = %q, want the request id", event.CorrelationID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no order event received")
+	}
+
+	// The hub logs the broadcast before sending it
+	entries := logs.FilterMessage("Broadcasting order event").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d broadcast logs, want 1", len(entries))
+	}
+	if got := entries[0].ContextMap()["correlation_id"]; got != "req-789" {
+		t.Errorf("broadcast log correlation_id = %v, want the request id", got)
+	}
+	requests := logs.FilterMessage("HTTP request processed").FilterField(zap.String("request_id", "req-789"))
+	if requests.Len() != 1 {
+		t.Errorf("got %d request logs with the id, want 1", requests.Len())
+	}
+}
@@ -0,0 +1,87 @@
+// Package apperror defines domain errors that carry the HTTP status and the
+// client-facing message they should be reported with.
+package apperror
+
+import "net/http"
+
+// Error is a domain error. Message is safe to show to clients; Err, the
+// underlying cause, is only logged.
+type Error struct {
+	Status  int
+	Message string
+	// Fields holds per-field problems for validation errors
+	Fields map[string]string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel for e's status, so callers can
+// write errors.Is(err, apperror.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Status == e.Status
+}
+
+// Sentinels for matching with errors.Is; use the constructors to create errors
+var (
+	ErrBadRequest   = &Error{Status: http.StatusBadRequest}
+	ErrUnauthorized = &Error{Status: http.StatusUnauthorized}
+	ErrForbidden    = &Error{Status: http.StatusForbidden}
+	ErrNotFound     = &Error{Status: http.StatusNotFound}
+	ErrConflict     = &Error{Status: http.StatusConflict}
+	ErrValidation   = &Error{Status: http.StatusUnprocessableEntity}
+	ErrInternal     = &Error{Status: http.StatusInternalServerError}
+	ErrUnavailable  = &Error{Status: http.StatusServiceUnavailable}
+)
+
+// BadRequest reports a malformed request, e.g. an unparseable parameter
+func BadRequest(msg string) *Error {
+	return &Error{Status: http.StatusBadRequest, Message: msg}
+}
+
+// Unauthorized reports missing or invalid credentials
+func Unauthorized(msg string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Message: msg}
+}
+
+// Forbidden reports an authenticated caller acting outside their rights
+func Forbidden(msg string) *Error {
+	return &Error{Status: http.StatusForbidden, Message: msg}
+}
+
+// NotFound reports a missing resource
+func NotFound(msg string) *Error {
+	return &Error{Status: http.StatusNotFound, Message: msg}
+}
+
+// Conflict reports a request that clashes with the resource's current state
+func Conflict(msg string) *Error {
+	return &Error{Status: http.StatusConflict, Message: msg}
+}
+
+// Validation reports invalid fields, keyed by field name
+func Validation(fields map[string]string) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Message: "One or more fields are invalid", Fields: fields}
+}
+
+// Internal wraps an unexpected failure; msg is shown to the client and err
+// is logged
+func Internal(msg string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Message: msg, Err: err}
+}
+
+// Unavailable reports a dependency that is temporarily down, e.g. the
+// database during shutdown; msg is shown to the client and err is logged
+func Unavailable(msg string, err error) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Message: msg, Err: err}
+}
@@ -0,0 +1,61 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestConstructorsMatchSentinels(t *testing.T) {
+	cause := errors.New("disk on fire")
+
+	tests := []struct {
+		name     string
+		err      *Error
+		sentinel *Error
+		status   int
+	}{
+		{"bad request", BadRequest("bad"), ErrBadRequest, http.StatusBadRequest},
+		{"unauthorized", Unauthorized("who"), ErrUnauthorized, http.StatusUnauthorized},
+		{"forbidden", Forbidden("no"), ErrForbidden, http.StatusForbidden},
+		{"not found", NotFound("gone"), ErrNotFound, http.StatusNotFound},
+		{"conflict", Conflict("clash"), ErrConflict, http.StatusConflict},
+		{"validation", Validation(map[string]string{"email": "is required"}), ErrValidation, http.StatusUnprocessableEntity},
+		{"internal", Internal("oops", cause), ErrInternal, http.StatusInternalServerError},
+		{"unavailable", Unavailable("later", cause), ErrUnavailable, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Status != tt.status {
+				t.Errorf("Status = %d, want %d", tt.err.Status, tt.status)
+			}
+			wrapped := fmt.Errorf("handler: %w", tt.err)
+			if !errors.Is(wrapped, tt.sentinel) {
+				t.Error("wrapped error doesn't match its sentinel")
+			}
+			if tt.sentinel != ErrNotFound && errors.Is(wrapped, ErrNotFound) {
+				t.Error("error matches another status's sentinel")
+			}
+			var appErr *Error
+			if !errors.As(wrapped, &appErr) || appErr != tt.err {
+				t.Error("errors.As doesn't find the error")
+			}
+		})
+	}
+}
+
+func TestErrorMessageAndCause(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := Internal("Failed to load user", cause)
+
+	if got := err.Error(); got != "Failed to load user: connection refused" {
+		t.Errorf("Error() = %q", got)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("error doesn't unwrap to its cause")
+	}
+	if got := NotFound("User not found").Error(); got != "User not found" {
+		t.Errorf("Error() without a cause = %q", got)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrisp986/trader-backend/api"
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap/zapcore"
+)
+
+// config holds every setting the service reads at startup. Settings come
+// from environment variables, optionally backed by a JSON config file; see
+// LoadConfig.
+type config struct {
+	port     string
+	dbDriver string
+	dbDSN    string
+	logLevel string
+	// logFormat is json for log collectors or console for local development
+	logFormat      string
+	logDevelopment bool
+	// logFile, when set, also writes logs to this file, rotated by size
+	logFile       string
+	logMaxSizeMB  int64
+	logMaxAgeDays int64
+	logMaxBackups int64
+	// seed inserts the sample data after migrations
+	seed bool
+	// demoExchange matches orders in memory instead of leaving them open
+	demoExchange bool
+	// dbHealthInterval is how often the database is pinged in the
+	// background; 0 disables the monitor
+	dbHealthInterval time.Duration
+	// slowQueryMS is how many milliseconds a query may take before it is
+	// logged as slow; 0 disables the log
+	slowQueryMS int64
+	// otlpEndpoint, when set, receives trace spans over OTLP/HTTP
+	otlpEndpoint string
+	server       api.Config
+}
+
+// Accepted LOG_FORMAT values
+const (
+	logFormatJSON    = "json"
+	logFormatConsole = "console"
+)
+
+// defaultRedactParams are masked in request logs unless LOG_REDACT_PARAMS is set
+var defaultRedactParams = []string{"token", "key", "api_key", "password", "secret"}
+
+// defaultRedactFields are masked in logged bodies unless LOG_REDACT_FIELDS is set
+var defaultRedactFields = []string{"password", "password_hash", "token", "secret", "api_key"}
+
+// accessLogFormats lists the accepted ACCESS_LOG_FORMAT values
+var accessLogFormats = []string{api.AccessLogJSON, api.AccessLogCommon, api.AccessLogCombined}
+
+// LoadConfig builds the configuration from the JSON file at path, if any,
+// overlaid by environment variables, with defaults for anything left unset.
+// Unparseable values and failed validation are reported together rather
+// than stopping at the first.
+func LoadConfig(path string) (config, error) {
+	src := &configSource{}
+	if path != "" {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return config{}, err
+		}
+		src.file = file
+	}
+
+	cfg := src.parse()
+	problems := src.problems
+	if err := cfg.Validate(); err != nil {
+		// Validate joins its problems one per line
+		problems = append(problems, strings.Split(err.Error(), "\n")...)
+	}
+	if len(problems) > 0 {
+		return config{}, fmt.Errorf("%d invalid settings:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return cfg, nil
+}
+
+// parse reads every setting, applying defaults. Values that don't parse are
+// recorded as problems and replaced by their default.
+func (s *configSource) parse() config {
+	cfg := config{
+		// Get log level, default info
+		logLevel: s.string("LOG_LEVEL", "info"),
+		// Get the stdout log format, json (default) or console
+		logFormat: s.string("LOG_FORMAT", logFormatJSON),
+		// Get whether development logging defaults apply, default off
+		logDevelopment: s.bool("LOG_DEVELOPMENT", false),
+		// Get the log file and its rotation: rotate at 100MB, keeping 5 old
+		// files for up to 30 days; by default logs go to stdout only
+		logFile:       s.get("LOG_FILE"),
+		logMaxSizeMB:  s.int("LOG_MAX_SIZE_MB", 100),
+		logMaxAgeDays: s.int("LOG_MAX_AGE_DAYS", 30),
+		logMaxBackups: s.int("LOG_MAX_BACKUPS", 5),
+		// Get port, default 8080
+		port: s.string("PORT", "8080"),
+		// Get the database driver (sqlite3 or postgres) and its DSN; for
+		// SQLite the DSN is the database file path
+		dbDriver: s.string("DB_DRIVER", db.DriverSQLite),
+		dbDSN:    s.get("DB_DSN"),
+		// Get whether sample data is added at startup, default off
+		seed: s.bool("SEED", false),
+		// Get whether orders are matched by the in-memory demo exchange, default off
+		demoExchange: s.bool("DEMO_EXCHANGE", false),
+		// Get how often the database is pinged in the background, default 30s
+		dbHealthInterval: s.duration("DB_HEALTH_INTERVAL", 30*time.Second),
+		// Get the slow query threshold in milliseconds, default 200
+		slowQueryMS: s.int("SLOW_QUERY_MS", 200),
+		// Get the OTLP/HTTP collector URL for traces; tracing is off when unset
+		otlpEndpoint: s.get("OTEL_EXPORTER_OTLP_ENDPOINT"),
+	}
+	if cfg.dbDSN == "" && cfg.dbDriver == db.DriverSQLite {
+		cfg.dbDSN = "trader_backend.db"
+	}
+
+	routeTimeouts, err := parseRouteTimeouts(s.get("ROUTE_TIMEOUTS"))
+	if err != nil {
+		s.problems = append(s.problems, fmt.Sprintf("invalid ROUTE_TIMEOUTS: %v", err))
+	}
+
+	// Get the proxies allowed to report client IPs, as comma-separated CIDRs
+	// or addresses, default none
+	trustedProxies, err := parseTrustedProxies(s.get("TRUSTED_PROXIES"))
+	if err != nil {
+		s.problems = append(s.problems, fmt.Sprintf("invalid TRUSTED_PROXIES: %v", err))
+	}
+
+	// Get query parameters to redact from request logs, comma-separated
+	redactParams := defaultRedactParams
+	if v := s.get("LOG_REDACT_PARAMS"); v != "" {
+		redactParams = splitList(v)
+	}
+	// Get JSON fields to redact from logged bodies, comma-separated
+	redactFields := defaultRedactFields
+	if v := s.get("LOG_REDACT_FIELDS"); v != "" {
+		redactFields = splitList(v)
+	}
+
+	cfg.server = api.Config{
+		// Get the deployment environment, e.g. development or production
+		Env: s.string("APP_ENV", "development"),
+		// Get whether POST /admin/reset may wipe the database, default off
+		AllowDBReset: s.bool("ALLOW_DB_RESET", false),
+		AdminToken:   s.get("ADMIN_TOKEN"),
+		JWTSecret:    []byte(s.get("JWT_SECRET")),
+		// Get the token lifetime for /v1/login, default 24h
+		JWTTTL: s.duration("JWT_TTL", 24*time.Hour),
+		// Get the TLS certificate and key; HTTPS is used only when both are set
+		TLSCertFile:  s.get("TLS_CERT_FILE"),
+		TLSKeyFile:   s.get("TLS_KEY_FILE"),
+		RedactParams: redactParams,
+		// Get whether bodies are logged at debug level, default off
+		LogBodies:    s.bool("LOG_BODIES", false),
+		RedactFields: redactFields,
+		// Get the access log format: json (default), common or combined
+		AccessLogFormat: s.string("ACCESS_LOG_FORMAT", api.AccessLogJSON),
+		// Get the minimum free disk space in MB for readiness, default 100MB
+		MinFreeDiskBytes: s.uint("MIN_FREE_DISK_MB", 100) << 20,
+		// Get how long to keep serving after SIGTERM before shutting down, default none
+		DrainDelay: s.duration("SHUTDOWN_DRAIN_DELAY", 0),
+		// Get the deadline for draining requests and closing resources, default 30s
+		ShutdownTimeout: s.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		// Get the HTTP server's connection timeouts, default 15s to read a
+		// request (5s for its headers), 15s to write a response and 60s idle
+		ReadTimeout:       s.duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout: s.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:      s.duration("HTTP_WRITE_TIMEOUT", 15*time.Second),
+		IdleTimeout:       s.duration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+		// Get the default request timeout, overridable per route
+		RequestTimeout: s.duration("REQUEST_TIMEOUT", 10*time.Second),
+		RouteTimeouts:  routeTimeouts,
+		TrustedProxies: trustedProxies,
+		// Get allowed CORS origins, comma-separated, default none
+		CORSAllowedOrigins: splitList(s.get("CORS_ALLOWED_ORIGINS")),
+		// Get per-client rate limits, default 10 requests/second with bursts of 20
+		RateLimitRPS:   s.float("RATE_LIMIT_RPS", 10),
+		RateLimitBurst: int(s.int("RATE_LIMIT_BURST", 20)),
+		// Get whether Prometheus metrics are served at /metrics, default off
+		MetricsEnabled: s.bool("METRICS_ENABLED", false),
+		// Get whether pprof profiles are served at /debug/pprof, default off
+		PprofEnabled: s.bool("ENABLE_PPROF", false),
+		// Get the maximum request body size in bytes, default 1MB
+		MaxBodyBytes: s.int("MAX_BODY_BYTES", 1<<20),
+		// Get whether unknown query parameters are rejected, default lenient
+		StrictQueryParams: s.bool("STRICT_QUERY_PARAMS", false),
+		// Get the path prefix to serve every route under, default none; a
+		// trailing slash is ignored
+		BasePath: strings.TrimRight(s.get("BASE_PATH"), "/"),
+	}
+	return cfg
+}
+
+// Validate checks ranges and combinations of settings, returning one error
+// that lists every problem, named by its environment variable
+func (c config) Validate() error {
+	var problems []error
+	invalid := func(name string, value any, requirement string) {
+		problems = append(problems, fmt.Errorf("invalid %s %q: %s", name, fmt.Sprint(value), requirement))
+	}
+
+	if _, err := zapcore.ParseLevel(c.logLevel); err != nil {
+		invalid("LOG_LEVEL", c.logLevel, "must be one of debug, info, warn, error, dpanic, panic or fatal")
+	}
+	if c.logFormat != logFormatJSON && c.logFormat != logFormatConsole {
+		invalid("LOG_FORMAT", c.logFormat, "must be json or console")
+	}
+	if c.logMaxSizeMB < 1 {
+		invalid("LOG_MAX_SIZE_MB", c.logMaxSizeMB, "must be a positive integer")
+	}
+	if c.logMaxAgeDays < 0 {
+		invalid("LOG_MAX_AGE_DAYS", c.logMaxAgeDays, "must be a non-negative integer")
+	}
+	if c.logMaxBackups < 0 {
+		invalid("LOG_MAX_BACKUPS", c.logMaxBackups, "must be a non-negative integer")
+	}
+	if n, err := strconv.Atoi(c.port); err != nil || n < 1 || n > 65535 {
+		invalid("PORT", c.port, "must be a number between 1 and 65535")
+	}
+
+	switch c.dbDriver {
+	case db.DriverSQLite, db.DriverPostgres:
+		if c.dbDSN == "" {
+			problems = append(problems, fmt.Errorf("DB_DSN is required for DB_DRIVER %q", c.dbDriver))
+		}
+	default:
+		invalid("DB_DRIVER", c.dbDriver, fmt.Sprintf("must be %s or %s", db.DriverSQLite, db.DriverPostgres))
+	}
+
+	if c.dbHealthInterval < 0 {
+		invalid("DB_HEALTH_INTERVAL", c.dbHealthInterval, "must be a non-negative duration")
+	}
+	if c.slowQueryMS < 0 {
+		invalid("SLOW_QUERY_MS", c.slowQueryMS, "must be a non-negative number of milliseconds")
+	}
+	if c.otlpEndpoint != "" {
+		if u, err := url.Parse(c.otlpEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("OTEL_EXPORTER_OTLP_ENDPOINT", c.otlpEndpoint, "must be an http or https URL")
+		}
+	}
+
+	s := c.server
+	if s.JWTTTL <= 0 {
+		invalid("JWT_TTL", s.JWTTTL, "must be a positive duration")
+	}
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		problems = append(problems, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if !slices.Contains(accessLogFormats, s.AccessLogFormat) {
+		invalid("ACCESS_LOG_FORMAT", s.AccessLogFormat, "must be one of "+strings.Join(accessLogFormats, ", "))
+	}
+	if s.DrainDelay < 0 {
+		invalid("SHUTDOWN_DRAIN_DELAY", s.DrainDelay, "must be a non-negative duration")
+	}
+	if s.ShutdownTimeout <= 0 {
+		invalid("SHUTDOWN_TIMEOUT", s.ShutdownTimeout, "must be a positive duration")
+	}
+	if s.ReadTimeout <= 0 {
+		invalid("HTTP_READ_TIMEOUT", s.ReadTimeout, "must be a positive duration")
+	}
+	if s.ReadHeaderTimeout <= 0 || s.ReadHeaderTimeout > s.ReadTimeout {
+		invalid("HTTP_READ_HEADER_TIMEOUT", s.ReadHeaderTimeout, "must be a positive duration no longer than HTTP_READ_TIMEOUT")
+	}
+	if s.WriteTimeout <= 0 {
+		invalid("HTTP_WRITE_TIMEOUT", s.WriteTimeout, "must be a positive duration")
+	}
+	if s.IdleTimeout <= 0 {
+		invalid("HTTP_IDLE_TIMEOUT", s.IdleTimeout, "must be a positive duration")
+	}
+	if s.RequestTimeout <= 0 {
+		invalid("REQUEST_TIMEOUT", s.RequestTimeout, "must be a positive duration")
+	}
+	if s.RateLimitRPS < 0 {
+		invalid("RATE_LIMIT_RPS", s.RateLimitRPS, "must be a non-negative number")
+	}
+	if s.RateLimitBurst < 1 {
+		invalid("RATE_LIMIT_BURST", s.RateLimitBurst, "must be a positive integer")
+	}
+	if s.MaxBodyBytes < 0 {
+		invalid("MAX_BODY_BYTES", s.MaxBodyBytes, "must be a non-negative integer")
+	}
+	if s.BasePath != "" && (!strings.HasPrefix(s.BasePath, "/") || strings.ContainsAny(s.BasePath, "{}*?#")) {
+		invalid("BASE_PATH", s.BasePath, "must be a path starting with /, without patterns or query")
+	}
+
+	return errors.Join(problems...)
+}
+
+// configSource reads settings from the environment, falling back to the
+// config file, and collects the values that fail to parse
+type configSource struct {
+	file     map[string]string
+	problems []string
+}
+
+// readConfigFile reads a JSON object whose keys are the environment variable
+// names, e.g. {"PORT": 8080, "CORS_ALLOWED_ORIGINS": ["https://a.example"]}.
+// Numbers and booleans are accepted as well as strings; arrays are joined
+// with commas.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	file := make(map[string]string, len(raw))
+	for key, value := range raw {
+		s, err := configFileValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+		file[strings.ToUpper(key)] = s
+	}
+	return file, nil
+}
+
+// configFileValue converts a JSON value into the string form the environment
+// variable would have
+func configFileValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configFileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// get returns the setting's environment value, or its config file value
+// when the variable is unset or empty
+func (s *configSource) get(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return s.file[name]
+}
+
+// invalid records that the setting's value can't be parsed
+func (s *configSource) invalid(name, requirement string) {
+	s.problems = append(s.problems, fmt.Sprintf("invalid %s %q: %s", name, s.get(name), requirement))
+}
+
+// string returns the setting, or def when it is unset
+func (s *configSource) string(name, def string) string {
+	if v := s.get(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// duration parses the setting as a Go duration, e.g. "30s"
+func (s *configSource) duration(name string, def time.Duration) time.Duration {
+	v := s.get(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		s.invalid(name, "must be a duration such as 30s or 5m")
+		return def
+	}
+	return d
+}
+
+// int parses the setting as a base-10 integer
+func (s *configSource) int(name string, def int64) int64 {
+	v := s.get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		s.invalid(name, "must be an integer")
+		return def
+	}
+	return n
+}
+
+// uint parses the setting as a non-negative base-10 integer
+func (s *configSource) uint(name string, def uint64) uint64 {
+	v := s.get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		s.invalid(name, "must be a non-negative integer")
+		return def
+	}
+	return n
+}
+
+// float parses the setting as a number
+func (s *configSource) float(name string, def float64) float64 {
+	v := s.get(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		s.invalid(name, "must be a number")
+		return def
+	}
+	return f
+}
+
+// bool parses the setting as a boolean, e.g. true, false, 1 or 0
+func (s *configSource) bool(name string, def bool) bool {
+	v := s.get(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		s.invalid(name, "must be a boolean")
+		return def
+	}
+	return b
+}
+
+// splitList splits a comma-separated value, dropping blank entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRouteTimeouts parses "pattern=duration" pairs separated by commas,
+// e.g. "/admin/maintenance=5m,/health=2s"
+func parseRouteTimeouts(v string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, item := range splitList(v) {
+		pattern, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("route timeout %q must have the form pattern=duration", item)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("route timeout for %s: %w", pattern, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("route timeout for %s must not be negative", pattern)
+		}
+		timeouts[strings.TrimSpace(pattern)] = d
+	}
+	return timeouts, nil
+}
+
+// parseTrustedProxies parses a comma-separated list of CIDR ranges, e.g.
+// "10.0.0.0/8,fd00::/8", where a bare address stands for itself alone
+func parseTrustedProxies(v string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range splitList(v) {
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes contents to a config file in a temporary directory
+// and returns its path
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clearEnv unsets the named variables for the test, so values from the
+// environment running the tests don't leak in
+func clearEnv(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	clearEnv(t, "PORT", "LOG_LEVEL", "CORS_ALLOWED_ORIGINS", "METRICS_ENABLED", "DB_DSN", "JWT_TTL", "ALLOW_DB_RESET")
+	path := writeConfigFile(t, `{
+		"PORT": 9090,
+		"log_level": "debug",
+		"CORS_ALLOWED_ORIGINS": ["https://a.example", "https://b.example"],
+		"METRICS_ENABLED": true,
+		"JWT_TTL": "2h"
+	}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.port != "9090" || cfg.logLevel != "debug" {
+		t.Errorf("port, logLevel = %q, %q, want 9090, debug", cfg.port, cfg.logLevel)
+	}
+	if got := strings.Join(cfg.server.CORSAllowedOrigins, " "); got != "https://a.example https://b.example" {
+		t.Errorf("CORSAllowedOrigins = %q", got)
+	}
+	if !cfg.server.MetricsEnabled || cfg.server.JWTTTL != 2*time.Hour {
+		t.Errorf("MetricsEnabled, JWTTTL = %v, %v, want true, 2h", cfg.server.MetricsEnabled, cfg.server.JWTTTL)
+	}
+	// Unset settings fall back to their defaults
+	if cfg.dbDSN != "trader_backend.db" || cfg.server.RequestTimeout != 10*time.Second {
+		t.Errorf("dbDSN, RequestTimeout = %q, %v, want the defaults", cfg.dbDSN, cfg.server.RequestTimeout)
+	}
+	if cfg.server.AllowDBReset {
+		t.Error("AllowDBReset = true, want database resets off by default")
+	}
+}
+
+func TestLoadConfigEnvOverridesFile(t *testing.T) {
+	clearEnv(t, "LOG_LEVEL")
+	t.Setenv("PORT", "7070")
+	path := writeConfigFile(t, `{"PORT": "9090", "LOG_LEVEL": "warn"}`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.port != "7070" {
+		t.Errorf("port = %q, want the environment's 7070", cfg.port)
+	}
+	if cfg.logLevel != "warn" {
+		t.Errorf("logLevel = %q, want the file's warn", cfg.logLevel)
+	}
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	t.Setenv("PORT", "abc")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("RATE_LIMIT_RPS", "fast")
+
+	_, err := LoadConfig("")
+	if err == nil {
+		t.Fatal("LoadConfig succeeded with invalid settings")
+	}
+	for _, want := range []string{"3 invalid settings", `invalid PORT "abc"`, `invalid LOG_LEVEL "loud"`, `invalid RATE_LIMIT_RPS "fast"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"malformed", `{"PORT": `, "failed to parse config file"},
+		{"unsupported value", `{"PORT": {"number": 8080}}`, "PORT: unsupported value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfigFile(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadConfig of a missing file succeeded")
+	}
+}
+
+func TestLoadConfigParsesEachType(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "45s")
+	t.Setenv("RATE_LIMIT_BURST", "7")
+	t.Setenv("MIN_FREE_DISK_MB", "2")
+	t.Setenv("RATE_LIMIT_RPS", "2.5")
+	t.Setenv("STRICT_QUERY_PARAMS", "1")
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://a.example, ,https://b.example ")
+	t.Setenv("ROUTE_TIMEOUTS", "/admin/maintenance=5m, /health=2s")
+	t.Setenv("BASE_PATH", "/api/")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	s := cfg.server
+	if s.ShutdownTimeout != 45*time.Second {
+		t.Errorf("ShutdownTimeout = %v, want 45s", s.ShutdownTimeout)
+	}
+	if s.RateLimitBurst != 7 || s.RateLimitRPS != 2.5 {
+		t.Errorf("RateLimitBurst, RateLimitRPS = %d, %v, want 7, 2.5", s.RateLimitBurst, s.RateLimitRPS)
+	}
+	if s.MinFreeDiskBytes != 2<<20 {
+		t.Errorf("MinFreeDiskBytes = %d, want 2MB", s.MinFreeDiskBytes)
+	}
+	if !s.StrictQueryParams {
+		t.Error("StrictQueryParams = false, want true")
+	}
+	if len(s.CORSAllowedOrigins) != 2 || s.CORSAllowedOrigins[1] != "https://b.example" {
+		t.Errorf("CORSAllowedOrigins = %q, want the two trimmed origins", s.CORSAllowedOrigins)
+	}
+	if s.RouteTimeouts["/admin/maintenance"] != 5*time.Minute || s.RouteTimeouts["/health"] != 2*time.Second {
+		t.Errorf("RouteTimeouts = %v", s.RouteTimeouts)
+	}
+	if s.BasePath != "/api" {
+		t.Errorf("BasePath = %q, want /api", s.BasePath)
+	}
+}
+
+func TestLoadConfigRejectsBadValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		error string
+	}{
+		{"duration", map[string]string{"SHUTDOWN_TIMEOUT": "soon"}, `invalid SHUTDOWN_TIMEOUT "soon": must be a duration`},
+		{"integer", map[string]string{"RATE_LIMIT_BURST": "many"}, `invalid RATE_LIMIT_BURST "many": must be an integer`},
+		{"unsigned", map[string]string{"MIN_FREE_DISK_MB": "-1"}, `invalid MIN_FREE_DISK_MB "-1": must be a non-negative integer`},
+		{"boolean", map[string]string{"METRICS_ENABLED": "yes"}, `invalid METRICS_ENABLED "yes": must be a boolean`},
+		{"port range", map[string]string{"PORT": "70000"}, `invalid PORT "70000"`},
+		{"driver", map[string]string{"DB_DRIVER": "mysql"}, `invalid DB_DRIVER "mysql"`},
+		{"tls pair", map[string]string{"TLS_CERT_FILE": "cert.pem"}, "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		{"header timeout", map[string]string{"HTTP_READ_HEADER_TIMEOUT": "20s"}, "no longer than HTTP_READ_TIMEOUT"},
+		{"route timeouts", map[string]string{"ROUTE_TIMEOUTS": "/health"}, "invalid ROUTE_TIMEOUTS"},
+		{"base path", map[string]string{"BASE_PATH": "api"}, `invalid BASE_PATH "api"`},
+		{"otlp endpoint", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4318"}, "invalid OTEL_EXPORTER_OTLP_ENDPOINT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := LoadConfig("")
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("LoadConfig = %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.1, 10.1.2.3/16, ::1")
+	if err != nil {
+		t.Fatalf("parseTrustedProxies: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "10.1.0.0/16", "::1/128"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("got %v, want %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, prefix, want[i])
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "proxy.internal"} {
+		if _, err := parseTrustedProxies(bad); err == nil {
+			t.Errorf("parseTrustedProxies(%q) succeeded, want an error", bad)
+		}
+	}
+}
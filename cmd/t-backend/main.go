@@ -1,36 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
+	"github.com/chrisp986/trader-backend/api"
 	db "github.com/chrisp986/trader-backend/database"
+	"github.com/chrisp986/trader-backend/engine"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-// // HealthResponse represents the health check response structure
-// type HttpResponse struct {
-// 	HttpStatusCode int       `json:"http_status_code"`
-// 	Status         string    `json:"status"`
-// 	Timestamp      time.Time `json:"timestamp"`
-// 	Version        string    `json:"version"`
-// 	Uptime         string    `json:"uptime"`
-// }
-
-type application struct {
-	logger *zap.Logger
-	user   db.UserModelInterface
-}
-
-type config struct {
-	port     string
-	dbPath   string
-	logLevel string
-}
-
-// newLogger creates a new zap logger with structured JSON output
-func newLogger(logLevel string) *zap.Logger {
+// newLogger creates a new zap logger writing to stdout, as structured JSON or,
+// with LOG_FORMAT=console, as colored human-readable lines. When cfg.logFile
+// is set it also writes JSON to that file with rotation. The returned
+// closer, nil without a log file, closes the file.
+func newLogger(cfg config) (*zap.Logger, io.Closer) {
+	logLevel := cfg.logLevel
 
 	var level zapcore.Level
 	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
@@ -65,57 +55,105 @@ func newLogger(logLevel string) *zap.Logger {
 		OutputPaths:      []string{"stdout"},
 		ErrorOutputPaths: []string{"stderr"},
 	}
+	// The log file stays JSON whatever the stdout format
+	fileEncoderConfig := config.EncoderConfig
 
-	logger, err := config.Build()
+	if cfg.logFormat == logFormatConsole {
+		config.Encoding = logFormatConsole
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		config.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+	}
+	// Development mode adds stack traces from warnings up and makes DPanic panic
+	config.Development = cfg.logDevelopment
+
+	var options []zap.Option
+	var logFile *lumberjack.Logger
+	if cfg.logFile != "" {
+		logFile = &lumberjack.Logger{
+			Filename:   cfg.logFile,
+			MaxSize:    int(cfg.logMaxSizeMB),
+			MaxAge:     int(cfg.logMaxAgeDays),
+			MaxBackups: int(cfg.logMaxBackups),
+		}
+		// Tee the stdout core into one writing JSON to the file
+		fileCore := zapcore.NewCore(
+			zapcore.NewJSONEncoder(fileEncoderConfig),
+			zapcore.AddSync(logFile),
+			config.Level,
+		)
+		options = append(options, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, fileCore)
+		}))
+	}
+
+	logger, err := config.Build(options...)
 	if err != nil {
 		panic("Failed to initialize logger: " + err.Error())
 	}
 
-	return logger
+	if logFile == nil {
+		return logger, nil
+	}
+	return logger, logFile
 }
 
-func getConfig() config {
-	// Get log level from environment variable or default to INFO
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = "info"
+func main() {
+
+	// CONFIG_FILE optionally names a JSON file of settings; environment
+	// variables override it
+	cfg, err := LoadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		os.Exit(1)
 	}
 
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	logger, logFile := newLogger(cfg)
+	if cfg.server.LogBodies && !logger.Core().Enabled(zap.DebugLevel) {
+		logger.Warn("LOG_BODIES has no effect unless LOG_LEVEL is debug")
 	}
-	cfg := config{port: port, dbPath: "trader_backend.db", logLevel: logLevel}
-	return cfg
-}
 
-func main() {
+	server, err := newServer(cfg, logger, logFile)
+	if err != nil {
+		logger.Fatal("Failed to set up server", zap.Error(err))
+	}
 
-	cfg := getConfig()
+	addr := ":" + cfg.port
 
-	logger := newLogger(cfg.logLevel)
+	fmt.Println("Starting Trader backend with address:", addr)
+	logger.Info("Application starting",
+		zap.String("port", cfg.port),
+	)
 
+	if err := server.Start(addr); err != nil {
+		logger.Fatal("Failed to start server", zap.Error(err))
+	}
+}
+
+// newServer sets up the database, its sample data and health monitor,
+// tracing, the order hub and, in demo exchange mode, the matching engine, and
+// returns the API server using them. The server's shutdown phases release all
+// of them, closing logFile, if set, last.
+func newServer(cfg config, logger *zap.Logger, logFile io.Closer) (*api.Server, error) {
 	// Create database manager
-	dbManager := db.NewDatabaseManager(cfg.dbPath, logger)
-	app := &application{user: &db.UserModel{DB: dbManager.DB, Logger: logger}}
+	dbManager, err := db.NewDatabaseManager(cfg.dbDriver, cfg.dbDSN, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
 
-	// Ensure cleanup
-	defer func() {
-		if err := dbManager.Close(); err != nil {
-			logger.Info("Error closing database:", zap.Error(err))
-		}
-	}()
+	// Log queries slower than SLOW_QUERY_MS
+	dbManager.SetSlowQueryThreshold(time.Duration(cfg.slowQueryMS) * time.Millisecond)
 
 	// Initialize database
 	if err := dbManager.InitializeDatabase(); err != nil {
-		logger.Fatal("Failed to initialize database:", zap.Error(err))
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	// // Add sample data
-	// if err := dbManager.AddSampleData(); err != nil {
-	// 	log.Printf("Warning: Failed to add sample data: %v", err)
-	// }
+	// Add sample data when asked to; it is safe to run on every start
+	if cfg.seed {
+		if err := dbManager.AddSampleData(); err != nil {
+			logger.Warn("Failed to add sample data", zap.Error(err))
+		}
+	}
 
 	// Display table information
 	if err := dbManager.GetTableInfo(); err != nil {
@@ -124,19 +162,69 @@ func main() {
 
 	logger.Info("Database setup completed successfully!")
 
-	server := NewServer()
+	// Ping the database in the background so problems show up in the logs
+	// and /readiness without waiting for a request to fail
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	if cfg.dbHealthInterval > 0 {
+		dbManager.StartHealthMonitor(monitorCtx, cfg.dbHealthInterval)
+	}
 
-	// Ensure logger is properly closed on exit
-	defer logger.Sync()
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	tracerProvider, err := newTracerProvider(context.Background(), cfg, logger)
+	if err != nil {
+		stopMonitor()
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	if tracerProvider != nil {
+		logger.Info("Tracing enabled", zap.String("otlp_endpoint", cfg.otlpEndpoint))
+	}
 
-	addr := ":" + cfg.port
+	// The hub streams order status changes to WebSocket clients
+	hub := api.NewHub(logger)
+	go hub.Run()
 
-	fmt.Println("Starting Trader backend with address:", addr)
-	logger.Info("Application starting",
-		zap.String("port", cfg.port),
-	)
+	store := db.NewSQLStore(dbManager, logger)
+	store.Order.Publisher = hub
 
-	if err := server.Start(addr); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	services := api.Services{Hub: hub}
+	if cfg.demoExchange {
+		services.Matcher = engine.New(dbManager.DB, store.Order, store.Trade, store.Position, logger)
+		logger.Info("Demo exchange enabled: orders are matched in memory")
+	}
+	server, err := api.NewServer(cfg.server, logger, store, services, dbManager)
+	if err != nil {
+		stopMonitor()
+		return nil, err
+	}
+	// Trades feed the price stream
+	if services.Matcher != nil {
+		services.Matcher.SetPricePublisher(server.Prices())
+	}
+
+	// Shutdown order after the HTTP server drains: disconnect streaming
+	// clients, whose hijacked connections the drain doesn't wait for, stop
+	// pinging the database, flush traces and logs, close the database, and
+	// close the log file last
+	server.OnShutdown("close order hub", hub.Close)
+	server.OnShutdown("stop database health monitor", func(ctx context.Context) error {
+		stopMonitor()
+		return nil
+	})
+	if tracerProvider != nil {
+		server.OnShutdown("flush traces", tracerProvider.Shutdown)
+	}
+	server.OnShutdown("flush logs", func(ctx context.Context) error {
+		logger.Sync()
+		return nil
+	})
+	server.OnShutdown("close database", func(ctx context.Context) error {
+		return dbManager.Close()
+	})
+	if logFile != nil {
+		server.OnShutdown("close log file", func(ctx context.Context) error {
+			return logFile.Close()
+		})
 	}
+
+	return server, nil
 }
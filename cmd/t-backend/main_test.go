@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// testEnv sets the environment a server needs, with its database in a
+// temporary directory, and returns the database path
+func testEnv(t *testing.T) string {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	t.Setenv("DB_DSN", dsn)
+	t.Setenv("JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	return dsn
+}
+
+// runServer builds a server from the environment and serves it on a free
+// loopback port. The returned stop shuts it down, running its shutdown
+// phases; it is also called when the test ends.
+func runServer(t *testing.T) (addr string, stop func()) {
+	t.Helper()
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	server, err := newServer(cfg, zap.NewNop(), nil)
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = ln.Addr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx, addr) }()
+
+	stopped := false
+	stop = func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}
+	t.Cleanup(stop)
+	return addr, stop
+}
+
+func TestNewServerServesHealth(t *testing.T) {
+	testEnv(t)
+	addr, _ := runServer(t)
+
+	var resp *http.Response
+	var err error
+	for range 50 {
+		if resp, err = http.Get("http://" + addr + "/health"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestSeedAddsSampleUsersOnce(t *testing.T) {
+	dsn := testEnv(t)
+	t.Setenv("SEED", "true")
+
+	for range 2 {
+		_, stop := runServer(t)
+		stop()
+	}
+
+	database, err := sql.Open(db.DriverSQLite, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	for _, username := range []string{"john_doe", "jane_smith"} {
+		var count int
+		if err := database.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", username).Scan(&count); err != nil {
+			t.Fatalf("count %s: %v", username, err)
+		}
+		if count != 1 {
+			t.Errorf("%s exists %d times, want once", username, count)
+		}
+	}
+}
+
+func TestNewLoggerWritesFile(t *testing.T) {
+	cfg := config{
+		logLevel:     "info",
+		logFile:      filepath.Join(t.TempDir(), "server.log"),
+		logMaxSizeMB: 1,
+	}
+	logger, closer := newLogger(cfg)
+	if closer == nil {
+		t.Fatal("newLogger returned no closer for the log file")
+	}
+	logger.Info("written to file", zap.String("component", "test"))
+	logger.Debug("below the level")
+	logger.Sync()
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	contents, err := os.ReadFile(cfg.logFile)
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("log file has %d lines, want 1:\n%s", len(lines), contents)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v\n%s", err, lines[0])
+	}
+	if entry["message"] != "written to file" || entry["level"] != "info" || entry["component"] != "test" {
+		t.Errorf("log entry = %v", entry)
+	}
+}
+
+// captureStdout points os.Stdout at a pipe while fn runs and returns what
+// was written to it
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestNewLoggerFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		json   bool
+	}{
+		{logFormatJSON, true},
+		{logFormatConsole, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			out := captureStdout(t, func() {
+				logger, _ := newLogger(config{logLevel: "info", logFormat: tt.format})
+				logger.Info("hello", zap.Int("n", 1))
+				logger.Sync()
+			})
+			line := strings.TrimSpace(out)
+			if !strings.Contains(line, "hello") {
+				t.Fatalf("stdout = %q, want the message", out)
+			}
+			if got := json.Valid([]byte(line)); got != tt.json {
+				t.Errorf("stdout is JSON = %v, want %v: %q", got, tt.json, line)
+			}
+		})
+	}
+}
+
+func TestNewTracerProviderDisabledWithoutEndpoint(t *testing.T) {
+	tp, err := newTracerProvider(context.Background(), config{}, zap.NewNop())
+	if tp != nil || err != nil {
+		t.Errorf("newTracerProvider = %v, %v, want nil, nil without an endpoint", tp, err)
+	}
+}
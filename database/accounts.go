@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// AccountModelInterface manages per-user, per-currency balances. Amounts are
+// integer minor units (cents for USD) so balances never pick up float error.
+type AccountModelInterface interface {
+	Credit(ctx context.Context, userID int, currency string, amount int64) error
+	Debit(ctx context.Context, userID int, currency string, amount int64) error
+	DebitTx(ctx context.Context, tx *sql.Tx, userID int, currency string, amount int64) error
+	GetBalance(ctx context.Context, userID int, currency string) (int64, error)
+}
+
+// AccountModel wraps a database connection pool for account balances
+type AccountModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled accounts.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *AccountModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// NormalizeCurrency trims and uppercases a currency code so "usd" and "USD"
+// refer to the same account
+func NormalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// validateAmount rejects zero and negative amounts, which would turn a credit
+// into a debit or the other way round
+func validateAmount(amount int64) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive, got %d", amount)
+	}
+	return nil
+}
+
+// Credit adds amount to the user's balance in currency, opening the account
+// on its first credit
+func (m *AccountModel) Credit(ctx context.Context, userID int, currency string, amount int64) error {
+	if err := validateAmount(amount); err != nil {
+		return err
+	}
+	currency = NormalizeCurrency(currency)
+
+	query := `
+	INSERT INTO accounts (user_id, currency, balance)
+	VALUES (?, ?, ?)
+	ON CONFLICT (user_id, currency) DO UPDATE
+	SET balance = accounts.balance + excluded.balance, updated_at = CURRENT_TIMESTAMP`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "accounts.credit", query)
+	_, err := m.DB.ExecContext(ctx, m.rebind(query), userID, currency, amount)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to credit %s account of user %d: %w", currency, userID, err)
+	}
+
+	m.Logger.Info("Account credited",
+		zap.Int("user_id", userID),
+		zap.String("currency", currency),
+		zap.Int64("amount", amount))
+
+	return nil
+}
+
+// Debit subtracts amount from the user's balance in currency. It returns
+// ErrInsufficientFunds, leaving the balance untouched, when the account holds
+// less than amount or doesn't exist.
+func (m *AccountModel) Debit(ctx context.Context, userID int, currency string, amount int64) (err error) {
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "accounts.debit", "")
+	defer func() { err = done(err) }()
+
+	tx, err := BeginTx(ctx, m.DB, m.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.DebitTx(ctx, tx.Tx, userID, currency, amount); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit debit: %w", err)
+	}
+	return nil
+}
+
+// DebitTx debits the account inside tx so the caller can combine it with
+// other writes. The UPDATE only matches while the balance covers amount, so
+// concurrent debits can never take the balance below zero.
+func (m *AccountModel) DebitTx(ctx context.Context, tx *sql.Tx, userID int, currency string, amount int64) error {
+	if err := validateAmount(amount); err != nil {
+		return err
+	}
+	currency = NormalizeCurrency(currency)
+
+	query := `
+	UPDATE accounts
+	SET balance = balance - ?, updated_at = CURRENT_TIMESTAMP
+	WHERE user_id = ? AND currency = ? AND balance >= ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "accounts.debit_tx", query)
+	result, err := tx.ExecContext(ctx, m.rebind(query), amount, userID, currency, amount)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to debit %s account of user %d: %w", currency, userID, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrInsufficientFunds
+	}
+
+	m.Logger.Info("Account debited",
+		zap.Int("user_id", userID),
+		zap.String("currency", currency),
+		zap.Int64("amount", amount))
+
+	return nil
+}
+
+// GetBalance returns the user's balance in currency, or ErrNoRecord if the
+// account has never been credited
+func (m *AccountModel) GetBalance(ctx context.Context, userID int, currency string) (int64, error) {
+	currency = NormalizeCurrency(currency)
+
+	var balance int64
+	query := `SELECT balance FROM accounts WHERE user_id = ? AND currency = ?`
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "accounts.get_balance", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), userID, currency).Scan(&balance)
+	err = done(err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, fmt.Errorf("failed to get %s balance of user %d: %w", currency, userID, err)
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAccountCreditDebit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+
+	if _, err := s.Account.GetBalance(ctx, user.UserID, "USD"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetBalance before any credit = %v, want ErrNoRecord", err)
+	}
+	if err := s.Account.Debit(ctx, user.UserID, "USD", 1); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Debit of a missing account = %v, want ErrInsufficientFunds", err)
+	}
+
+	if err := s.Account.Credit(ctx, user.UserID, "usd", 1000); err != nil {
+		t.Fatalf("Credit: %v", err)
+	}
+	if err := s.Account.Credit(ctx, user.UserID, " USD ", 500); err != nil {
+		t.Fatalf("second Credit: %v", err)
+	}
+	if err := s.Account.Debit(ctx, user.UserID, "USD", 300); err != nil {
+		t.Fatalf("Debit: %v", err)
+	}
+	if err := s.Account.Debit(ctx, user.UserID, "USD", 1201); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdraft Debit = %v, want ErrInsufficientFunds", err)
+	}
+	for _, amount := range []int64{0, -5} {
+		if err := s.Account.Credit(ctx, user.UserID, "USD", amount); err == nil {
+			t.Errorf("Credit of %d succeeded", amount)
+		}
+		if err := s.Account.Debit(ctx, user.UserID, "USD", amount); err == nil {
+			t.Errorf("Debit of %d succeeded", amount)
+		}
+	}
+
+	balance, err := s.Account.GetBalance(ctx, user.UserID, "usd")
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != 1200 {
+		t.Errorf("balance = %d, want 1200", balance)
+	}
+}
+
+func TestAccountConcurrentDebitsDontOversell(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+	if err := s.Account.Credit(ctx, user.UserID, "USD", 1000); err != nil {
+		t.Fatalf("Credit: %v", err)
+	}
+
+	const debits = 25
+	var succeeded atomic.Int64
+	var wg sync.WaitGroup
+	for range debits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch err := s.Account.Debit(ctx, user.UserID, "USD", 100); {
+			case err == nil:
+				succeeded.Add(1)
+			case !errors.Is(err, ErrInsufficientFunds):
+				t.Errorf("Debit: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded.Load() != 10 {
+		t.Errorf("%d debits succeeded, want 10", succeeded.Load())
+	}
+	balance, err := s.Account.GetBalance(ctx, user.UserID, "USD")
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance != 0 {
+		t.Errorf("balance = %d, want 0", balance)
+	}
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionCancel  = "cancel"
+)
+
+// AuditEntry records one mutating operation. UserID is the user who made
+// the change, or nil when it wasn't made by a user; Detail holds
+// action-specific JSON.
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	UserID    *int            `json:"user_id"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entity_id"`
+	Detail    json.RawMessage `json:"detail,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AuditModelInterface interface {
+	Record(ctx context.Context, entry *AuditEntry) error
+	List(ctx context.Context, userID, limit, offset int) ([]*AuditEntry, error)
+	Count(ctx context.Context, userID int) (int, error)
+}
+
+// AuditModel wraps a database connection pool for the audit trail. Entries
+// are only ever inserted; they outlive the records they describe.
+type AuditModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled audit.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *AuditModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// Record inserts entry, setting its ID and CreatedAt
+func (m *AuditModel) Record(ctx context.Context, entry *AuditEntry) error {
+	var detail sql.NullString
+	if len(entry.Detail) > 0 {
+		detail = sql.NullString{String: string(entry.Detail), Valid: true}
+	}
+
+	query := `
+	INSERT INTO audit_log (user_id, action, entity, entity_id, detail)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING id, created_at`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "audit.record", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), entry.UserID, entry.Action, entry.Entity, entry.EntityID, detail).Scan(&entry.ID, &entry.CreatedAt)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditFilter returns the WHERE clause and arguments selecting the entries
+// made by userID, or all entries when userID is 0
+func auditFilter(userID int) (string, []any) {
+	if userID == 0 {
+		return "", nil
+	}
+	return " WHERE user_id = ?", []any{userID}
+}
+
+// List returns a page of audit entries, newest first, made by userID or by
+// anyone when userID is 0. limit is clamped the same way as UserModel.List.
+func (m *AuditModel) List(ctx context.Context, userID, limit, offset int) ([]*AuditEntry, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	where, args := auditFilter(userID)
+	query := `
+	SELECT id, user_id, action, entity, entity_id, detail, created_at
+	FROM audit_log` + where + `
+	ORDER BY id DESC
+	LIMIT ? OFFSET ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "audit.list", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), append(args, limit, offset)...)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry := &AuditEntry{}
+		var userID sql.NullInt64
+		var detail sql.NullString
+		if err := rows.Scan(&entry.ID, &userID, &entry.Action, &entry.Entity, &entry.EntityID, &detail, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			entry.UserID = &id
+		}
+		if detail.Valid {
+			entry.Detail = json.RawMessage(detail.String)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Count returns the number of audit entries made by userID, or all entries
+// when userID is 0
+func (m *AuditModel) Count(ctx context.Context, userID int) (int, error) {
+	where, args := auditFilter(userID)
+
+	query := `SELECT COUNT(*) FROM audit_log` + where
+
+	var count int
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "audit.count", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), args...).Scan(&count)
+	err = done(err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestAuditRecordAndList(t *testing.T) {
+	m := newTestStore(t).AuditLog
+	ctx := context.Background()
+
+	alice, bob := 1, 2
+	entries := []*AuditEntry{
+		{UserID: &alice, Action: AuditActionCreate, Entity: "user", EntityID: 1, Detail: json.RawMessage(`{"user_name":"alice"}`)},
+		{UserID: &bob, Action: AuditActionUpdate, Entity: "user", EntityID: 2},
+		{Action: AuditActionDelete, Entity: "user", EntityID: 1},
+	}
+	for _, entry := range entries {
+		if err := m.Record(ctx, entry); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+		if entry.ID == 0 || entry.CreatedAt.IsZero() {
+			t.Errorf("Record didn't set ID and CreatedAt: %+v", entry)
+		}
+	}
+
+	all, err := m.List(ctx, 0, 10, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 3 || all[0].Action != AuditActionDelete || all[0].UserID != nil {
+		t.Fatalf("List = %d entries starting with %+v, want 3, newest first", len(all), all[0])
+	}
+
+	mine, err := m.List(ctx, alice, 10, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(mine) != 1 || *mine[0].UserID != alice || string(mine[0].Detail) != `{"user_name":"alice"}` {
+		t.Errorf("List for alice = %+v, want her create entry", mine)
+	}
+
+	for userID, want := range map[int]int{0: 3, alice: 1, bob: 1, 3: 0} {
+		if count, err := m.Count(ctx, userID); err != nil || count != want {
+			t.Errorf("Count(%d) = %d, %v, want %d", userID, count, err, want)
+		}
+	}
+}
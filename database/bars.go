@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Timeframes lists the bar intervals accepted by the CHECK constraint on bars
+var Timeframes = []string{"1m", "5m", "15m", "30m", "1h", "4h", "1d"}
+
+// Bar is one OHLCV candle for a symbol over a timeframe starting at Timestamp
+type Bar struct {
+	Symbol    string    `json:"symbol"`
+	Timeframe string    `json:"timeframe"`
+	Timestamp time.Time `json:"ts"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    float64   `json:"volume"`
+}
+
+// Validate returns a map of field name to problem, empty when the bar is
+// consistent. It mirrors the CHECK constraints so batches can be rejected
+// before any row is written.
+func (b *Bar) Validate() map[string]string {
+	fields := make(map[string]string)
+
+	if NormalizeSymbol(b.Symbol) == "" {
+		fields["symbol"] = "must not be empty"
+	}
+	if !slices.Contains(Timeframes, b.Timeframe) {
+		fields["timeframe"] = fmt.Sprintf("must be one of %v", Timeframes)
+	}
+	if b.Timestamp.IsZero() {
+		fields["ts"] = "must be set"
+	}
+	if b.Open <= 0 {
+		fields["open"] = "must be positive"
+	}
+	if b.Close <= 0 {
+		fields["close"] = "must be positive"
+	}
+	if b.Low <= 0 || b.Low > min(b.Open, b.Close) {
+		fields["low"] = "must be positive and at most open and close"
+	}
+	if b.High < max(b.Open, b.Close, b.Low) {
+		fields["high"] = "must be at least open, close and low"
+	}
+	if b.Volume < 0 {
+		fields["volume"] = "must not be negative"
+	}
+
+	return fields
+}
+
+// Limits for BarModel.Query
+const (
+	DefaultBarLimit = 500
+	MaxBarLimit     = 5000
+)
+
+type BarModelInterface interface {
+	InsertBatch(ctx context.Context, bars []Bar) (int, error)
+	Query(ctx context.Context, symbol, timeframe string, from, to time.Time, limit int) ([]Bar, error)
+}
+
+// BarModel wraps a database connection pool for OHLCV market data
+type BarModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled bars.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *BarModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// InsertBatch inserts bars in a single transaction through one prepared
+// statement and returns how many were new. Bars already stored for the same
+// symbol, timeframe and timestamp are skipped, so re-sending a batch is
+// harmless. Any other failure rolls back the whole batch.
+func (m *BarModel) InsertBatch(ctx context.Context, bars []Bar) (inserted int, err error) {
+	if len(bars) == 0 {
+		return 0, nil
+	}
+
+	query := `
+	INSERT INTO bars (symbol, timeframe, ts, open, high, low, close, volume)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (symbol, timeframe, ts) DO NOTHING`
+
+	// One observation covers the whole batch, so the slow query log reports
+	// the insert statement with the batch's total duration
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "bars.insert_batch", query)
+	defer func() { err = done(err) }()
+
+	tx, err := BeginTx(ctx, m.DB, m.Logger)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, m.rebind(query))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare bar insert: %w", err)
+	}
+	defer stmt.Close()
+
+	start := time.Now()
+	for i := range bars {
+		b := &bars[i]
+		b.Symbol = NormalizeSymbol(b.Symbol)
+		// Store UTC so equal instants always produce the same key
+		b.Timestamp = b.Timestamp.UTC()
+
+		result, err := stmt.ExecContext(ctx, b.Symbol, b.Timeframe, b.Timestamp, b.Open, b.High, b.Low, b.Close, b.Volume)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert bar %d (%s %s %s): %w", i, b.Symbol, b.Timeframe, b.Timestamp.Format(time.RFC3339), err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		inserted += int(rows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit bars: %w", err)
+	}
+
+	m.Logger.Info("Bars inserted",
+		zap.Int("received", len(bars)),
+		zap.Int("inserted", inserted),
+		zap.Duration("duration", time.Since(start)))
+
+	return inserted, nil
+}
+
+// Query returns up to limit bars for symbol and timeframe with timestamps in
+// [from, to], oldest first. A zero from or to leaves that end open. limit is
+// clamped to 1..MaxBarLimit, with DefaultBarLimit used when it is 0 or less.
+func (m *BarModel) Query(ctx context.Context, symbol, timeframe string, from, to time.Time, limit int) ([]Bar, error) {
+	switch {
+	case limit <= 0:
+		limit = DefaultBarLimit
+	case limit > MaxBarLimit:
+		limit = MaxBarLimit
+	}
+	symbol = NormalizeSymbol(symbol)
+
+	conditions := []string{"symbol = ?", "timeframe = ?"}
+	args := []any{symbol, timeframe}
+	if !from.IsZero() {
+		conditions = append(conditions, "ts >= ?")
+		args = append(args, from.UTC())
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "ts <= ?")
+		args = append(args, to.UTC())
+	}
+	args = append(args, limit)
+
+	query := `
+	SELECT symbol, timeframe, ts, open, high, low, close, volume
+	FROM bars
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY ts
+	LIMIT ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "bars.query", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), args...)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s %s bars: %w", symbol, timeframe, err)
+	}
+	defer rows.Close()
+
+	bars := []Bar{}
+	for rows.Next() {
+		var b Bar
+		if err := rows.Scan(&b.Symbol, &b.Timeframe, &b.Timestamp, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan bar: %w", err)
+		}
+		bars = append(bars, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query %s %s bars: %w", symbol, timeframe, err)
+	}
+
+	return bars, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InMemoryBarModel is a BarModelInterface kept in a map, for tests and demos
+// that don't want a database. Like BarModel it skips bars already stored for
+// the same symbol, timeframe and timestamp, but it leaves validating bars to
+// its callers. The zero value is ready to use.
+type InMemoryBarModel struct {
+	mu   sync.Mutex
+	bars map[barKey]Bar
+}
+
+// barKey identifies a bar, as the unique constraint on bars does
+type barKey struct {
+	symbol    string
+	timeframe string
+	ts        time.Time
+}
+
+var _ BarModelInterface = (*InMemoryBarModel)(nil)
+
+// NewInMemoryBarModel returns an empty InMemoryBarModel
+func NewInMemoryBarModel() *InMemoryBarModel {
+	return &InMemoryBarModel{}
+}
+
+// InsertBatch stores bars and returns how many were new. Symbols are
+// normalized and timestamps stored in UTC, as BarModel.InsertBatch does.
+func (m *InMemoryBarModel) InsertBatch(ctx context.Context, bars []Bar) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.bars == nil {
+		m.bars = make(map[barKey]Bar)
+	}
+	inserted := 0
+	for i := range bars {
+		b := &bars[i]
+		b.Symbol = NormalizeSymbol(b.Symbol)
+		b.Timestamp = b.Timestamp.UTC()
+
+		key := barKey{symbol: b.Symbol, timeframe: b.Timeframe, ts: b.Timestamp}
+		if _, ok := m.bars[key]; ok {
+			continue
+		}
+		m.bars[key] = *b
+		inserted++
+	}
+	return inserted, nil
+}
+
+// Query returns up to limit bars for symbol and timeframe with timestamps in
+// [from, to], oldest first, with the same defaults as BarModel.Query
+func (m *InMemoryBarModel) Query(ctx context.Context, symbol, timeframe string, from, to time.Time, limit int) ([]Bar, error) {
+	switch {
+	case limit <= 0:
+		limit = DefaultBarLimit
+	case limit > MaxBarLimit:
+		limit = MaxBarLimit
+	}
+	symbol = NormalizeSymbol(symbol)
+
+	m.mu.Lock()
+	bars := []Bar{}
+	for key, b := range m.bars {
+		switch {
+		case key.symbol != symbol || key.timeframe != timeframe:
+		case !from.IsZero() && key.ts.Before(from):
+		case !to.IsZero() && key.ts.After(to):
+		default:
+			bars = append(bars, b)
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(bars, func(a, b Bar) int { return a.Timestamp.Compare(b.Timestamp) })
+	return bars[:min(limit, len(bars))], nil
+}
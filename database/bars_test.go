@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testBars returns n consecutive one-minute AAPL bars starting at start
+func testBars(start time.Time, n int) []Bar {
+	bars := make([]Bar, n)
+	for i := range bars {
+		bars[i] = Bar{
+			Symbol:    "aapl",
+			Timeframe: "1m",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Open:      100,
+			High:      102,
+			Low:       99,
+			Close:     101,
+			Volume:    1000,
+		}
+	}
+	return bars
+}
+
+func TestBarInsertBatchSkipsDuplicates(t *testing.T) {
+	start := time.Date(2024, 1, 2, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		bars BarModelInterface
+	}{
+		{"sql", newTestStore(t).Bar},
+		{"memory", NewInMemoryBarModel()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			inserted, err := tt.bars.InsertBatch(ctx, testBars(start, 3))
+			if err != nil {
+				t.Fatalf("InsertBatch: %v", err)
+			}
+			if inserted != 3 {
+				t.Errorf("first InsertBatch inserted %d, want 3", inserted)
+			}
+
+			inserted, err = tt.bars.InsertBatch(ctx, testBars(start, 4))
+			if err != nil {
+				t.Fatalf("second InsertBatch: %v", err)
+			}
+			if inserted != 1 {
+				t.Errorf("second InsertBatch inserted %d, want only the new bar", inserted)
+			}
+
+			bars, err := tt.bars.Query(ctx, "AAPL", "1m", time.Time{}, time.Time{}, 0)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if len(bars) != 4 {
+				t.Errorf("stored %d bars, want 4", len(bars))
+			}
+		})
+	}
+}
+
+func TestBarValidate(t *testing.T) {
+	valid := testBars(time.Now(), 1)[0]
+	if fields := valid.Validate(); len(fields) != 0 {
+		t.Errorf("Validate of a valid bar = %v", fields)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(b *Bar)
+		field  string
+	}{
+		{"empty symbol", func(b *Bar) { b.Symbol = " " }, "symbol"},
+		{"unknown timeframe", func(b *Bar) { b.Timeframe = "2m" }, "timeframe"},
+		{"missing timestamp", func(b *Bar) { b.Timestamp = time.Time{} }, "ts"},
+		{"high below open", func(b *Bar) { b.High = 99.5 }, "high"},
+		{"low above close", func(b *Bar) { b.Low = 101.5 }, "low"},
+		{"negative volume", func(b *Bar) { b.Volume = -1 }, "volume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bar := valid
+			tt.modify(&bar)
+			if fields := bar.Validate(); fields[tt.field] == "" {
+				t.Errorf("Validate = %v, want a problem with %s", fields, tt.field)
+			}
+		})
+	}
+}
+
+func TestBarQueryRange(t *testing.T) {
+	start := time.Date(2024, 1, 2, 14, 30, 0, 0, time.UTC)
+	minute := func(i int) time.Time { return start.Add(time.Duration(i) * time.Minute) }
+
+	tests := []struct {
+		name string
+		bars BarModelInterface
+	}{
+		{"sql", newTestStore(t).Bar},
+		{"memory", NewInMemoryBarModel()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := tt.bars.InsertBatch(ctx, testBars(start, 10)); err != nil {
+				t.Fatalf("InsertBatch: %v", err)
+			}
+
+			queries := []struct {
+				name      string
+				timeframe string
+				from, to  time.Time
+				limit     int
+				want      []time.Time
+			}{
+				{"inclusive range", "1m", minute(2), minute(4), 0, []time.Time{minute(2), minute(3), minute(4)}},
+				{"open start", "1m", time.Time{}, minute(1), 0, []time.Time{minute(0), minute(1)}},
+				{"limited", "1m", minute(5), time.Time{}, 2, []time.Time{minute(5), minute(6)}},
+				{"other zone", "1m", minute(9).In(time.FixedZone("EST", -5*3600)), time.Time{}, 0, []time.Time{minute(9)}},
+				{"empty range", "1m", minute(20), minute(30), 0, nil},
+				{"other timeframe", "1h", time.Time{}, time.Time{}, 0, nil},
+			}
+			for _, q := range queries {
+				bars, err := tt.bars.Query(ctx, "aapl", q.timeframe, q.from, q.to, q.limit)
+				if err != nil {
+					t.Fatalf("%s: Query: %v", q.name, err)
+				}
+				if bars == nil {
+					t.Errorf("%s: Query returned nil, want an empty slice", q.name)
+				}
+				var got []time.Time
+				for _, b := range bars {
+					got = append(got, b.Timestamp.UTC())
+				}
+				if len(got) != len(q.want) {
+					t.Errorf("%s: Query = %v, want %v", q.name, got, q.want)
+					continue
+				}
+				for i := range got {
+					if !got[i].Equal(q.want[i]) {
+						t.Errorf("%s: Query = %v, want %v", q.name, got, q.want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Constraint naming conventions
+//
+// UNIQUE and NOT NULL violations are reported by SQLite as "table.column", so
+// they need no naming. SQLite only reports the name of a CHECK constraint, so
+// CHECK constraints must be named chk_<table>__<column> (two underscores
+// between table and column) for the failing field to be recovered, e.g.
+//
+//	CONSTRAINT chk_orders__quantity CHECK (quantity > 0)
+//
+// FOREIGN KEY violations carry no table or column information at all, so
+// they are reported without fields.
+
+// ConstraintKind classifies a constraint violation for API responses
+type ConstraintKind string
+
+const (
+	// ConstraintDuplicate is a UNIQUE or PRIMARY KEY violation
+	ConstraintDuplicate ConstraintKind = "duplicate"
+	// ConstraintInvalid is a CHECK or NOT NULL violation
+	ConstraintInvalid ConstraintKind = "invalid"
+	// ConstraintForeignKey is a FOREIGN KEY violation
+	ConstraintForeignKey ConstraintKind = "foreign_key"
+)
+
+// ConstraintError describes a violated constraint in terms of the table and
+// fields involved, so the API layer can report field-level errors
+type ConstraintError struct {
+	Kind   ConstraintKind
+	Table  string
+	Fields []string
+	Err    error
+}
+
+func (e *ConstraintError) Error() string {
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("%s constraint violated", e.Kind)
+	}
+	return fmt.Sprintf("%s constraint violated on %s(%s)", e.Kind, e.Table, strings.Join(e.Fields, ", "))
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// AsConstraintError inspects err for a SQLite or Postgres constraint violation
+// and, if it finds one, describes it as a ConstraintError
+func AsConstraintError(err error) (*ConstraintError, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return asPostgresConstraintError(err, pqErr)
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return nil, false
+	}
+
+	// Messages look like "UNIQUE constraint failed: users.email"
+	_, detail, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+	cerr := &ConstraintError{Err: err}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		cerr.Kind = ConstraintDuplicate
+		cerr.Table, cerr.Fields = parseConstraintColumns(detail)
+	case sqlite3.ErrConstraintNotNull:
+		cerr.Kind = ConstraintInvalid
+		cerr.Table, cerr.Fields = parseConstraintColumns(detail)
+	case sqlite3.ErrConstraintCheck:
+		cerr.Kind = ConstraintInvalid
+		cerr.Table, cerr.Fields = parseCheckConstraintName(detail)
+	case sqlite3.ErrConstraintForeignKey:
+		cerr.Kind = ConstraintForeignKey
+	default:
+		return nil, false
+	}
+
+	return cerr, true
+}
+
+// asPostgresConstraintError describes a Postgres integrity constraint
+// violation (SQLSTATE class 23) as a ConstraintError
+func asPostgresConstraintError(err error, pqErr *pq.Error) (*ConstraintError, bool) {
+	cerr := &ConstraintError{Err: err, Table: pqErr.Table}
+
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		cerr.Kind = ConstraintDuplicate
+		cerr.Fields = parseKeyDetail(pqErr.Detail)
+	case "not_null_violation":
+		cerr.Kind = ConstraintInvalid
+		if pqErr.Column != "" {
+			cerr.Fields = []string{pqErr.Column}
+		}
+	case "check_violation":
+		cerr.Kind = ConstraintInvalid
+		cerr.Table, cerr.Fields = parseCheckConstraintName(pqErr.Constraint)
+	case "foreign_key_violation":
+		cerr.Kind = ConstraintForeignKey
+		cerr.Table = ""
+	default:
+		return nil, false
+	}
+
+	return cerr, true
+}
+
+// parseKeyDetail parses the columns out of a Postgres unique violation detail
+// such as "Key (email)=(a@example.com) already exists."
+func parseKeyDetail(detail string) []string {
+	_, rest, ok := strings.Cut(detail, "Key (")
+	if !ok {
+		return nil
+	}
+	columns, _, ok := strings.Cut(rest, ")=")
+	if !ok {
+		return nil
+	}
+	return strings.Split(columns, ", ")
+}
+
+// parseConstraintColumns parses "table.a, table.b" into the table and columns
+func parseConstraintColumns(detail string) (string, []string) {
+	var table string
+	var fields []string
+	for _, column := range strings.Split(detail, ", ") {
+		t, field, ok := strings.Cut(strings.TrimSpace(column), ".")
+		if !ok {
+			continue
+		}
+		table = t
+		fields = append(fields, field)
+	}
+	return table, fields
+}
+
+// parseCheckConstraintName parses a chk_<table>__<column> constraint name
+func parseCheckConstraintName(name string) (string, []string) {
+	table, field, ok := strings.Cut(strings.TrimPrefix(name, "chk_"), "__")
+	if !ok || !strings.HasPrefix(name, "chk_") {
+		return "", nil
+	}
+	return table, []string{field}
+}
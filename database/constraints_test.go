@@ -0,0 +1,139 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+func TestAsConstraintErrorSQLite(t *testing.T) {
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	user := insertTestUser(t, s, "alice")
+
+	tests := []struct {
+		name   string
+		query  string
+		args   []any
+		kind   ConstraintKind
+		table  string
+		fields []string
+	}{
+		{
+			name:   "unique",
+			query:  "INSERT INTO users (username, email) VALUES (?, ?)",
+			args:   []any{"alice2", user.Email},
+			kind:   ConstraintDuplicate,
+			table:  "users",
+			fields: []string{"email"},
+		},
+		{
+			name:   "check",
+			query:  "INSERT INTO orders (user_id, symbol, side, type, quantity) VALUES (?, 'AAPL', 'buy', 'market', -1)",
+			args:   []any{user.UserID},
+			kind:   ConstraintInvalid,
+			table:  "orders",
+			fields: []string{"quantity"},
+		},
+		{
+			name:   "not null",
+			query:  "INSERT INTO orders (user_id, symbol, type, quantity) VALUES (?, 'AAPL', 'market', 1)",
+			args:   []any{user.UserID},
+			kind:   ConstraintInvalid,
+			table:  "orders",
+			fields: []string{"side"},
+		},
+		{
+			name:  "foreign key",
+			query: "INSERT INTO orders (user_id, symbol, side, type, quantity) VALUES (?, 'AAPL', 'buy', 'market', 1)",
+			args:  []any{user.UserID + 100},
+			kind:  ConstraintForeignKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := dm.DB.Exec(tt.query, tt.args...)
+			if err == nil {
+				t.Fatal("insert succeeded, want a constraint violation")
+			}
+
+			cerr, ok := AsConstraintError(err)
+			if !ok {
+				t.Fatalf("AsConstraintError(%v) found no constraint error", err)
+			}
+			if cerr.Kind != tt.kind || cerr.Table != tt.table || !slices.Equal(cerr.Fields, tt.fields) {
+				t.Errorf("constraint error = %s %s %v, want %s %s %v", cerr.Kind, cerr.Table, cerr.Fields, tt.kind, tt.table, tt.fields)
+			}
+			if !errors.Is(cerr, err) {
+				t.Error("constraint error doesn't wrap the driver error")
+			}
+		})
+	}
+}
+
+func TestAsConstraintErrorPostgres(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *pq.Error
+		kind   ConstraintKind
+		table  string
+		fields []string
+	}{
+		{
+			name:   "unique",
+			err:    &pq.Error{Code: "23505", Table: "users", Detail: "Key (email)=(a@example.com) already exists."},
+			kind:   ConstraintDuplicate,
+			table:  "users",
+			fields: []string{"email"},
+		},
+		{
+			name:   "check",
+			err:    &pq.Error{Code: "23514", Table: "orders", Constraint: "chk_orders__quantity"},
+			kind:   ConstraintInvalid,
+			table:  "orders",
+			fields: []string{"quantity"},
+		},
+		{
+			name:   "not null",
+			err:    &pq.Error{Code: "23502", Table: "orders", Column: "side"},
+			kind:   ConstraintInvalid,
+			table:  "orders",
+			fields: []string{"side"},
+		},
+		{
+			name: "foreign key",
+			err:  &pq.Error{Code: "23503", Table: "orders", Constraint: "orders_user_id_fkey"},
+			kind: ConstraintForeignKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("insert: %w", tt.err)
+
+			cerr, ok := AsConstraintError(err)
+			if !ok {
+				t.Fatalf("AsConstraintError(%v) found no constraint error", err)
+			}
+			if cerr.Kind != tt.kind || cerr.Table != tt.table || !slices.Equal(cerr.Fields, tt.fields) {
+				t.Errorf("constraint error = %s %s %v, want %s %s %v", cerr.Kind, cerr.Table, cerr.Fields, tt.kind, tt.table, tt.fields)
+			}
+		})
+	}
+
+	if _, ok := AsConstraintError(&pq.Error{Code: "42P01"}); ok {
+		t.Error("AsConstraintError matched an undefined_table error")
+	}
+}
+
+func TestAsConstraintErrorIgnoresOtherErrors(t *testing.T) {
+	if _, ok := AsConstraintError(errors.New("boom")); ok {
+		t.Error("AsConstraintError matched a plain error")
+	}
+	if _, ok := AsConstraintError(nil); ok {
+		t.Error("AsConstraintError matched nil")
+	}
+}
@@ -0,0 +1,22 @@
+package db
+
+import "context"
+
+// contextKey is an unexported type for context keys defined in this package
+type contextKey string
+
+const correlationIDContextKey = contextKey("correlation_id")
+
+// WithCorrelationID returns a copy of ctx carrying id, which ties the work an
+// operation sets off asynchronously, such as published order updates, back
+// to it. The API uses the HTTP request id.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey, id)
+}
+
+// CorrelationID returns the id stored by WithCorrelationID, or "" if there is
+// none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDContextKey).(string)
+	return id
+}
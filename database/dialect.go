@@ -0,0 +1,102 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported database drivers
+const (
+	// DriverSQLite stores data in a local SQLite file; the DSN is its path
+	DriverSQLite = "sqlite3"
+	// DriverPostgres connects to PostgreSQL; the DSN is a libpq connection
+	// string or postgres:// URL
+	DriverPostgres = "postgres"
+)
+
+// validateDriver checks that driver is one of the supported drivers
+func validateDriver(driver string) error {
+	switch driver {
+	case DriverSQLite, DriverPostgres:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedDriver, driver)
+	}
+}
+
+// Rebind rewrites the ? placeholders in query into the syntax driver expects.
+// Queries are written with ?, which SQLite accepts as is; for Postgres they
+// become $1, $2, ... Question marks inside quoted strings are left alone.
+func Rebind(driver, query string) string {
+	if driver != DriverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote rune
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// ddlReplacer translates the SQLite column types used by the migrations into
+// their Postgres equivalents
+var ddlReplacer = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY",
+	"DATETIME", "TIMESTAMP",
+	" REAL", " DOUBLE PRECISION",
+	" BLOB", " BYTEA",
+)
+
+// translateDDL adapts migration SQL, written for SQLite, to driver
+func translateDDL(driver, ddl string) string {
+	if driver != DriverPostgres {
+		return ddl
+	}
+	return ddlReplacer.Replace(ddl)
+}
+
+// sqliteTimestampLayout is the format of SQLite's CURRENT_TIMESTAMP
+const sqliteTimestampLayout = "2006-01-02 15:04:05"
+
+// timestampArg converts t for comparison with a column filled by
+// CURRENT_TIMESTAMP. SQLite stores those as UTC text that only compares
+// correctly with text in the same layout; Postgres compares times natively.
+func timestampArg(driver string, t time.Time) any {
+	if driver == DriverPostgres {
+		return t
+	}
+	return t.UTC().Format(sqliteTimestampLayout)
+}
+
+// unixSeconds returns an expression for column, a timestamp, as whole
+// seconds since the Unix epoch
+func unixSeconds(driver, column string) string {
+	if driver == DriverPostgres {
+		return "CAST(EXTRACT(EPOCH FROM " + column + ") AS BIGINT)"
+	}
+	return "CAST(strftime('%s', " + column + ") AS INTEGER)"
+}
+
+// rebind rewrites query's placeholders for the manager's driver
+func (dm *DatabaseManager) rebind(query string) string {
+	return Rebind(dm.Driver, query)
+}
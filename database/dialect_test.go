@@ -0,0 +1,68 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNewDatabaseManagerSelectsDriver(t *testing.T) {
+	tests := []struct {
+		driver  string
+		dsn     string
+		dbPath  string
+		wantErr error
+	}{
+		{DriverSQLite, "data/app.db", "data/app.db", nil},
+		{DriverPostgres, "postgres://localhost/trader", "", nil},
+		{"mysql", "root@/trader", "", ErrUnsupportedDriver},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			dm, err := NewDatabaseManager(tt.driver, tt.dsn, zap.NewNop())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewDatabaseManager error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if dm.Driver != tt.driver || dm.DSN != tt.dsn || dm.DBPath != tt.dbPath {
+				t.Errorf("manager = %s %q %q, want %s %q %q", dm.Driver, dm.DSN, dm.DBPath, tt.driver, tt.dsn, tt.dbPath)
+			}
+		})
+	}
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		query  string
+		want   string
+	}{
+		{"sqlite unchanged", DriverSQLite, "SELECT * FROM users WHERE id = ? AND email = ?", "SELECT * FROM users WHERE id = ? AND email = ?"},
+		{"postgres numbered", DriverPostgres, "SELECT * FROM users WHERE id = ? AND email = ?", "SELECT * FROM users WHERE id = $1 AND email = $2"},
+		{"postgres quoted", DriverPostgres, "SELECT '?', \"a?\" FROM t WHERE x = ?", "SELECT '?', \"a?\" FROM t WHERE x = $1"},
+		{"postgres no placeholders", DriverPostgres, "SELECT 1", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Rebind(tt.driver, tt.query); got != tt.want {
+				t.Errorf("Rebind = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateDDL(t *testing.T) {
+	ddl := "CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, price REAL, data BLOB, created_at DATETIME)"
+
+	if got := translateDDL(DriverSQLite, ddl); got != ddl {
+		t.Errorf("SQLite DDL changed to %q", got)
+	}
+	want := "CREATE TABLE t (id SERIAL PRIMARY KEY, price DOUBLE PRECISION, data BYTEA, created_at TIMESTAMP)"
+	if got := translateDDL(DriverPostgres, ddl); got != want {
+		t.Errorf("Postgres DDL = %q, want %q", got, want)
+	}
+}
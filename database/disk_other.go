@@ -0,0 +1,10 @@
+//go:build !unix
+
+package db
+
+import "errors"
+
+// freeDiskBytes is not supported on this platform
+func freeDiskBytes(path string) (uint64, error) {
+	return 0, errors.New("disk space check is not supported on this platform")
+}
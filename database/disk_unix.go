@@ -0,0 +1,15 @@
+//go:build unix
+
+package db
+
+import "syscall"
+
+// freeDiskBytes returns the space available to unprivileged users on the
+// filesystem containing path
+func freeDiskBytes(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNoRecord is returned when a lookup matches no rows
+	ErrNoRecord = errors.New("db: no matching record found")
+
+	// ErrInvalidCredentials is returned by Authenticate when the email is
+	// unknown or the password doesn't match
+	ErrInvalidCredentials = errors.New("db: invalid credentials")
+
+	// ErrDuplicateEmail is returned when an email is already taken by another user
+	ErrDuplicateEmail = errors.New("db: duplicate email")
+
+	// ErrDuplicateUsername is returned when a username is already taken by another user
+	ErrDuplicateUsername = errors.New("db: duplicate username")
+
+	// ErrReferenced is returned when a record can't be deleted because other
+	// records still reference it through a foreign key
+	ErrReferenced = errors.New("db: record is still referenced by other records")
+
+	// ErrInvalidTransition is returned by OrderModel.UpdateStatus when the
+	// order's current status can't move to the requested one
+	ErrInvalidTransition = errors.New("db: invalid order status transition")
+
+	// ErrInsufficientFunds is returned by AccountModel.Debit when the account
+	// balance is lower than the amount
+	ErrInsufficientFunds = errors.New("db: insufficient funds")
+
+	// ErrIdempotencyMismatch is returned by IdempotencyModel.Reserve when the
+	// key was already used for a different request
+	ErrIdempotencyMismatch = errors.New("db: idempotency key reused for a different request")
+
+	// ErrIdempotencyInProgress is returned by IdempotencyModel.Reserve while
+	// the first request with the key is still running
+	ErrIdempotencyInProgress = errors.New("db: idempotency key is in use by a request in progress")
+
+	// ErrLowDiskSpace is returned by CheckDiskSpace when the filesystem holding
+	// the database is below the required free space
+	ErrLowDiskSpace = errors.New("db: insufficient free disk space")
+
+	// ErrUnsupportedDriver is returned for an unknown database driver, or by
+	// operations that only exist for SQLite when running on another driver
+	ErrUnsupportedDriver = errors.New("db: unsupported database driver")
+
+	// ErrVacuumInTransaction is returned by Vacuum while a transaction begun
+	// through BeginTx is still open, since VACUUM would wait on or break it.
+	ErrVacuumInTransaction = errors.New("db: cannot vacuum while a transaction is open")
+
+	// ErrInvalidTimestamp is returned by UserModel.InsertWithTimestamps when a
+	// supplied timestamp is out of range
+	ErrInvalidTimestamp = errors.New("db: invalid timestamp")
+
+	// ErrDatabaseUnavailable is returned when the connection pool has been
+	// closed, e.g. by a request that outlives shutdown
+	ErrDatabaseUnavailable = errors.New("db: database is unavailable")
+)
+
+// unavailableError wraps err with ErrDatabaseUnavailable if it reports a
+// closed pool or connection, and returns it unchanged otherwise. database/sql
+// doesn't export its closed-pool error, so that one is matched by message.
+func unavailableError(err error) error {
+	if err == nil || errors.Is(err, ErrDatabaseUnavailable) {
+		return err
+	}
+	if errors.Is(err, sql.ErrConnDone) || strings.Contains(err.Error(), "sql: database is closed") {
+		return fmt.Errorf("%w: %w", ErrDatabaseUnavailable, err)
+	}
+	return err
+}
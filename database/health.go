@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxHealthPingTimeout bounds each monitor ping, so a wedged database shows
+// up as a failure instead of leaving the last status in place
+const maxHealthPingTimeout = 5 * time.Second
+
+// healthErrorAfter is how many consecutive failed pings are logged as
+// warnings before they escalate to errors
+const healthErrorAfter = 3
+
+// pingStatus is the outcome of the health monitor's latest ping
+type pingStatus struct {
+	mu       sync.Mutex
+	ok       bool
+	at       time.Time
+	failures int
+}
+
+// StartHealthMonitor pings the database straight away and then every
+// interval in a background goroutine until ctx is cancelled. Failures are
+// logged as warnings, and as errors once they persist; the latest outcome is
+// available from LastPingStatus.
+func (dm *DatabaseManager) StartHealthMonitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		timeout := min(interval, maxHealthPingTimeout)
+		for {
+			dm.healthPing(ctx, timeout)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// healthPing pings once and records and logs the outcome
+func (dm *DatabaseManager) healthPing(ctx context.Context, timeout time.Duration) {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	err := dm.Ping(pingCtx)
+	cancel()
+	// A ping cut short by the monitor stopping says nothing about the database
+	if ctx.Err() != nil {
+		return
+	}
+
+	s := &dm.pingStatus
+	s.mu.Lock()
+	wasFailing := s.failures > 0
+	s.ok, s.at = err == nil, time.Now()
+	if err != nil {
+		s.failures++
+	} else {
+		s.failures = 0
+	}
+	failures := s.failures
+	s.mu.Unlock()
+
+	switch {
+	case err == nil && wasFailing:
+		dm.logger.Info("Database ping recovered")
+	case err == nil:
+	case failures >= healthErrorAfter || errors.Is(err, ErrDatabaseUnavailable):
+		dm.logger.Error("Database ping failed", zap.Int("consecutive_failures", failures), zap.Error(err))
+	default:
+		dm.logger.Warn("Database ping failed", zap.Int("consecutive_failures", failures), zap.Error(err))
+	}
+}
+
+// LastPingStatus reports whether the health monitor's latest ping succeeded
+// and when it completed. at is zero if the monitor hasn't pinged yet.
+func (dm *DatabaseManager) LastPingStatus() (ok bool, at time.Time) {
+	dm.pingStatus.mu.Lock()
+	defer dm.pingStatus.mu.Unlock()
+	return dm.pingStatus.ok, dm.pingStatus.at
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newObservedManager is newTestManager logging to an observer
+func newObservedManager(t *testing.T) (*DatabaseManager, *observer.ObservedLogs) {
+	t.Helper()
+
+	core, logs := observer.New(zap.InfoLevel)
+	dm, err := NewDatabaseManager(DriverSQLite, filepath.Join(t.TempDir(), "test.db"), zap.New(core))
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	if err := dm.InitializeDatabase(); err != nil {
+		t.Fatalf("InitializeDatabase: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	logs.TakeAll()
+	return dm, logs
+}
+
+func TestHealthPingStatusFlips(t *testing.T) {
+	dm, logs := newObservedManager(t)
+	ctx := context.Background()
+
+	if ok, at := dm.LastPingStatus(); ok || !at.IsZero() {
+		t.Errorf("LastPingStatus before any ping = %v, %v, want false and the zero time", ok, at)
+	}
+
+	dm.healthPing(ctx, time.Second)
+	if ok, at := dm.LastPingStatus(); !ok || at.IsZero() {
+		t.Errorf("LastPingStatus after a good ping = %v, %v, want true", ok, at)
+	}
+
+	// A ping that times out straight away fails, and is logged as a warning
+	// until it has failed healthErrorAfter times in a row
+	for i := 1; i <= healthErrorAfter; i++ {
+		dm.healthPing(ctx, 0)
+		if ok, _ := dm.LastPingStatus(); ok {
+			t.Fatalf("LastPingStatus after failed ping %d = true, want false", i)
+		}
+	}
+	levels := []zapcore.Level{}
+	for _, entry := range logs.FilterMessage("Database ping failed").All() {
+		levels = append(levels, entry.Level)
+	}
+	want := []zapcore.Level{zapcore.WarnLevel, zapcore.WarnLevel, zapcore.ErrorLevel}
+	if len(levels) != len(want) || levels[0] != want[0] || levels[1] != want[1] || levels[2] != want[2] {
+		t.Errorf("failure log levels = %v, want %v", levels, want)
+	}
+
+	dm.healthPing(ctx, time.Second)
+	if ok, _ := dm.LastPingStatus(); !ok {
+		t.Error("LastPingStatus after recovery = false, want true")
+	}
+	if n := logs.FilterMessage("Database ping recovered").Len(); n != 1 {
+		t.Errorf("got %d recovery logs, want 1", n)
+	}
+}
+
+func TestHealthMonitorStopsOnCancel(t *testing.T) {
+	dm, logs := newObservedManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dm.StartHealthMonitor(ctx, 10*time.Millisecond)
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if ok, at := dm.LastPingStatus(); ok == want && !at.IsZero() {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("LastPingStatus never became %v", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor(true)
+	dm.Close()
+	waitFor(false)
+	if logs.FilterMessage("Database ping failed").FilterLevelExact(zapcore.ErrorLevel).Len() == 0 {
+		t.Error("a closed database wasn't logged as an error")
+	}
+
+	cancel()
+	time.Sleep(30 * time.Millisecond)
+	_, stopped := dm.LastPingStatus()
+	time.Sleep(50 * time.Millisecond)
+	if _, at := dm.LastPingStatus(); !at.Equal(stopped) {
+		t.Error("the monitor kept pinging after its context was cancelled")
+	}
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// IdempotencyTTL is how long a stored response is replayed for its key;
+// after that the key can be reused
+const IdempotencyTTL = 24 * time.Hour
+
+// StoredResponse is the response recorded for an idempotency key
+type StoredResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+type IdempotencyModelInterface interface {
+	Reserve(ctx context.Context, key, requestHash string) (*StoredResponse, error)
+	Complete(ctx context.Context, key string, statusCode int, body []byte) error
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyModel records the responses to requests made with an
+// Idempotency-Key header so retries get the original response
+type IdempotencyModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled idempotency.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *IdempotencyModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// Reserve claims key for a request whose identity is requestHash. It returns
+// nil when the key is new, and the caller should run the request and then
+// Complete or Release the key. For a key already used by the same request it
+// returns the stored response. It returns ErrIdempotencyMismatch when the key
+// was used for a different request, and ErrIdempotencyInProgress while the
+// first request with the key hasn't finished.
+func (m *IdempotencyModel) Reserve(ctx context.Context, key, requestHash string) (_ *StoredResponse, err error) {
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "idempotency.reserve", "")
+	defer func() { err = done(err) }()
+
+	now := time.Now().UTC()
+
+	// Forget an expired use of the key so it can be claimed again
+	_, err = m.DB.ExecContext(ctx, m.rebind(`DELETE FROM idempotency_keys WHERE key = ? AND created_at < ?`), key, now.Add(-IdempotencyTTL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire idempotency key: %w", err)
+	}
+
+	// A status code of 0 marks the key as claimed by a request in progress
+	result, err := m.DB.ExecContext(ctx, m.rebind(`
+	INSERT INTO idempotency_keys (key, request_hash, status_code, created_at)
+	VALUES (?, ?, 0, ?)
+	ON CONFLICT (key) DO NOTHING`), key, requestHash, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	var storedHash string
+	var body []byte
+	stored := &StoredResponse{}
+	err = m.DB.QueryRowContext(ctx, m.rebind(`
+	SELECT request_hash, status_code, response_body FROM idempotency_keys WHERE key = ?`), key).Scan(&storedHash, &stored.StatusCode, &body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Released between the insert and the select; the client can retry
+			return nil, ErrIdempotencyInProgress
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	switch {
+	case storedHash != requestHash:
+		return nil, ErrIdempotencyMismatch
+	case stored.StatusCode == 0:
+		return nil, ErrIdempotencyInProgress
+	}
+	stored.Body = body
+	return stored, nil
+}
+
+// Complete stores the response for a key claimed with Reserve
+func (m *IdempotencyModel) Complete(ctx context.Context, key string, statusCode int, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = ?, response_body = ? WHERE key = ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "idempotency.complete", query)
+	_, err := m.DB.ExecContext(ctx, m.rebind(query), statusCode, body, key)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release forgets a key claimed with Reserve without storing a response, so
+// a retry runs the request again
+func (m *IdempotencyModel) Release(ctx context.Context, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "idempotency.release", query)
+	_, err := m.DB.ExecContext(ctx, m.rebind(query), key)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
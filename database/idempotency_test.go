@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIdempotencyReserveLifecycle(t *testing.T) {
+	m := newTestStore(t).IdempotencyKeys
+	ctx := context.Background()
+
+	if stored, err := m.Reserve(ctx, "key-1", "hash-a"); err != nil || stored != nil {
+		t.Fatalf("first Reserve = %v, %v, want nil, nil", stored, err)
+	}
+	if _, err := m.Reserve(ctx, "key-1", "hash-a"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Errorf("Reserve before Complete = %v, want ErrIdempotencyInProgress", err)
+	}
+
+	if err := m.Complete(ctx, "key-1", 201, []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	stored, err := m.Reserve(ctx, "key-1", "hash-a")
+	if err != nil || stored == nil || stored.StatusCode != 201 || string(stored.Body) != `{"id":1}` {
+		t.Errorf("replayed Reserve = %+v, %v, want the stored 201 response", stored, err)
+	}
+	if _, err := m.Reserve(ctx, "key-1", "hash-b"); !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Errorf("Reserve with another hash = %v, want ErrIdempotencyMismatch", err)
+	}
+}
+
+func TestIdempotencyReleaseFreesKey(t *testing.T) {
+	m := newTestStore(t).IdempotencyKeys
+	ctx := context.Background()
+
+	if _, err := m.Reserve(ctx, "key-1", "hash-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Release(ctx, "key-1"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if stored, err := m.Reserve(ctx, "key-1", "hash-b"); err != nil || stored != nil {
+		t.Errorf("Reserve after Release = %v, %v, want nil, nil", stored, err)
+	}
+}
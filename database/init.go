@@ -1,19 +1,43 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 )
 
 // DatabaseManager handles all database operations
 type DatabaseManager struct {
-	DB     *sql.DB
+	DB *sql.DB
+	// Driver is DriverSQLite or DriverPostgres
+	Driver string
+	// DSN is the connection string passed to the driver
+	DSN string
+	// DBPath is the SQLite database file; empty for other drivers
 	DBPath string
 	logger *zap.Logger
+	// migrateMu serializes ApplyMigrations
+	migrateMu sync.Mutex
+	// observer receives query timings; see SetQueryObserver
+	observer QueryObserver
+	// closed is set by the first Close
+	closed atomic.Bool
+	// pingStatus is kept up to date by StartHealthMonitor
+	pingStatus pingStatus
+	// slowQueryThreshold is the duration above which queries are logged as
+	// slow; see SetSlowQueryThreshold
+	slowQueryThreshold time.Duration
 }
 
 // Migration represents a database migration
@@ -21,19 +45,44 @@ type Migration struct {
 	Version int
 	Name    string
 	SQL     string
+	// Check, if set, runs in the migration's transaction before SQL; an
+	// error aborts the migration
+	Check func(ctx context.Context, tx *Tx) error
 }
 
-// NewDatabaseManager creates a new database manager instance
-func NewDatabaseManager(dbPath string, logger *zap.Logger) *DatabaseManager {
-	return &DatabaseManager{
-		DBPath: dbPath,
-		logger: logger,
+// NewDatabaseManager creates a new database manager for driver, either
+// DriverSQLite with dsn as the database file path, or DriverPostgres
+func NewDatabaseManager(driver, dsn string, logger *zap.Logger) (*DatabaseManager, error) {
+	if err := validateDriver(driver); err != nil {
+		return nil, err
+	}
+
+	dm := &DatabaseManager{
+		Driver:             driver,
+		DSN:                dsn,
+		logger:             logger,
+		slowQueryThreshold: DefaultSlowQueryThreshold,
+	}
+	if driver == DriverSQLite {
+		dm.DBPath = dsn
 	}
+	return dm, nil
 }
 
-// Connect establishes connection to the SQLite database
+// Connect establishes the connection to the database
 func (dm *DatabaseManager) Connect() error {
-	db, err := sql.Open("sqlite3", dm.DBPath+"?_foreign_keys=on")
+	dsn := dm.DSN
+	if dm.Driver == DriverSQLite {
+		sep := "?"
+		if strings.Contains(dm.DBPath, "?") {
+			sep = "&"
+		}
+		// The busy timeout makes concurrent writers wait for the lock
+		// instead of failing immediately with "database is locked"
+		dsn = dm.DBPath + sep + "_foreign_keys=on&_busy_timeout=5000"
+	}
+
+	db, err := sql.Open(dm.Driver, dsn)
 	if err != nil {
 		dm.logger.Error("failed to open database:", zap.Error(err))
 		return err
@@ -46,13 +95,19 @@ func (dm *DatabaseManager) Connect() error {
 	}
 
 	dm.DB = db
-	dm.logger.Info("Connected to database.", zap.String("Connected to database.", dm.DBPath))
+	dm.logger.Info("Connected to database.", zap.String("driver", dm.Driver), zap.String("Connected to database.", dm.DBPath))
 	return nil
 }
 
-// Close closes the database connection
+// Close closes the database connection. Only the first call does anything,
+// so it is safe to call from more than one shutdown path; queries made
+// afterwards fail with ErrDatabaseUnavailable.
 func (dm *DatabaseManager) Close() error {
+	if !dm.closed.CompareAndSwap(false, true) {
+		return nil
+	}
 	if dm.DB != nil {
+		openTxs.Delete(dm.DB)
 		err := dm.DB.Close()
 		if err != nil {
 			return fmt.Errorf("failed to close database: %w", err)
@@ -62,6 +117,20 @@ func (dm *DatabaseManager) Close() error {
 	return nil
 }
 
+// Ping checks that the database connection is usable
+func (dm *DatabaseManager) Ping(ctx context.Context) error {
+	if dm.DB == nil {
+		return errors.New("database is not connected")
+	}
+	if dm.closed.Load() {
+		return ErrDatabaseUnavailable
+	}
+	if err := dm.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
 // InitializeDatabase creates the database file and runs initial setup
 func (dm *DatabaseManager) InitializeDatabase() error {
 	if err := dm.Connect(); err != nil {
@@ -92,7 +161,7 @@ func (dm *DatabaseManager) createMigrationsTable() error {
 		executed_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 
-	_, err := dm.DB.Exec(query)
+	_, err := dm.DB.Exec(translateDDL(dm.Driver, query))
 	if err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
@@ -118,85 +187,489 @@ func GetMigrations() []Migration {
 			CREATE INDEX idx_users_email ON users(email);
 			`,
 		},
+		{
+			Version: 2,
+			Name:    "normalize_user_emails",
+			Check:   checkNormalizedEmailsUnique,
+			SQL: `
+			UPDATE users SET email = LOWER(TRIM(email));
+			`,
+		},
+		{
+			Version: 3,
+			Name:    "add_users_password_hash",
+			SQL: `
+			ALTER TABLE users ADD COLUMN password_hash TEXT;
+			`,
+		},
+		{
+			Version: 4,
+			Name:    "create_orders_table",
+			SQL: `
+			CREATE TABLE orders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				symbol TEXT NOT NULL,
+				side TEXT NOT NULL CONSTRAINT chk_orders__side CHECK (side IN ('buy', 'sell')),
+				type TEXT NOT NULL CONSTRAINT chk_orders__type CHECK (type IN ('market', 'limit')),
+				quantity REAL NOT NULL CONSTRAINT chk_orders__quantity CHECK (quantity > 0),
+				price REAL CONSTRAINT chk_orders__price CHECK (price IS NULL OR price > 0),
+				status TEXT NOT NULL DEFAULT 'open' CONSTRAINT chk_orders__status CHECK (status IN ('open', 'filled', 'cancelled')),
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX idx_orders_user_id ON orders(user_id);
+			CREATE INDEX idx_orders_symbol ON orders(symbol);
+			`,
+		},
+		{
+			Version: 5,
+			Name:    "create_trades_table",
+			SQL: `
+			CREATE TABLE trades (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				order_id INTEGER NOT NULL REFERENCES orders(id),
+				symbol TEXT NOT NULL,
+				quantity REAL NOT NULL CONSTRAINT chk_trades__quantity CHECK (quantity > 0),
+				price REAL NOT NULL CONSTRAINT chk_trades__price CHECK (price > 0),
+				executed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX idx_trades_order_id ON trades(order_id);
+			`,
+		},
+		{
+			Version: 6,
+			Name:    "create_positions_table",
+			SQL: `
+			CREATE TABLE positions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				symbol TEXT NOT NULL,
+				quantity REAL NOT NULL DEFAULT 0,
+				avg_price REAL NOT NULL DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT uq_positions_user_symbol UNIQUE (user_id, symbol)
+			);
+			`,
+		},
+		{
+			Version: 7,
+			Name:    "create_accounts_table",
+			SQL: `
+			CREATE TABLE accounts (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				currency TEXT NOT NULL,
+				balance INTEGER NOT NULL DEFAULT 0 CONSTRAINT chk_accounts__balance CHECK (balance >= 0),
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT uq_accounts_user_currency UNIQUE (user_id, currency)
+			);
+			`,
+		},
+		{
+			Version: 8,
+			Name:    "create_instruments_table",
+			SQL: `
+			CREATE TABLE instruments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				symbol TEXT NOT NULL UNIQUE,
+				name TEXT NOT NULL,
+				exchange TEXT NOT NULL,
+				tick_size REAL NOT NULL CONSTRAINT chk_instruments__tick_size CHECK (tick_size > 0),
+				active BOOLEAN NOT NULL DEFAULT TRUE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			INSERT INTO instruments (symbol, name, exchange, tick_size) VALUES
+				('AAPL', 'Apple Inc.', 'NASDAQ', 0.01),
+				('MSFT', 'Microsoft Corporation', 'NASDAQ', 0.01),
+				('GOOGL', 'Alphabet Inc. Class A', 'NASDAQ', 0.01),
+				('AMZN', 'Amazon.com, Inc.', 'NASDAQ', 0.01),
+				('TSLA', 'Tesla, Inc.', 'NASDAQ', 0.01),
+				('SPY', 'SPDR S&P 500 ETF Trust', 'NYSE Arca', 0.01);
+			`,
+		},
+		{
+			Version: 9,
+			Name:    "create_bars_table",
+			SQL: `
+			CREATE TABLE bars (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				symbol TEXT NOT NULL,
+				timeframe TEXT NOT NULL CONSTRAINT chk_bars__timeframe CHECK (timeframe IN ('1m', '5m', '15m', '30m', '1h', '4h', '1d')),
+				ts DATETIME NOT NULL,
+				open REAL NOT NULL CONSTRAINT chk_bars__open CHECK (open > 0),
+				high REAL NOT NULL CONSTRAINT chk_bars__high CHECK (high >= open AND high >= close AND high >= low),
+				low REAL NOT NULL CONSTRAINT chk_bars__low CHECK (low > 0 AND low <= open AND low <= close),
+				close REAL NOT NULL CONSTRAINT chk_bars__close CHECK (close > 0),
+				volume REAL NOT NULL DEFAULT 0 CONSTRAINT chk_bars__volume CHECK (volume >= 0),
+				CONSTRAINT uq_bars_symbol_timeframe_ts UNIQUE (symbol, timeframe, ts)
+			);
+			`,
+		},
+		{
+			Version: 10,
+			Name:    "add_orders_filled_quantity",
+			SQL: `
+			ALTER TABLE orders ADD COLUMN filled_quantity REAL NOT NULL DEFAULT 0;
+			`,
+		},
+		{
+			Version: 11,
+			Name:    "create_idempotency_keys_table",
+			SQL: `
+			CREATE TABLE idempotency_keys (
+				key TEXT PRIMARY KEY,
+				request_hash TEXT NOT NULL,
+				status_code INTEGER NOT NULL DEFAULT 0,
+				response_body BLOB,
+				created_at DATETIME NOT NULL
+			);
+			`,
+		},
+		{
+			Version: 12,
+			Name:    "create_audit_log_table",
+			SQL: `
+			CREATE TABLE audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER,
+				action TEXT NOT NULL,
+				entity TEXT NOT NULL,
+				entity_id INTEGER NOT NULL,
+				detail TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX idx_audit_log_user_id ON audit_log(user_id);
+			`,
+		},
+		{
+			Version: 13,
+			Name:    "add_users_deleted_at",
+			SQL: `
+			ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+			`,
+		},
 	}
 }
 
 // RunMigrations executes all pending migrations
 func (dm *DatabaseManager) RunMigrations() error {
-	migrations := GetMigrations()
+	_, err := dm.ApplyMigrations(context.Background())
+	return err
+}
 
-	for _, migration := range migrations {
-		// Check if migration has already been executed
-		var count int
-		err := dm.DB.QueryRow("SELECT COUNT(*) FROM migrations WHERE version = ?", migration.Version).Scan(&count)
-		if err != nil {
-			return fmt.Errorf("failed to check migration status: %w", err)
+// PendingMigrations returns the migrations that haven't been executed yet,
+// in version order
+func (dm *DatabaseManager) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	rows, err := dm.DB.QueryContext(ctx, "SELECT version FROM migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migration status: %w", err)
+	}
+	defer rows.Close()
+
+	executed := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
 		}
+		executed[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check migration status: %w", err)
+	}
 
-		if count > 0 {
-			dm.logger.Info("Migration already executed, skipping", zap.Int("migration version", migration.Version), zap.String("migration name", migration.Name))
-			continue
+	var pending []Migration
+	for _, migration := range GetMigrations() {
+		if !executed[migration.Version] {
+			pending = append(pending, migration)
 		}
+	}
+	return pending, nil
+}
 
-		// Execute migration
-		log.Printf("Executing migration %d: %s", migration.Version, migration.Name)
+// ApplyMigrations executes the pending migrations in order and returns the
+// ones it applied, which is empty when the schema is up to date. Calls are
+// serialized so concurrent callers can't run the same migration twice. On
+// error, the migrations applied before the failing one are still returned.
+func (dm *DatabaseManager) ApplyMigrations(ctx context.Context) ([]Migration, error) {
+	dm.migrateMu.Lock()
+	defer dm.migrateMu.Unlock()
 
-		tx, err := dm.DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
+	pending, err := dm.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		// Execute the migration SQL
-		_, err = tx.Exec(migration.SQL)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to execute migration %d: %w", migration.Version, err)
-		}
+	applied := []Migration{}
+	for _, migration := range pending {
+		dm.logger.Info("Executing migration", zap.Int("migration version", migration.Version), zap.String("migration name", migration.Name))
 
-		// Record the migration
-		_, err = tx.Exec("INSERT INTO migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		if err := dm.runMigration(ctx, migration); err != nil {
+			return applied, err
 		}
+		applied = append(applied, migration)
+
+		dm.logger.Info("Migration executed successfully", zap.Int("migration version", migration.Version), zap.String("migration name", migration.Name))
+	}
+
+	if len(applied) == 0 {
+		dm.logger.Info("Database schema is up to date")
+	}
+	return applied, nil
+}
+
+// runMigration executes a single migration and records it in one transaction.
+// The deferred rollback guarantees the transaction is closed on every error
+// path, including panics; after a successful commit it is a no-op.
+func (dm *DatabaseManager) runMigration(ctx context.Context, migration Migration) error {
+	tx, err := dm.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	if migration.Check != nil {
+		if err := migration.Check(ctx, tx); err != nil {
+			return fmt.Errorf("migration %d check failed: %w", migration.Version, err)
 		}
+	}
 
-		dm.logger.Info("Migration %d (%s) executed successfully", zap.Int("migration version", migration.Version), zap.String("migration name", migration.Name))
+	// Execute the migration SQL, adapted to the driver's column types
+	if _, err := tx.ExecContext(ctx, translateDDL(dm.Driver, migration.SQL)); err != nil {
+		return fmt.Errorf("failed to execute migration %d: %w", migration.Version, err)
 	}
 
+	// Record the migration
+	if _, err := tx.ExecContext(ctx, dm.rebind("INSERT INTO migrations (version, name) VALUES (?, ?)"), migration.Version, migration.Name); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
 	return nil
 }
 
-// AddSampleData inserts some sample data for testing
+// checkNormalizedEmailsUnique refuses to normalize emails while two users'
+// addresses differ only in case or surrounding space, since one of them
+// would then break the UNIQUE constraint. Each conflict is logged, and the
+// accounts are left for an operator to merge or rename.
+func checkNormalizedEmailsUnique(ctx context.Context, tx *Tx) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT LOWER(TRIM(email)), id FROM users
+		WHERE LOWER(TRIM(email)) IN (
+			SELECT LOWER(TRIM(email)) FROM users
+			GROUP BY LOWER(TRIM(email)) HAVING COUNT(*) > 1
+		)
+		ORDER BY LOWER(TRIM(email)), id`)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []string
+	ids := make(map[string][]int)
+	for rows.Next() {
+		var email string
+		var id int
+		if err := rows.Scan(&email, &id); err != nil {
+			return fmt.Errorf("failed to scan duplicate email: %w", err)
+		}
+		if _, ok := ids[email]; !ok {
+			emails = append(emails, email)
+		}
+		ids[email] = append(ids[email], id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find duplicate emails: %w", err)
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+
+	conflicts := make([]string, len(emails))
+	for i, email := range emails {
+		tx.logger.Error("Users share an email once normalized",
+			zap.String("email", email), zap.Ints("user_ids", ids[email]))
+		conflicts[i] = fmt.Sprintf("%s (users %s)", email, strings.Trim(fmt.Sprint(ids[email]), "[]"))
+	}
+	return fmt.Errorf("%w: %s", ErrDuplicateEmail, strings.Join(conflicts, "; "))
+}
+
+// AddSampleData inserts some sample data for testing. It is idempotent:
+// rows that already exist are skipped, and only new rows are counted.
 func (dm *DatabaseManager) AddSampleData() error {
-	log.Println("Adding sample data...")
+	dm.logger.Info("Adding sample data...")
 
-	// Insert sample users
+	// Insert sample users; ON CONFLICT DO NOTHING works on SQLite and Postgres
 	userQueries := []string{
-		"INSERT OR IGNORE INTO users (username, email) VALUES ('john_doe', 'john@example.com')",
-		"INSERT OR IGNORE INTO users (username, email) VALUES ('jane_smith', 'jane@example.com')",
+		"INSERT INTO users (username, email) VALUES ('john_doe', 'john@example.com') ON CONFLICT DO NOTHING",
+		"INSERT INTO users (username, email) VALUES ('jane_smith', 'jane@example.com') ON CONFLICT DO NOTHING",
 	}
 
 	// Execute sample data queries
 	allQueries := userQueries
 
+	var inserted int64
 	for _, query := range allQueries {
-		_, err := dm.DB.Exec(query)
+		result, err := dm.DB.Exec(query)
 		if err != nil {
 			return fmt.Errorf("failed to insert sample data: %w", err)
 		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		inserted += rows
 	}
 
-	dm.logger.Info("Sample data added successfully")
+	dm.logger.Info("Sample data added successfully",
+		zap.Int64("inserted", inserted),
+		zap.Int("skipped", len(allQueries)-int(inserted)))
 	return nil
 }
 
+// ResetData deletes all rows from the domain tables in a single transaction,
+// leaving the migrations history and the seeded instruments intact, and
+// returns the tables it cleared.
+// It is meant for integration tests and must never be exposed in production.
+func (dm *DatabaseManager) ResetData(ctx context.Context) ([]string, error) {
+	var tables []string
+
+	err := dm.WithTransaction(ctx, func(tx *sql.Tx) error {
+		if dm.Driver == DriverPostgres {
+			var err error
+			tables, err = dm.resetPostgres(tx)
+			return err
+		}
+
+		// Check foreign keys at commit, when every table has been emptied
+		if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+			return fmt.Errorf("failed to defer foreign keys: %w", err)
+		}
+
+		var err error
+		tables, err = queryTableNames(tx, `
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT IN ('migrations', 'instruments') AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM "%s"`, table)); err != nil {
+				return fmt.Errorf("failed to clear table %s: %w", table, err)
+			}
+		}
+
+		// Restart AUTOINCREMENT ids for the cleared tables
+		if _, err := tx.Exec("DELETE FROM sqlite_sequence WHERE name NOT IN ('migrations', 'instruments')"); err != nil {
+			return fmt.Errorf("failed to reset sequences: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dm.logger.Warn("Database data reset", zap.Strings("tables", tables))
+	return tables, nil
+}
+
+// resetPostgres empties every table except migrations and instruments with a
+// single TRUNCATE, restarting their id sequences
+func (dm *DatabaseManager) resetPostgres(tx *sql.Tx) ([]string, error) {
+	tables, err := queryTableNames(tx, `
+	SELECT table_name FROM information_schema.tables
+	WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' AND table_name NOT IN ('migrations', 'instruments')
+	ORDER BY table_name`)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return tables, nil
+	}
+
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = `"` + table + `"`
+	}
+	if _, err := tx.Exec("TRUNCATE " + strings.Join(quoted, ", ") + " RESTART IDENTITY CASCADE"); err != nil {
+		return nil, fmt.Errorf("failed to truncate tables: %w", err)
+	}
+	return tables, nil
+}
+
+// queryTableNames runs a query returning one table name per row
+func queryTableNames(tx *sql.Tx, query string) ([]string, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return tables, nil
+}
+
+// DumpSchema returns the CREATE statements for all tables and indexes,
+// tables first, excluding SQLite's internal tables. It is only supported on
+// SQLite.
+func (dm *DatabaseManager) DumpSchema() (string, error) {
+	if dm.Driver != DriverSQLite {
+		return "", fmt.Errorf("%w: schema dumps need SQLite", ErrUnsupportedDriver)
+	}
+
+	rows, err := dm.DB.Query(`
+	SELECT sql FROM sqlite_master
+	WHERE type IN ('table', 'index') AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+	ORDER BY CASE type WHEN 'table' THEN 0 ELSE 1 END, name`)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	var schema strings.Builder
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return "", fmt.Errorf("failed to scan schema statement: %w", err)
+		}
+		schema.WriteString(stmt)
+		schema.WriteString(";\n\n")
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	return schema.String(), nil
+}
+
 // GetTableInfo returns information about all tables
 func (dm *DatabaseManager) GetTableInfo() error {
-	rows, err := dm.DB.Query("SELECT name FROM sqlite_master WHERE type='table' ORDER BY name")
+	query := "SELECT name FROM sqlite_master WHERE type='table' ORDER BY name"
+	if dm.Driver == DriverPostgres {
+		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() ORDER BY table_name"
+	}
+
+	rows, err := dm.DB.Query(query)
 	if err != nil {
 		return fmt.Errorf("failed to get table info: %w", err)
 	}
@@ -214,6 +687,104 @@ func (dm *DatabaseManager) GetTableInfo() error {
 	return nil
 }
 
+// Backup writes a consistent snapshot of the live database to destPath using
+// VACUUM INTO, so it can run while the server keeps serving requests. An
+// existing file at destPath is only replaced when overwrite is true. It is
+// only supported on SQLite.
+func (dm *DatabaseManager) Backup(destPath string, overwrite bool) error {
+	if dm.Driver != DriverSQLite {
+		return fmt.Errorf("%w: file backups need SQLite", ErrUnsupportedDriver)
+	}
+
+	if _, err := os.Stat(destPath); err == nil {
+		if !overwrite {
+			return fmt.Errorf("backup destination %s already exists", destPath)
+		}
+		if err := os.Remove(destPath); err != nil {
+			return fmt.Errorf("failed to remove existing backup %s: %w", destPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check backup destination %s: %w", destPath, err)
+	}
+
+	if _, err := dm.DB.Exec("VACUUM INTO ?", destPath); err != nil {
+		return fmt.Errorf("failed to back up database to %s: %w", destPath, err)
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat backup %s: %w", destPath, err)
+	}
+
+	dm.logger.Info("Database backup created",
+		zap.String("path", destPath),
+		zap.Int64("size_bytes", info.Size()))
+	return nil
+}
+
+// Vacuum rebuilds the database file to reclaim space left behind by deleted
+// rows and logs the file size before and after. It returns
+// ErrVacuumInTransaction without running VACUUM while a transaction begun
+// through BeginTx is still open on the database.
+func (dm *DatabaseManager) Vacuum() error {
+	if n := openTxCount(dm.DB).Load(); n > 0 {
+		return fmt.Errorf("%w: %d open", ErrVacuumInTransaction, n)
+	}
+	before := dm.fileSize()
+
+	if _, err := dm.DB.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	dm.logger.Info("Database vacuumed",
+		zap.Int64("size_before_bytes", before),
+		zap.Int64("size_after_bytes", dm.fileSize()))
+	return nil
+}
+
+// Analyze refreshes the statistics used by the query planner
+func (dm *DatabaseManager) Analyze() error {
+	if _, err := dm.DB.Exec("ANALYZE"); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+
+	dm.logger.Info("Database statistics analyzed")
+	return nil
+}
+
+// CheckDiskSpace returns ErrLowDiskSpace when the filesystem holding the
+// database file has less than minFreeBytes available. In-memory and remote
+// databases are always considered healthy.
+func (dm *DatabaseManager) CheckDiskSpace(minFreeBytes uint64) error {
+	if dm.Driver != DriverSQLite || dm.isInMemory() {
+		return nil
+	}
+
+	free, err := freeDiskBytes(filepath.Dir(dm.DBPath))
+	if err != nil {
+		return fmt.Errorf("failed to check free disk space: %w", err)
+	}
+
+	if free < minFreeBytes {
+		return fmt.Errorf("%w: %d bytes free, %d required", ErrLowDiskSpace, free, minFreeBytes)
+	}
+	return nil
+}
+
+// isInMemory reports whether the database lives in memory rather than on disk
+func (dm *DatabaseManager) isInMemory() bool {
+	return dm.DBPath == ":memory:" || strings.Contains(dm.DBPath, "mode=memory")
+}
+
+// fileSize returns the size of the database file, or 0 if it can't be determined
+func (dm *DatabaseManager) fileSize() int64 {
+	info, err := os.Stat(dm.DBPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
 // Example usage and main function
 // func main() {
 // Create database manager
@@ -248,15 +819,44 @@ func (dm *DatabaseManager) GetTableInfo() error {
 
 // ExecuteQuery executes a custom query and returns results
 func (dm *DatabaseManager) ExecuteQuery(query string, args ...interface{}) (*sql.Rows, error) {
-	return dm.DB.Query(query, args...)
+	start := time.Now()
+	rows, err := dm.DB.Query(query, args...)
+	observeQuery(dm, "manager.query", query, start, err)
+	return rows, err
 }
 
 // ExecuteStatement executes a statement (INSERT, UPDATE, DELETE)
 func (dm *DatabaseManager) ExecuteStatement(query string, args ...interface{}) (sql.Result, error) {
-	return dm.DB.Exec(query, args...)
+	start := time.Now()
+	result, err := dm.DB.Exec(query, args...)
+	observeQuery(dm, "manager.exec", query, start, err)
+	return result, err
 }
 
-// BeginTransaction starts a new transaction
-func (dm *DatabaseManager) BeginTransaction() (*sql.Tx, error) {
-	return dm.DB.Begin()
+// BeginTransaction starts a new transaction that logs its lifecycle
+func (dm *DatabaseManager) BeginTransaction() (*Tx, error) {
+	return dm.beginTx(context.Background())
+}
+
+// WithTransaction runs fn inside a transaction. The transaction is committed
+// when fn returns nil and rolled back when it returns an error or panics; a
+// panic is re-raised after the rollback.
+func (dm *DatabaseManager) WithTransaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := dm.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Roll back on any path that doesn't reach Commit, including a panic in
+	// fn, which keeps unwinding afterwards. It is a no-op once committed.
+	defer tx.Rollback()
+
+	if err := fn(tx.Tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,341 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTestManager returns a migrated manager on a fresh SQLite file in a
+// temporary directory, closed when the test ends
+func newTestManager(t *testing.T) *DatabaseManager {
+	t.Helper()
+
+	dm, err := NewDatabaseManager(DriverSQLite, filepath.Join(t.TempDir(), "test.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	if err := dm.InitializeDatabase(); err != nil {
+		t.Fatalf("InitializeDatabase: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	return dm
+}
+
+// newTestStore returns the SQL models on a newTestManager
+func newTestStore(t *testing.T) *SQLStore {
+	t.Helper()
+	return NewSQLStore(newTestManager(t), zap.NewNop())
+}
+
+func TestBackupCopiesLiveDatabase(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	users := NewSQLStore(dm, zap.NewNop()).User
+
+	user := &User{Username: "alice", Email: "alice@example.com"}
+	if err := users.Insert(ctx, user); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if err := dm.Backup(dest, false); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	backup, err := sql.Open(DriverSQLite, dest)
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer backup.Close()
+
+	var email string
+	if err := backup.QueryRow("SELECT email FROM users WHERE id = ?", user.UserID).Scan(&email); err != nil {
+		t.Fatalf("read user from backup: %v", err)
+	}
+	if email != user.Email {
+		t.Errorf("backed up email = %q, want %q", email, user.Email)
+	}
+}
+
+func TestBackupOverwrite(t *testing.T) {
+	dm := newTestManager(t)
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if err := os.WriteFile(dest, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dm.Backup(dest, false); err == nil {
+		t.Fatal("Backup over an existing file without overwrite succeeded")
+	}
+	if err := dm.Backup(dest, true); err != nil {
+		t.Fatalf("Backup with overwrite: %v", err)
+	}
+}
+
+func TestNormalizeEmailsMigrationRefusesDuplicates(t *testing.T) {
+	dm, err := NewDatabaseManager(DriverSQLite, filepath.Join(t.TempDir(), "test.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	if err := dm.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	ctx := context.Background()
+
+	// Seed case variants as they could exist before the migration
+	if err := dm.createMigrationsTable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.runMigration(ctx, GetMigrations()[0]); err != nil {
+		t.Fatalf("run first migration: %v", err)
+	}
+	if _, err := dm.DB.Exec(`INSERT INTO users (username, email) VALUES
+		('john', 'John@Example.com'), ('johnny', ' john@example.com'), ('jane', 'JANE@example.com')`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dm.ApplyMigrations(ctx)
+	if !errors.Is(err, ErrDuplicateEmail) || !strings.Contains(err.Error(), "john@example.com (users 1 2)") {
+		t.Fatalf("ApplyMigrations = %v, want ErrDuplicateEmail listing users 1 and 2", err)
+	}
+	var count int
+	if err := dm.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil || count != 3 {
+		t.Errorf("users after refused migration = %d, %v, want all 3 kept", count, err)
+	}
+
+	// Once an operator resolves the conflict the migration goes through
+	if _, err := dm.DB.Exec("UPDATE users SET email = 'johnny@example.com' WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dm.ApplyMigrations(ctx); err != nil {
+		t.Fatalf("ApplyMigrations after resolving: %v", err)
+	}
+	var email string
+	if err := dm.DB.QueryRow("SELECT email FROM users WHERE id = 3").Scan(&email); err != nil || email != "jane@example.com" {
+		t.Errorf("email = %q, %v, want jane@example.com", email, err)
+	}
+}
+
+func TestVacuumShrinksFileAfterDelete(t *testing.T) {
+	dm := newTestManager(t)
+
+	if _, err := dm.DB.Exec("CREATE TABLE scratch (data BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+	for range 500 {
+		if _, err := dm.DB.Exec("INSERT INTO scratch (data) VALUES (randomblob(4096))"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dm.DB.Exec("DELETE FROM scratch"); err != nil {
+		t.Fatal(err)
+	}
+
+	before := dm.fileSize()
+	if err := dm.Vacuum(); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	if after := dm.fileSize(); after >= before {
+		t.Errorf("file size after vacuum = %d, want less than %d", after, before)
+	}
+	if err := dm.Analyze(); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+}
+
+func TestVacuumRefusedWhileTransactionOpen(t *testing.T) {
+	dm := newTestManager(t)
+
+	tx, err := dm.beginTx(context.Background())
+	if err != nil {
+		t.Fatalf("beginTx: %v", err)
+	}
+	if err := dm.Vacuum(); !errors.Is(err, ErrVacuumInTransaction) {
+		t.Errorf("Vacuum with an open transaction = %v, want ErrVacuumInTransaction", err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	// A second rollback mustn't drop the count below zero
+	tx.Rollback()
+	if err := dm.Vacuum(); err != nil {
+		t.Errorf("Vacuum after rollback = %v, want nil", err)
+	}
+
+	tx, err = dm.beginTx(context.Background())
+	if err != nil {
+		t.Fatalf("beginTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := dm.Vacuum(); err != nil {
+		t.Errorf("Vacuum after commit = %v, want nil", err)
+	}
+}
+
+// insertTestUser stores a user named username in s and returns it
+func insertTestUser(t *testing.T, s *SQLStore, username string) *User {
+	t.Helper()
+
+	user := &User{Username: username, Email: username + "@example.com"}
+	if err := s.User.Insert(context.Background(), user); err != nil {
+		t.Fatalf("Insert user %s: %v", username, err)
+	}
+	return user
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dm := newTestManager(t)
+
+	if err := dm.CheckDiskSpace(1); err != nil {
+		t.Errorf("CheckDiskSpace with a small threshold = %v, want nil", err)
+	}
+	if err := dm.CheckDiskSpace(math.MaxUint64); !errors.Is(err, ErrLowDiskSpace) {
+		t.Errorf("CheckDiskSpace with a huge threshold = %v, want ErrLowDiskSpace", err)
+	}
+}
+
+func TestCheckDiskSpaceSkipsInMemory(t *testing.T) {
+	dm, err := NewDatabaseManager(DriverSQLite, ":memory:", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	defer dm.Close()
+
+	if err := dm.CheckDiskSpace(math.MaxUint64); err != nil {
+		t.Errorf("CheckDiskSpace on :memory: = %v, want nil", err)
+	}
+}
+
+func TestResetDataClearsDomainTables(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	user := insertTestUser(t, s, "alice")
+	if err := s.Order.Insert(ctx, limitOrder(user.UserID, "AAPL", OrderSideBuy, 1, 100)); err != nil {
+		t.Fatalf("Insert order: %v", err)
+	}
+
+	tables, err := dm.ResetData(ctx)
+	if err != nil {
+		t.Fatalf("ResetData: %v", err)
+	}
+	if !slices.Contains(tables, "users") || !slices.Contains(tables, "orders") {
+		t.Errorf("ResetData cleared %v, want users and orders among them", tables)
+	}
+	if slices.Contains(tables, "migrations") || slices.Contains(tables, "instruments") {
+		t.Errorf("ResetData cleared %v, want migrations and instruments kept", tables)
+	}
+
+	for _, table := range []string{"users", "orders"} {
+		var n int
+		if err := dm.DB.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s has %d rows after reset, want 0", table, n)
+		}
+	}
+	if _, err := s.Instrument.GetBySymbol(ctx, "AAPL"); err != nil {
+		t.Errorf("seeded instrument gone after reset: %v", err)
+	}
+	pending, err := dm.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d migrations pending after reset, want none", len(pending))
+	}
+
+	// Ids restart once the tables are cleared
+	if again := insertTestUser(t, s, "bob"); again.UserID != 1 {
+		t.Errorf("first user after reset has id %d, want 1", again.UserID)
+	}
+}
+
+func TestDumpSchema(t *testing.T) {
+	schema, err := newTestManager(t).DumpSchema()
+	if err != nil {
+		t.Fatalf("DumpSchema: %v", err)
+	}
+
+	for _, want := range []string{"CREATE TABLE users", "CREATE INDEX idx_users_username", "CREATE INDEX idx_users_email"} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(schema, "sqlite_sequence") {
+		t.Error("schema contains internal sqlite tables")
+	}
+}
+
+func TestAddSampleDataIsIdempotent(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	dm := newTestManager(t)
+	dm.logger = zap.New(core)
+
+	for _, want := range []int64{2, 0} {
+		if err := dm.AddSampleData(); err != nil {
+			t.Fatalf("AddSampleData: %v", err)
+		}
+		entries := logs.TakeAll()
+		i := slices.IndexFunc(entries, func(e observer.LoggedEntry) bool { return e.Message == "Sample data added successfully" })
+		if i < 0 {
+			t.Fatal("AddSampleData didn't log its result")
+		}
+		if got := entries[i].ContextMap()["inserted"]; got != want {
+			t.Errorf("logged inserted = %v, want %d", got, want)
+		}
+	}
+
+	count, err := NewSQLStore(dm, zap.NewNop()).User.Count(context.Background())
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Count = %d, want the 2 sample users", count)
+	}
+}
+
+func TestClosedDatabaseIsUnavailable(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	user := &User{Username: "alice", Email: "alice@example.com"}
+	if err := s.User.Insert(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dm.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := dm.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+
+	calls := map[string]func() error{
+		"GetByID": func() error { _, err := s.User.GetByID(ctx, user.UserID); return err },
+		"Insert":  func() error { return s.User.Insert(ctx, &User{Username: "bob", Email: "bob@example.com"}) },
+		"List":    func() error { _, err := s.User.List(ctx, 10, 0, ""); return err },
+		"Ping":    func() error { return dm.Ping(ctx) },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrDatabaseUnavailable) {
+			t.Errorf("%s after Close = %v, want ErrDatabaseUnavailable", name, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Instrument struct {
+	InstrumentID int     `json:"instrument_id"`
+	Symbol       string  `json:"symbol"`
+	Name         string  `json:"name"`
+	Exchange     string  `json:"exchange"`
+	TickSize     float64 `json:"tick_size"`
+	// Active is false for instruments that can no longer be traded
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InstrumentModelInterface interface {
+	GetBySymbol(ctx context.Context, symbol string) (*Instrument, error)
+	List(ctx context.Context) ([]*Instrument, error)
+}
+
+// InstrumentModel wraps a database connection pool for the tradable
+// instruments reference data
+type InstrumentModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled instruments.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *InstrumentModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// instrumentColumns is the column list scanned by scanInstrument
+const instrumentColumns = `id, symbol, name, exchange, tick_size, active, created_at`
+
+// scanInstrument reads one row selected with instrumentColumns
+func scanInstrument(row rowScanner) (*Instrument, error) {
+	i := &Instrument{}
+	err := row.Scan(&i.InstrumentID, &i.Symbol, &i.Name, &i.Exchange, &i.TickSize, &i.Active, &i.CreatedAt)
+	return i, err
+}
+
+// GetBySymbol returns the instrument for symbol, which is normalized first,
+// or ErrNoRecord if none exists
+func (m *InstrumentModel) GetBySymbol(ctx context.Context, symbol string) (*Instrument, error) {
+	symbol = NormalizeSymbol(symbol)
+
+	query := `SELECT ` + instrumentColumns + ` FROM instruments WHERE symbol = ?`
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "instruments.get", query)
+	instrument, err := scanInstrument(m.DB.QueryRowContext(ctx, m.rebind(query), symbol))
+	err = done(err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, fmt.Errorf("failed to get instrument %s: %w", symbol, err)
+	}
+
+	return instrument, nil
+}
+
+// List returns all instruments ordered by symbol
+func (m *InstrumentModel) List(ctx context.Context) ([]*Instrument, error) {
+	query := `SELECT ` + instrumentColumns + ` FROM instruments ORDER BY symbol`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "instruments.list", query)
+	rows, err := m.DB.QueryContext(ctx, query)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instruments: %w", err)
+	}
+	defer rows.Close()
+
+	instruments := []*Instrument{}
+	for rows.Next() {
+		instrument, err := scanInstrument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan instrument: %w", err)
+		}
+		instruments = append(instruments, instrument)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list instruments: %w", err)
+	}
+
+	return instruments, nil
+}
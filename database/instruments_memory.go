@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// InMemoryInstrumentModel is an InstrumentModelInterface kept in a map, for
+// tests and demos that don't want a database. It starts empty, without the
+// instruments the migrations seed; use Add to provide them. The zero value is
+// ready to use.
+type InMemoryInstrumentModel struct {
+	mu          sync.Mutex
+	instruments map[string]*Instrument
+	lastID      int
+}
+
+var _ InstrumentModelInterface = (*InMemoryInstrumentModel)(nil)
+
+// NewInMemoryInstrumentModel returns an InMemoryInstrumentModel holding
+// instruments
+func NewInMemoryInstrumentModel(instruments ...*Instrument) *InMemoryInstrumentModel {
+	m := &InMemoryInstrumentModel{}
+	for _, instrument := range instruments {
+		m.Add(instrument)
+	}
+	return m
+}
+
+// Add stores instrument under its normalized symbol, replacing any
+// instrument with the same symbol, and sets its id and CreatedAt
+func (m *InMemoryInstrumentModel) Add(instrument *Instrument) {
+	instrument.Symbol = NormalizeSymbol(instrument.Symbol)
+	instrument.CreatedAt = memoryNow()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.instruments == nil {
+		m.instruments = make(map[string]*Instrument)
+	}
+	m.lastID++
+	instrument.InstrumentID = m.lastID
+	stored := *instrument
+	m.instruments[instrument.Symbol] = &stored
+}
+
+// GetBySymbol returns the instrument for symbol, which is normalized first,
+// or ErrNoRecord if none exists
+func (m *InMemoryInstrumentModel) GetBySymbol(ctx context.Context, symbol string) (*Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instrument, ok := m.instruments[NormalizeSymbol(symbol)]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	i := *instrument
+	return &i, nil
+}
+
+// List returns all instruments ordered by symbol
+func (m *InMemoryInstrumentModel) List(ctx context.Context) ([]*Instrument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	instruments := []*Instrument{}
+	for _, instrument := range m.instruments {
+		i := *instrument
+		instruments = append(instruments, &i)
+	}
+	slices.SortFunc(instruments, func(a, b *Instrument) int { return strings.Compare(a.Symbol, b.Symbol) })
+	return instruments, nil
+}
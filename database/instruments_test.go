@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentLookup(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	instrument, err := s.Instrument.GetBySymbol(ctx, " aapl ")
+	if err != nil {
+		t.Fatalf("GetBySymbol: %v", err)
+	}
+	if instrument.Symbol != "AAPL" || instrument.Exchange != "NASDAQ" || instrument.TickSize != 0.01 || !instrument.Active {
+		t.Errorf("GetBySymbol = %+v, want the seeded active AAPL instrument", instrument)
+	}
+
+	if _, err := s.Instrument.GetBySymbol(ctx, "NOPE"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetBySymbol of an unknown symbol = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestInstrumentList(t *testing.T) {
+	instruments, err := newTestStore(t).Instrument.List(context.Background())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	var symbols []string
+	for _, instrument := range instruments {
+		symbols = append(symbols, instrument.Symbol)
+	}
+	want := []string{"AAPL", "AMZN", "GOOGL", "MSFT", "SPY", "TSLA"}
+	if !slices.Equal(symbols, want) {
+		t.Errorf("List symbols = %v, want %v", symbols, want)
+	}
+	if !slices.IsSortedFunc(instruments, func(a, b *Instrument) int { return strings.Compare(a.Symbol, b.Symbol) }) {
+		t.Error("List isn't ordered by symbol")
+	}
+}
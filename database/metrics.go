@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// QueryObserver receives the duration and outcome of database operations,
+// e.g. to export them as metrics. operation names the model method or
+// manager call, such as "users.insert".
+type QueryObserver interface {
+	ObserveQuery(operation string, duration time.Duration, err error)
+}
+
+// SetQueryObserver makes the manager report its queries to o. It must be
+// called before the manager is used concurrently. Models whose Observer is
+// the manager report through o as well.
+func (dm *DatabaseManager) SetQueryObserver(o QueryObserver) {
+	dm.observer = o
+}
+
+// ObserveQuery forwards to the observer set with SetQueryObserver, if any, so
+// models can use the manager as their QueryObserver
+func (dm *DatabaseManager) ObserveQuery(operation string, duration time.Duration, err error) {
+	if dm.observer != nil {
+		dm.observer.ObserveQuery(operation, duration, err)
+	}
+}
+
+// slowQueryLogger is implemented by observers that also log slow queries,
+// which need the SQL that QueryObserver doesn't receive
+type slowQueryLogger interface {
+	logIfSlow(operation, query string, duration time.Duration)
+}
+
+// observeQuery reports an operation that began at start to o, which may be
+// nil. A query that simply matched no rows isn't counted as a failure.
+func observeQuery(o QueryObserver, operation, query string, start time.Time, err error) {
+	if o == nil {
+		return
+	}
+	duration := time.Since(start)
+	if sl, ok := o.(slowQueryLogger); ok {
+		sl.logIfSlow(operation, query, duration)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	o.ObserveQuery(operation, duration, err)
+}
+
+// DefaultSlowQueryThreshold is the slow query threshold of a new manager
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// SetSlowQueryThreshold changes how long a query may take before it is
+// logged as slow; 0 disables the log. It covers ExecuteQuery,
+// ExecuteStatement and every model created by NewSQLStore, since their
+// Observer is the manager, and, like SetQueryObserver, must be called before
+// the manager is used concurrently.
+func (dm *DatabaseManager) SetSlowQueryThreshold(d time.Duration) {
+	dm.slowQueryThreshold = d
+}
+
+// logIfSlow logs the query at warn level if it took longer than the
+// threshold. Whitespace is collapsed to keep multi-line SQL on one line.
+func (dm *DatabaseManager) logIfSlow(operation, query string, duration time.Duration) {
+	if dm.slowQueryThreshold <= 0 || duration <= dm.slowQueryThreshold {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("operation", operation),
+		zap.Duration("duration", duration),
+		zap.Duration("threshold", dm.slowQueryThreshold),
+	}
+	if query != "" {
+		fields = append(fields, zap.String("sql", strings.Join(strings.Fields(query), " ")))
+	}
+	dm.logger.Warn("Slow query", fields...)
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// observation is one call to a QueryObserver
+type observation struct {
+	operation string
+	failed    bool
+}
+
+// recordingObserver is a QueryObserver that keeps what it observes
+type recordingObserver struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveQuery(operation string, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observations = append(o.observations, observation{operation: operation, failed: err != nil})
+}
+
+// take returns the observations so far and forgets them
+func (o *recordingObserver) take() []observation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	observations := o.observations
+	o.observations = nil
+	return observations
+}
+
+func TestQueryObserver(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	o := &recordingObserver{}
+	dm.SetQueryObserver(o)
+
+	tests := []struct {
+		name string
+		run  func() error
+		want observation
+	}{
+		{"insert", func() error { return s.User.Insert(ctx, &User{Username: "john", Email: "john@example.com"}) }, observation{"users.insert", false}},
+		{"duplicate insert", func() error {
+			s.User.Insert(ctx, &User{Username: "john", Email: "john@example.com"})
+			return nil
+		}, observation{"users.insert", true}},
+		{"no rows is not a failure", func() error {
+			if _, err := s.User.GetByID(ctx, 42); !errors.Is(err, ErrNoRecord) {
+				return err
+			}
+			return nil
+		}, observation{"users.get", false}},
+		{"manager exec", func() error {
+			_, err := dm.ExecuteStatement("UPDATE users SET username = username")
+			return err
+		}, observation{"manager.exec", false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err != nil {
+				t.Fatal(err)
+			}
+			got := o.take()
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("observed %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	dm, logs := newObservedManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	ctx := context.Background()
+
+	if dm.slowQueryThreshold != DefaultSlowQueryThreshold {
+		t.Errorf("threshold = %v, want the default %v", dm.slowQueryThreshold, DefaultSlowQueryThreshold)
+	}
+
+	// Every query takes longer than a nanosecond
+	dm.SetSlowQueryThreshold(time.Nanosecond)
+	if err := s.User.Insert(ctx, &User{Username: "john", Email: "john@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dm.ExecuteStatement("UPDATE users SET username = username"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.FilterMessage("Slow query").All()
+	if len(entries) != 2 {
+		t.Fatalf("got %d slow query logs, want 2", len(entries))
+	}
+	insert := entries[0].ContextMap()
+	if entries[0].Level != zap.WarnLevel || insert["operation"] != "users.insert" {
+		t.Errorf("slow insert log = %v at %v, want users.insert at warn", insert, entries[0].Level)
+	}
+	if sql, _ := insert["sql"].(string); !strings.HasPrefix(sql, "INSERT INTO users") || strings.Contains(sql, "\n") {
+		t.Errorf("logged sql = %q, want the insert on one line", sql)
+	}
+	if entries[1].ContextMap()["sql"] != "UPDATE users SET username = username" {
+		t.Errorf("slow exec log = %v", entries[1].ContextMap())
+	}
+
+	logs.TakeAll()
+	dm.SetSlowQueryThreshold(0)
+	if _, err := s.User.Count(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := logs.FilterMessage("Slow query").Len(); n != 0 {
+		t.Errorf("got %d slow query logs with the log disabled, want 0", n)
+	}
+}
@@ -0,0 +1,450 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Order sides, types and statuses, matching the CHECK constraints on orders
+const (
+	OrderSideBuy  = "buy"
+	OrderSideSell = "sell"
+
+	OrderTypeMarket = "market"
+	OrderTypeLimit  = "limit"
+
+	OrderStatusOpen      = "open"
+	OrderStatusFilled    = "filled"
+	OrderStatusCancelled = "cancelled"
+)
+
+type Order struct {
+	OrderID  int     `json:"order_id"`
+	UserID   int     `json:"user_id"`
+	Symbol   string  `json:"symbol"`
+	Side     string  `json:"side"`
+	Type     string  `json:"type"`
+	Quantity float64 `json:"quantity"`
+	// FilledQuantity is how much of Quantity has executed so far
+	FilledQuantity float64 `json:"filled_quantity"`
+	// Price is the limit price; it is nil for market orders
+	Price     *float64  `json:"price,omitempty"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// orderTransitions lists the statuses each status may move to; filled and
+// cancelled orders are final
+var orderTransitions = map[string][]string{
+	OrderStatusOpen: {OrderStatusFilled, OrderStatusCancelled},
+}
+
+// OrderPublisher is notified after an order's status change is committed.
+// ctx is that of the change, carrying its CorrelationID; PublishOrder must
+// not block or keep it.
+type OrderPublisher interface {
+	PublishOrder(ctx context.Context, order *Order)
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to string) bool {
+	return slices.Contains(orderTransitions[from], to)
+}
+
+type OrderModelInterface interface {
+	Insert(ctx context.Context, order *Order) error
+	GetByID(ctx context.Context, id int) (*Order, error)
+	ListByUser(ctx context.Context, userID, limit, offset int) ([]*Order, error)
+	UpdateStatus(ctx context.Context, orderID int, newStatus string) error
+	AggregateBook(ctx context.Context, symbol string) (*OrderBook, error)
+	CancelOpen(ctx context.Context, userID int, symbol string) ([]int, error)
+}
+
+// OrderModel wraps a database connection pool for orders
+type OrderModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Publisher, if set, receives each order after UpdateStatus commits
+	Publisher OrderPublisher
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled orders.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *OrderModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// NormalizeSymbol trims and uppercases a symbol so "aapl " and "AAPL" match
+func NormalizeSymbol(symbol string) string {
+	return strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+// orderColumns is the column list scanned by scanOrder
+const orderColumns = `id, user_id, symbol, side, type, quantity, filled_quantity, price, status, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row rowScanner) (*Order, error) {
+	order := &Order{}
+	var price sql.NullFloat64
+	err := row.Scan(&order.OrderID, &order.UserID, &order.Symbol, &order.Side, &order.Type,
+		&order.Quantity, &order.FilledQuantity, &price, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if price.Valid {
+		order.Price = &price.Float64
+	}
+	return order, nil
+}
+
+// Insert creates a new open order. The symbol is normalized first; invalid
+// sides, types, quantities or prices are rejected by CHECK constraints.
+func (m *OrderModel) Insert(ctx context.Context, order *Order) error {
+	order.Symbol = NormalizeSymbol(order.Symbol)
+	order.Status = OrderStatusOpen
+
+	query := `
+	INSERT INTO orders (user_id, symbol, side, type, quantity, price, status)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	RETURNING id, created_at, updated_at`
+
+	m.Logger.Info("Creating new order",
+		zap.Int("user_id", order.UserID),
+		zap.String("symbol", order.Symbol),
+		zap.String("side", order.Side))
+
+	start := time.Now()
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.insert", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query),
+		order.UserID, order.Symbol, order.Side, order.Type, order.Quantity, order.Price, order.Status,
+	).Scan(&order.OrderID, &order.CreatedAt, &order.UpdatedAt)
+	err = done(err)
+
+	duration := time.Since(start)
+
+	if err != nil {
+		m.Logger.Error("Failed to create order",
+			zap.Int("user_id", order.UserID),
+			zap.String("symbol", order.Symbol),
+			zap.Duration("duration", duration),
+			zap.Error(err))
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+
+	m.Logger.Info("Order created successfully",
+		zap.Int("order_id", order.OrderID),
+		zap.Int("user_id", order.UserID),
+		zap.Duration("duration", duration))
+
+	return nil
+}
+
+// GetByID returns the order with the given id, or ErrNoRecord if none exists
+func (m *OrderModel) GetByID(ctx context.Context, id int) (*Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.get", query)
+	order, err := scanOrder(m.DB.QueryRowContext(ctx, m.rebind(query), id))
+	err = done(err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, fmt.Errorf("failed to get order %d: %w", id, err)
+	}
+
+	return order, nil
+}
+
+// ListByUser returns a page of the user's orders, newest first. Limits are
+// bounded the same way as UserModel.List.
+func (m *OrderModel) ListByUser(ctx context.Context, userID, limit, offset int) ([]*Order, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := `
+	SELECT ` + orderColumns + `
+	FROM orders
+	WHERE user_id = ?
+	ORDER BY id DESC
+	LIMIT ? OFFSET ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.list", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), userID, limit, offset)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	orders := []*Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list orders for user %d: %w", userID, err)
+	}
+
+	return orders, nil
+}
+
+// UpdateStatus moves the order to newStatus and sets updated_at. It returns
+// ErrNoRecord if the order doesn't exist and ErrInvalidTransition if the move
+// isn't allowed from the order's current status.
+func (m *OrderModel) UpdateStatus(ctx context.Context, orderID int, newStatus string) (err error) {
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.update_status", "")
+	defer func() { err = done(err) }()
+
+	tx, err := BeginTx(ctx, m.DB, m.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.UpdateStatusTx(ctx, tx.Tx, orderID, newStatus); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit status of order %d: %w", orderID, err)
+	}
+
+	m.Publish(ctx, orderID)
+	return nil
+}
+
+// Publish sends the order's committed state to the Publisher, if any. Callers
+// of UpdateStatusTx and FillTx use it once their transaction commits. The
+// change has already succeeded by then, so a failed reload is only logged.
+func (m *OrderModel) Publish(ctx context.Context, orderID int) {
+	if m.Publisher == nil {
+		return
+	}
+
+	order, err := m.GetByID(ctx, orderID)
+	if err != nil {
+		m.Logger.Warn("Failed to load order for publishing",
+			zap.Int("order_id", orderID),
+			zap.String("correlation_id", CorrelationID(ctx)),
+			zap.Error(err))
+		return
+	}
+	m.Publisher.PublishOrder(ctx, order)
+}
+
+// CancelOpen cancels all of the user's open orders for symbol, which is
+// normalized first, and returns their ids. A single statement makes the
+// change, so either every order is cancelled or none is; the Publisher is
+// notified of each once it has.
+func (m *OrderModel) CancelOpen(ctx context.Context, userID int, symbol string) ([]int, error) {
+	symbol = NormalizeSymbol(symbol)
+	if !CanTransition(OrderStatusOpen, OrderStatusCancelled) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, OrderStatusOpen, OrderStatusCancelled)
+	}
+
+	query := `
+	UPDATE orders
+	SET status = ?, updated_at = CURRENT_TIMESTAMP
+	WHERE user_id = ? AND symbol = ? AND status = ?
+	RETURNING id`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.cancel_open", query)
+	ids, err := func() ([]int, error) {
+		rows, err := m.DB.QueryContext(ctx, m.rebind(query), OrderStatusCancelled, userID, symbol, OrderStatusOpen)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		ids := []int{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, rows.Err()
+	}()
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel open %s orders of user %d: %w", symbol, userID, err)
+	}
+	slices.Sort(ids)
+
+	m.Logger.Info("Open orders cancelled",
+		zap.Int("user_id", userID),
+		zap.String("symbol", symbol),
+		zap.Int("cancelled", len(ids)))
+
+	for _, id := range ids {
+		m.Publish(ctx, id)
+	}
+	return ids, nil
+}
+
+// UpdateStatusTx is UpdateStatus inside a caller's transaction, e.g. to fill
+// an order and record its trade atomically with TradeModel.InsertTx. Unlike
+// UpdateStatus it doesn't notify the Publisher, since the caller commits.
+func (m *OrderModel) UpdateStatusTx(ctx context.Context, tx *sql.Tx, orderID int, newStatus string) (err error) {
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.update_status_tx", "")
+	defer func() { err = done(err) }()
+
+	var current string
+	err = tx.QueryRowContext(ctx, m.rebind("SELECT status FROM orders WHERE id = ?"), orderID).Scan(&current)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return fmt.Errorf("failed to get status of order %d: %w", orderID, err)
+	}
+
+	if !CanTransition(current, newStatus) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, current, newStatus)
+	}
+
+	// Guard on the status we checked so a concurrent change can't be overwritten
+	query := `
+	UPDATE orders
+	SET status = ?, updated_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND status = ?`
+
+	result, err := tx.ExecContext(ctx, m.rebind(query), newStatus, orderID, current)
+	if err != nil {
+		return fmt.Errorf("failed to update status of order %d: %w", orderID, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check updated rows for order %d: %w", orderID, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: order %d changed concurrently", ErrInvalidTransition, orderID)
+	}
+
+	m.Logger.Info("Order status updated",
+		zap.Int("order_id", orderID),
+		zap.String("from", current),
+		zap.String("to", newStatus))
+
+	return nil
+}
+
+// BookLevel is the open quantity resting at one price on one side of the book
+type BookLevel struct {
+	Price float64 `json:"price"`
+	// Quantity is what remains unfilled across the level's orders
+	Quantity float64 `json:"quantity"`
+	Orders   int     `json:"orders"`
+}
+
+// OrderBook is a symbol's open limit orders aggregated by price level. Bids
+// are ordered from the highest price down, asks from the lowest up.
+type OrderBook struct {
+	Symbol string      `json:"symbol"`
+	Bids   []BookLevel `json:"bids"`
+	Asks   []BookLevel `json:"asks"`
+}
+
+// AggregateBook returns the order book for symbol, which is normalized
+// first, built from its open limit orders. Filled and cancelled orders, and
+// market orders, which have no price, are left out.
+func (m *OrderModel) AggregateBook(ctx context.Context, symbol string) (*OrderBook, error) {
+	book := &OrderBook{Symbol: NormalizeSymbol(symbol), Bids: []BookLevel{}, Asks: []BookLevel{}}
+
+	query := `
+	SELECT side, price, SUM(quantity - filled_quantity), COUNT(*)
+	FROM orders
+	WHERE symbol = ? AND status = ? AND price IS NOT NULL
+	GROUP BY price, side
+	ORDER BY price`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.aggregate_book", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), book.Symbol, OrderStatusOpen)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate order book for %s: %w", book.Symbol, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var side string
+		var level BookLevel
+		if err := rows.Scan(&side, &level.Price, &level.Quantity, &level.Orders); err != nil {
+			return nil, fmt.Errorf("failed to scan order book level: %w", err)
+		}
+		if side == OrderSideBuy {
+			book.Bids = append(book.Bids, level)
+		} else {
+			book.Asks = append(book.Asks, level)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate order book for %s: %w", book.Symbol, err)
+	}
+
+	// Rows come lowest price first, the order asks want
+	slices.Reverse(book.Bids)
+	return book, nil
+}
+
+// fillEpsilon absorbs float residue when comparing filled and total quantity
+const fillEpsilon = 1e-9
+
+// FillTx records qty more of an open order as executed inside tx, moving it
+// to filled once nothing remains. It returns ErrInvalidTransition if the
+// order isn't open or the fill would exceed its quantity.
+func (m *OrderModel) FillTx(ctx context.Context, tx *sql.Tx, orderID int, qty float64) error {
+	if qty <= 0 {
+		return fmt.Errorf("fill quantity must be positive, got %v", qty)
+	}
+
+	query := `
+	UPDATE orders
+	SET filled_quantity = filled_quantity + ?,
+		status = CASE WHEN filled_quantity + ? >= quantity - ? THEN ? ELSE status END,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND status = ? AND filled_quantity + ? <= quantity + ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "orders.fill_tx", query)
+	result, err := tx.ExecContext(ctx, m.rebind(query),
+		qty, qty, fillEpsilon, OrderStatusFilled, orderID, OrderStatusOpen, qty, fillEpsilon)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to fill order %d: %w", orderID, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check updated rows for order %d: %w", orderID, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: order %d is not open or would be overfilled", ErrInvalidTransition, orderID)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// InMemoryOrderModel is an OrderModelInterface kept in a map, for tests and
+// demos that don't want a database. It follows OrderModel's rules for
+// symbols, statuses and transitions and returns the same sentinel errors,
+// but leaves checking sides, types, quantities and prices to its callers,
+// where OrderModel relies on CHECK constraints. The zero value is ready to
+// use.
+type InMemoryOrderModel struct {
+	// Publisher, if set, receives each order after UpdateStatus or
+	// CancelOpen changes it
+	Publisher OrderPublisher
+
+	mu     sync.Mutex
+	orders map[int]*Order
+	lastID int
+}
+
+var _ OrderModelInterface = (*InMemoryOrderModel)(nil)
+
+// NewInMemoryOrderModel returns an empty InMemoryOrderModel
+func NewInMemoryOrderModel() *InMemoryOrderModel {
+	return &InMemoryOrderModel{}
+}
+
+// copyOrder returns order as callers see it, sharing nothing with the store
+func copyOrder(order *Order) *Order {
+	c := *order
+	if order.Price != nil {
+		price := *order.Price
+		c.Price = &price
+	}
+	return &c
+}
+
+// Insert stores a new open order with the next id after normalizing its
+// symbol
+func (m *InMemoryOrderModel) Insert(ctx context.Context, order *Order) error {
+	order.Symbol = NormalizeSymbol(order.Symbol)
+	order.Status = OrderStatusOpen
+	order.FilledQuantity = 0
+	order.CreatedAt = memoryNow()
+	order.UpdatedAt = order.CreatedAt
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.orders == nil {
+		m.orders = make(map[int]*Order)
+	}
+	m.lastID++
+	order.OrderID = m.lastID
+	m.orders[order.OrderID] = copyOrder(order)
+	return nil
+}
+
+// GetByID returns the order with the given id, or ErrNoRecord if none exists
+func (m *InMemoryOrderModel) GetByID(ctx context.Context, id int) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+	return copyOrder(order), nil
+}
+
+// ListByUser returns a page of the user's orders, newest first, bounded as
+// OrderModel.ListByUser
+func (m *InMemoryOrderModel) ListByUser(ctx context.Context, userID, limit, offset int) ([]*Order, error) {
+	orders := m.matching(func(o *Order) bool { return o.UserID == userID })
+	slices.SortFunc(orders, func(a, b *Order) int { return cmp.Compare(b.OrderID, a.OrderID) })
+	return page(orders, limit, offset), nil
+}
+
+// UpdateStatus moves an order to newStatus, with the same errors as
+// OrderModel.UpdateStatus, and then notifies the Publisher
+func (m *InMemoryOrderModel) UpdateStatus(ctx context.Context, orderID int, newStatus string) error {
+	m.mu.Lock()
+	order, ok := m.orders[orderID]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNoRecord
+	}
+	if !CanTransition(order.Status, newStatus) {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, order.Status, newStatus)
+	}
+	order.Status = newStatus
+	order.UpdatedAt = memoryNow()
+	published := copyOrder(order)
+	m.mu.Unlock()
+
+	m.publish(ctx, published)
+	return nil
+}
+
+// AggregateBook returns the order book for symbol built from its open limit
+// orders, as OrderModel.AggregateBook does
+func (m *InMemoryOrderModel) AggregateBook(ctx context.Context, symbol string) (*OrderBook, error) {
+	book := &OrderBook{Symbol: NormalizeSymbol(symbol), Bids: []BookLevel{}, Asks: []BookLevel{}}
+
+	orders := m.matching(func(o *Order) bool {
+		return o.Symbol == book.Symbol && o.Status == OrderStatusOpen && o.Price != nil
+	})
+	levels := make(map[string]map[float64]*BookLevel, 2)
+	for _, o := range orders {
+		if levels[o.Side] == nil {
+			levels[o.Side] = make(map[float64]*BookLevel)
+		}
+		level, ok := levels[o.Side][*o.Price]
+		if !ok {
+			level = &BookLevel{Price: *o.Price}
+			levels[o.Side][*o.Price] = level
+		}
+		level.Quantity += o.Quantity - o.FilledQuantity
+		level.Orders++
+	}
+
+	for _, level := range levels[OrderSideBuy] {
+		book.Bids = append(book.Bids, *level)
+	}
+	for _, level := range levels[OrderSideSell] {
+		book.Asks = append(book.Asks, *level)
+	}
+	slices.SortFunc(book.Bids, func(a, b BookLevel) int { return cmp.Compare(b.Price, a.Price) })
+	slices.SortFunc(book.Asks, func(a, b BookLevel) int { return cmp.Compare(a.Price, b.Price) })
+	return book, nil
+}
+
+// CancelOpen cancels all of the user's open orders for symbol and returns
+// their ids in ascending order, notifying the Publisher of each
+func (m *InMemoryOrderModel) CancelOpen(ctx context.Context, userID int, symbol string) ([]int, error) {
+	symbol = NormalizeSymbol(symbol)
+
+	m.mu.Lock()
+	now := memoryNow()
+	var cancelled []*Order
+	for _, order := range m.orders {
+		if order.UserID == userID && order.Symbol == symbol && order.Status == OrderStatusOpen {
+			order.Status = OrderStatusCancelled
+			order.UpdatedAt = now
+			cancelled = append(cancelled, copyOrder(order))
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(cancelled, func(a, b *Order) int { return cmp.Compare(a.OrderID, b.OrderID) })
+	ids := make([]int, 0, len(cancelled))
+	for _, order := range cancelled {
+		ids = append(ids, order.OrderID)
+		m.publish(ctx, order)
+	}
+	return ids, nil
+}
+
+// matching returns copies of the orders for which match is true
+func (m *InMemoryOrderModel) matching(match func(o *Order) bool) []*Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orders := []*Order{}
+	for _, order := range m.orders {
+		if match(order) {
+			orders = append(orders, copyOrder(order))
+		}
+	}
+	return orders
+}
+
+// publish sends order to the Publisher, if any
+func (m *InMemoryOrderModel) publish(ctx context.Context, order *Order) {
+	if m.Publisher != nil {
+		m.Publisher.PublishOrder(ctx, order)
+	}
+}
@@ -0,0 +1,289 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// limitOrder returns an open limit order for userID
+func limitOrder(userID int, symbol, side string, quantity, price float64) *Order {
+	return &Order{UserID: userID, Symbol: symbol, Side: side, Type: OrderTypeLimit, Quantity: quantity, Price: &price}
+}
+
+func TestOrderInsertAndGet(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+
+	order := limitOrder(user.UserID, " aapl ", OrderSideBuy, 2, 150.5)
+	if err := s.Order.Insert(ctx, order); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if order.OrderID == 0 || order.CreatedAt.IsZero() || order.UpdatedAt.IsZero() {
+		t.Errorf("Insert didn't fill in the generated fields: %+v", order)
+	}
+
+	got, err := s.Order.GetByID(ctx, order.OrderID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Symbol != "AAPL" || got.Status != OrderStatusOpen || got.Side != OrderSideBuy ||
+		got.Quantity != 2 || got.Price == nil || *got.Price != 150.5 {
+		t.Errorf("GetByID = %+v, want the inserted open AAPL order", got)
+	}
+
+	market := &Order{UserID: user.UserID, Symbol: "AAPL", Side: OrderSideSell, Type: OrderTypeMarket, Quantity: 1}
+	if err := s.Order.Insert(ctx, market); err != nil {
+		t.Fatalf("Insert market order: %v", err)
+	}
+	if got, err := s.Order.GetByID(ctx, market.OrderID); err != nil || got.Price != nil {
+		t.Errorf("GetByID market order = %+v, %v, want no price", got, err)
+	}
+
+	if _, err := s.Order.GetByID(ctx, market.OrderID+1); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByID missing = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestOrderListByUser(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	alice := insertTestUser(t, s, "alice")
+	bob := insertTestUser(t, s, "bob")
+
+	var ids []int
+	for i := range 3 {
+		order := limitOrder(alice.UserID, "AAPL", OrderSideBuy, 1, float64(100+i))
+		if err := s.Order.Insert(ctx, order); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		ids = append(ids, order.OrderID)
+	}
+	if err := s.Order.Insert(ctx, limitOrder(bob.UserID, "AAPL", OrderSideBuy, 1, 100)); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   []int
+	}{
+		{"all newest first", 10, 0, []int{ids[2], ids[1], ids[0]}},
+		{"first page", 2, 0, []int{ids[2], ids[1]}},
+		{"second page", 2, 2, []int{ids[0]}},
+		{"past the end", 10, 3, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := s.Order.ListByUser(ctx, alice.UserID, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("ListByUser: %v", err)
+			}
+			got := []int{}
+			for _, order := range orders {
+				got = append(got, order.OrderID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListByUser = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateBook(t *testing.T) {
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+
+	tests := []struct {
+		name   string
+		orders OrderModelInterface
+	}{
+		{"sql", s.Order},
+		{"memory", NewInMemoryOrderModel()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			insert := func(order *Order) *Order {
+				t.Helper()
+				if err := tt.orders.Insert(ctx, order); err != nil {
+					t.Fatalf("Insert: %v", err)
+				}
+				return order
+			}
+
+			insert(limitOrder(user.UserID, "aapl", OrderSideBuy, 5, 99))
+			insert(limitOrder(user.UserID, "AAPL", OrderSideBuy, 3, 99))
+			insert(limitOrder(user.UserID, "AAPL", OrderSideBuy, 2, 98))
+			insert(limitOrder(user.UserID, "AAPL", OrderSideSell, 4, 101))
+			insert(limitOrder(user.UserID, "AAPL", OrderSideSell, 1, 102))
+			insert(&Order{UserID: user.UserID, Symbol: "AAPL", Side: OrderSideSell, Type: OrderTypeMarket, Quantity: 7})
+			insert(limitOrder(user.UserID, "MSFT", OrderSideBuy, 9, 99))
+
+			filled := insert(limitOrder(user.UserID, "AAPL", OrderSideBuy, 6, 99))
+			if err := tt.orders.UpdateStatus(ctx, filled.OrderID, OrderStatusFilled); err != nil {
+				t.Fatalf("UpdateStatus filled: %v", err)
+			}
+			cancelled := insert(limitOrder(user.UserID, "AAPL", OrderSideSell, 8, 101))
+			if err := tt.orders.UpdateStatus(ctx, cancelled.OrderID, OrderStatusCancelled); err != nil {
+				t.Fatalf("UpdateStatus cancelled: %v", err)
+			}
+
+			book, err := tt.orders.AggregateBook(ctx, " aapl ")
+			if err != nil {
+				t.Fatalf("AggregateBook: %v", err)
+			}
+
+			want := &OrderBook{
+				Symbol: "AAPL",
+				Bids:   []BookLevel{{Price: 99, Quantity: 8, Orders: 2}, {Price: 98, Quantity: 2, Orders: 1}},
+				Asks:   []BookLevel{{Price: 101, Quantity: 4, Orders: 1}, {Price: 102, Quantity: 1, Orders: 1}},
+			}
+			if !reflect.DeepEqual(book, want) {
+				t.Errorf("AggregateBook = %+v, want %+v", book, want)
+			}
+		})
+	}
+}
+
+func TestAggregateBookEmpty(t *testing.T) {
+	book, err := newTestStore(t).Order.AggregateBook(context.Background(), "AAPL")
+	if err != nil {
+		t.Fatalf("AggregateBook: %v", err)
+	}
+	if book.Bids == nil || book.Asks == nil || len(book.Bids)+len(book.Asks) != 0 {
+		t.Errorf("AggregateBook = %+v, want empty non-nil sides", book)
+	}
+}
+
+func TestCancelOpen(t *testing.T) {
+	s := newTestStore(t)
+	alice := insertTestUser(t, s, "alice")
+	bob := insertTestUser(t, s, "bob")
+
+	tests := []struct {
+		name   string
+		orders OrderModelInterface
+	}{
+		{"sql", s.Order},
+		{"memory", NewInMemoryOrderModel()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			insert := func(order *Order) *Order {
+				t.Helper()
+				if err := tt.orders.Insert(ctx, order); err != nil {
+					t.Fatalf("Insert: %v", err)
+				}
+				return order
+			}
+
+			first := insert(limitOrder(alice.UserID, "AAPL", OrderSideBuy, 1, 99))
+			second := insert(limitOrder(alice.UserID, "AAPL", OrderSideSell, 1, 101))
+			filled := insert(limitOrder(alice.UserID, "AAPL", OrderSideBuy, 1, 99))
+			if err := tt.orders.UpdateStatus(ctx, filled.OrderID, OrderStatusFilled); err != nil {
+				t.Fatalf("UpdateStatus: %v", err)
+			}
+			otherSymbol := insert(limitOrder(alice.UserID, "MSFT", OrderSideBuy, 1, 99))
+			otherUser := insert(limitOrder(bob.UserID, "AAPL", OrderSideBuy, 1, 99))
+
+			ids, err := tt.orders.CancelOpen(ctx, alice.UserID, "aapl")
+			if err != nil {
+				t.Fatalf("CancelOpen: %v", err)
+			}
+			if want := []int{first.OrderID, second.OrderID}; !slices.Equal(ids, want) {
+				t.Errorf("CancelOpen = %v, want %v", ids, want)
+			}
+
+			wantStatus := map[*Order]string{
+				first:       OrderStatusCancelled,
+				second:      OrderStatusCancelled,
+				filled:      OrderStatusFilled,
+				otherSymbol: OrderStatusOpen,
+				otherUser:   OrderStatusOpen,
+			}
+			for order, want := range wantStatus {
+				got, err := tt.orders.GetByID(ctx, order.OrderID)
+				if err != nil {
+					t.Fatalf("GetByID: %v", err)
+				}
+				if got.Status != want {
+					t.Errorf("order %d status = %s, want %s", order.OrderID, got.Status, want)
+				}
+			}
+
+			ids, err = tt.orders.CancelOpen(ctx, alice.UserID, "AAPL")
+			if err != nil {
+				t.Fatalf("second CancelOpen: %v", err)
+			}
+			if len(ids) != 0 {
+				t.Errorf("second CancelOpen = %v, want none", ids)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusTransitions(t *testing.T) {
+	statuses := []string{OrderStatusOpen, OrderStatusFilled, OrderStatusCancelled}
+	legal := map[[2]string]bool{
+		{OrderStatusOpen, OrderStatusFilled}:    true,
+		{OrderStatusOpen, OrderStatusCancelled}: true,
+	}
+
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				want := legal[[2]string{from, to}]
+				if got := CanTransition(from, to); got != want {
+					t.Errorf("CanTransition = %v, want %v", got, want)
+				}
+
+				order := limitOrder(user.UserID, "AAPL", OrderSideBuy, 1, 100)
+				if err := s.Order.Insert(ctx, order); err != nil {
+					t.Fatalf("Insert: %v", err)
+				}
+				if from != OrderStatusOpen {
+					if err := s.Order.UpdateStatus(ctx, order.OrderID, from); err != nil {
+						t.Fatalf("UpdateStatus to %s: %v", from, err)
+					}
+				}
+
+				err := s.Order.UpdateStatus(ctx, order.OrderID, to)
+				if want && err != nil {
+					t.Fatalf("UpdateStatus = %v, want nil", err)
+				}
+				if !want && !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("UpdateStatus = %v, want ErrInvalidTransition", err)
+				}
+
+				got, err := s.Order.GetByID(ctx, order.OrderID)
+				if err != nil {
+					t.Fatalf("GetByID: %v", err)
+				}
+				wantStatus := from
+				if want {
+					wantStatus = to
+				}
+				if got.Status != wantStatus {
+					t.Errorf("status = %s, want %s", got.Status, wantStatus)
+				}
+				if got.UpdatedAt.Before(order.UpdatedAt) {
+					t.Errorf("updated_at went back from %v to %v", order.UpdatedAt, got.UpdatedAt)
+				}
+			})
+		}
+	}
+
+	if err := s.Order.UpdateStatus(ctx, 9999, OrderStatusCancelled); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("UpdateStatus of a missing order = %v, want ErrNoRecord", err)
+	}
+}
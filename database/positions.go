@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Position struct {
+	UserID    int       `json:"user_id"`
+	Symbol    string    `json:"symbol"`
+	Quantity  float64   `json:"quantity"`
+	AvgPrice  float64   `json:"avg_price"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PositionModelInterface interface {
+	Upsert(ctx context.Context, userID int, symbol string, qtyDelta, price float64) error
+	ListByUser(ctx context.Context, userID int) ([]*Position, error)
+}
+
+// PositionModel wraps a database connection pool for per-user holdings
+type PositionModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled positions.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *PositionModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// quantityEpsilon treats float residue from repeated fills as a flat position
+const quantityEpsilon = 1e-9
+
+// applyFill returns the position after filling qtyDelta (positive to buy,
+// negative to sell) at price. Adding to a position moves the average price
+// to the weighted mean; reducing it keeps the average; closing it resets the
+// average to 0; and flipping from long to short or back starts the new side
+// at price.
+func applyFill(quantity, avgPrice, qtyDelta, price float64) (float64, float64) {
+	newQuantity := quantity + qtyDelta
+	switch {
+	case math.Abs(newQuantity) < quantityEpsilon:
+		return 0, 0
+	case quantity == 0 || (quantity > 0) == (qtyDelta > 0):
+		total := math.Abs(quantity)*avgPrice + math.Abs(qtyDelta)*price
+		return newQuantity, total / math.Abs(newQuantity)
+	case (quantity > 0) != (newQuantity > 0):
+		return newQuantity, price
+	default:
+		return newQuantity, avgPrice
+	}
+}
+
+// Upsert applies a fill of qtyDelta at price to the user's position in
+// symbol, creating the position on its first fill
+func (m *PositionModel) Upsert(ctx context.Context, userID int, symbol string, qtyDelta, price float64) (err error) {
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "positions.upsert", "")
+	defer func() { err = done(err) }()
+
+	tx, err := BeginTx(ctx, m.DB, m.Logger)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.UpsertTx(ctx, tx.Tx, userID, symbol, qtyDelta, price); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit position %s for user %d: %w", NormalizeSymbol(symbol), userID, err)
+	}
+	return nil
+}
+
+// UpsertTx applies a fill to the user's position inside tx, so the matching
+// engine can update positions in the same transaction as the fill. The new
+// quantity and average price are computed by the upsert itself, mirroring
+// applyFill, so concurrent fills can't read a stale position and lose an
+// update on drivers without row locks.
+func (m *PositionModel) UpsertTx(ctx context.Context, tx *sql.Tx, userID int, symbol string, qtyDelta, price float64) (err error) {
+	if qtyDelta == 0 {
+		return errors.New("position quantity delta must not be zero")
+	}
+	if price <= 0 {
+		return errors.New("position price must be positive")
+	}
+	symbol = NormalizeSymbol(symbol)
+
+	// positions holds the current row and excluded the fill; every SET
+	// expression sees the row as it was before the update
+	upsert := fmt.Sprintf(`
+	INSERT INTO positions (user_id, symbol, quantity, avg_price)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (user_id, symbol) DO UPDATE
+	SET quantity = CASE
+			WHEN ABS(positions.quantity + excluded.quantity) < %[1]g THEN 0
+			ELSE positions.quantity + excluded.quantity
+		END,
+		avg_price = CASE
+			WHEN ABS(positions.quantity + excluded.quantity) < %[1]g THEN 0
+			WHEN positions.quantity = 0 OR (positions.quantity > 0) = (excluded.quantity > 0)
+				THEN (ABS(positions.quantity) * positions.avg_price + ABS(excluded.quantity) * excluded.avg_price)
+					/ ABS(positions.quantity + excluded.quantity)
+			WHEN (positions.quantity > 0) <> (positions.quantity + excluded.quantity > 0) THEN excluded.avg_price
+			ELSE positions.avg_price
+		END,
+		updated_at = CURRENT_TIMESTAMP
+	RETURNING quantity, avg_price`, quantityEpsilon)
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "positions.upsert_tx", upsert)
+	defer func() { err = done(err) }()
+
+	var quantity, avgPrice float64
+	if err := tx.QueryRowContext(ctx, m.rebind(upsert), userID, symbol, qtyDelta, price).Scan(&quantity, &avgPrice); err != nil {
+		return fmt.Errorf("failed to update position %s for user %d: %w", symbol, userID, err)
+	}
+
+	m.Logger.Info("Position updated",
+		zap.Int("user_id", userID),
+		zap.String("symbol", symbol),
+		zap.Float64("quantity", quantity),
+		zap.Float64("avg_price", avgPrice))
+
+	return nil
+}
+
+// ListByUser returns the user's open positions ordered by symbol
+func (m *PositionModel) ListByUser(ctx context.Context, userID int) ([]*Position, error) {
+	query := `
+	SELECT user_id, symbol, quantity, avg_price, updated_at
+	FROM positions
+	WHERE user_id = ? AND quantity != 0
+	ORDER BY symbol`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "positions.list", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), userID)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list positions for user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	positions := []*Position{}
+	for rows.Next() {
+		p := &Position{}
+		if err := rows.Scan(&p.UserID, &p.Symbol, &p.Quantity, &p.AvgPrice, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan position: %w", err)
+		}
+		positions = append(positions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list positions for user %d: %w", userID, err)
+	}
+
+	return positions, nil
+}
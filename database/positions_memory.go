@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// InMemoryPositionModel is a PositionModelInterface kept in a map, for tests
+// and demos that don't want a database. Fills are applied with the same
+// averaging rules as PositionModel. The zero value is ready to use.
+type InMemoryPositionModel struct {
+	mu        sync.Mutex
+	positions map[positionKey]*Position
+}
+
+// positionKey identifies a position, as the primary key of positions does
+type positionKey struct {
+	userID int
+	symbol string
+}
+
+var _ PositionModelInterface = (*InMemoryPositionModel)(nil)
+
+// NewInMemoryPositionModel returns an empty InMemoryPositionModel
+func NewInMemoryPositionModel() *InMemoryPositionModel {
+	return &InMemoryPositionModel{}
+}
+
+// Upsert applies a fill of qtyDelta at price to the user's position in
+// symbol, creating the position on its first fill
+func (m *InMemoryPositionModel) Upsert(ctx context.Context, userID int, symbol string, qtyDelta, price float64) error {
+	if qtyDelta == 0 {
+		return errors.New("position quantity delta must not be zero")
+	}
+	if price <= 0 {
+		return errors.New("position price must be positive")
+	}
+	key := positionKey{userID: userID, symbol: NormalizeSymbol(symbol)}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.positions == nil {
+		m.positions = make(map[positionKey]*Position)
+	}
+	position, ok := m.positions[key]
+	if !ok {
+		position = &Position{UserID: key.userID, Symbol: key.symbol}
+		m.positions[key] = position
+	}
+	position.Quantity, position.AvgPrice = applyFill(position.Quantity, position.AvgPrice, qtyDelta, price)
+	position.UpdatedAt = memoryNow()
+	return nil
+}
+
+// ListByUser returns the user's open positions ordered by symbol
+func (m *InMemoryPositionModel) ListByUser(ctx context.Context, userID int) ([]*Position, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	positions := []*Position{}
+	for key, position := range m.positions {
+		if key.userID == userID && position.Quantity != 0 {
+			p := *position
+			positions = append(positions, &p)
+		}
+	}
+	slices.SortFunc(positions, func(a, b *Position) int { return strings.Compare(a.Symbol, b.Symbol) })
+	return positions, nil
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestApplyFill(t *testing.T) {
+	tests := []struct {
+		name             string
+		quantity, avg    float64
+		qtyDelta, price  float64
+		wantQty, wantAvg float64
+	}{
+		{"open long", 0, 0, 10, 100, 10, 100},
+		{"add to long", 10, 100, 10, 110, 20, 105},
+		{"reduce long", 20, 105, -5, 120, 15, 105},
+		{"close long", 15, 105, -15, 90, 0, 0},
+		{"flip long to short", 10, 100, -15, 120, -5, 120},
+		{"add to short", -5, 120, -5, 100, -10, 110},
+		{"cover short", -10, 110, 4, 90, -6, 110},
+	}
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qty, avg := applyFill(tt.quantity, tt.avg, tt.qtyDelta, tt.price)
+			if math.Abs(qty-tt.wantQty) > 1e-9 || math.Abs(avg-tt.wantAvg) > 1e-9 {
+				t.Errorf("applyFill = (%v, %v), want (%v, %v)", qty, avg, tt.wantQty, tt.wantAvg)
+			}
+
+			// The SQL upsert must compute the same position
+			if _, err := s.Position.DB.Exec(`
+				INSERT INTO positions (user_id, symbol, quantity, avg_price) VALUES (?, 'AAPL', ?, ?)
+				ON CONFLICT (user_id, symbol) DO UPDATE SET quantity = excluded.quantity, avg_price = excluded.avg_price`,
+				user.UserID, tt.quantity, tt.avg); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Position.Upsert(context.Background(), user.UserID, "AAPL", tt.qtyDelta, tt.price); err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+			if err := s.Position.DB.QueryRow("SELECT quantity, avg_price FROM positions WHERE user_id = ? AND symbol = 'AAPL'", user.UserID).Scan(&qty, &avg); err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(qty-tt.wantQty) > 1e-9 || math.Abs(avg-tt.wantAvg) > 1e-9 {
+				t.Errorf("Upsert stored (%v, %v), want (%v, %v)", qty, avg, tt.wantQty, tt.wantAvg)
+			}
+		})
+	}
+}
+
+func TestPositionUpsertConcurrentFills(t *testing.T) {
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+
+	const fills = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, fills)
+	for i := range fills {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Position.Upsert(context.Background(), user.UserID, "AAPL", 1, float64(100+i))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+	}
+
+	positions, err := s.Position.ListByUser(context.Background(), user.UserID)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	// Prices 100..119 average to 109.5; a lost update would change both
+	if len(positions) != 1 || positions[0].Quantity != fills || math.Abs(positions[0].AvgPrice-109.5) > 1e-9 {
+		t.Errorf("positions = %+v, want %d AAPL at 109.5", positions, fills)
+	}
+}
+
+func TestPositionUpsertSeries(t *testing.T) {
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+
+	tests := []struct {
+		name      string
+		positions PositionModelInterface
+	}{
+		{"sql", s.Position},
+		{"memory", NewInMemoryPositionModel()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fills := []struct {
+				symbol          string
+				qtyDelta, price float64
+			}{
+				{"aapl", 10, 100},
+				{"AAPL", 10, 110},
+				{"AAPL", -5, 120},
+				{"MSFT", 2, 300},
+				{"MSFT", -2, 310},
+			}
+			for _, f := range fills {
+				if err := tt.positions.Upsert(ctx, user.UserID, f.symbol, f.qtyDelta, f.price); err != nil {
+					t.Fatalf("Upsert %+v: %v", f, err)
+				}
+			}
+			if err := tt.positions.Upsert(ctx, user.UserID, "AAPL", 0, 100); err == nil {
+				t.Error("Upsert with a zero delta succeeded")
+			}
+			if err := tt.positions.Upsert(ctx, user.UserID, "AAPL", 1, 0); err == nil {
+				t.Error("Upsert with a zero price succeeded")
+			}
+
+			positions, err := tt.positions.ListByUser(ctx, user.UserID)
+			if err != nil {
+				t.Fatalf("ListByUser: %v", err)
+			}
+			if len(positions) != 1 {
+				t.Fatalf("ListByUser = %+v, want only the open AAPL position", positions)
+			}
+			p := positions[0]
+			if p.Symbol != "AAPL" || p.Quantity != 15 || p.AvgPrice != 105 {
+				t.Errorf("position = %+v, want 15 AAPL at 105", p)
+			}
+		})
+	}
+}
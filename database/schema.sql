@@ -6,12 +6,102 @@ CREATE TABLE users (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
 	username TEXT NOT NULL UNIQUE,
 	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT,
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	deleted_at DATETIME
 );
 			
 CREATE INDEX idx_users_username ON users(username);
 CREATE INDEX idx_users_email ON users(email);
-		
 
+CREATE TABLE orders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	symbol TEXT NOT NULL,
+	side TEXT NOT NULL CONSTRAINT chk_orders__side CHECK (side IN ('buy', 'sell')),
+	type TEXT NOT NULL CONSTRAINT chk_orders__type CHECK (type IN ('market', 'limit')),
+	quantity REAL NOT NULL CONSTRAINT chk_orders__quantity CHECK (quantity > 0),
+	filled_quantity REAL NOT NULL DEFAULT 0,
+	price REAL CONSTRAINT chk_orders__price CHECK (price IS NULL OR price > 0),
+	status TEXT NOT NULL DEFAULT 'open' CONSTRAINT chk_orders__status CHECK (status IN ('open', 'filled', 'cancelled')),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+CREATE INDEX idx_orders_symbol ON orders(symbol);
+
+CREATE TABLE trades (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL REFERENCES orders(id),
+	symbol TEXT NOT NULL,
+	quantity REAL NOT NULL CONSTRAINT chk_trades__quantity CHECK (quantity > 0),
+	price REAL NOT NULL CONSTRAINT chk_trades__price CHECK (price > 0),
+	executed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_trades_order_id ON trades(order_id);
+
+CREATE TABLE positions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	symbol TEXT NOT NULL,
+	quantity REAL NOT NULL DEFAULT 0,
+	avg_price REAL NOT NULL DEFAULT 0,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT uq_positions_user_symbol UNIQUE (user_id, symbol)
+);
+
+CREATE TABLE accounts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	currency TEXT NOT NULL,
+	balance INTEGER NOT NULL DEFAULT 0 CONSTRAINT chk_accounts__balance CHECK (balance >= 0),
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT uq_accounts_user_currency UNIQUE (user_id, currency)
+);
+
+CREATE TABLE instruments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	symbol TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	exchange TEXT NOT NULL,
+	tick_size REAL NOT NULL CONSTRAINT chk_instruments__tick_size CHECK (tick_size > 0),
+	active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE bars (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	symbol TEXT NOT NULL,
+	timeframe TEXT NOT NULL CONSTRAINT chk_bars__timeframe CHECK (timeframe IN ('1m', '5m', '15m', '30m', '1h', '4h', '1d')),
+	ts DATETIME NOT NULL,
+	open REAL NOT NULL CONSTRAINT chk_bars__open CHECK (open > 0),
+	high REAL NOT NULL CONSTRAINT chk_bars__high CHECK (high >= open AND high >= close AND high >= low),
+	low REAL NOT NULL CONSTRAINT chk_bars__low CHECK (low > 0 AND low <= open AND low <= close),
+	close REAL NOT NULL CONSTRAINT chk_bars__close CHECK (close > 0),
+	volume REAL NOT NULL DEFAULT 0 CONSTRAINT chk_bars__volume CHECK (volume >= 0),
+	CONSTRAINT uq_bars_symbol_timeframe_ts UNIQUE (symbol, timeframe, ts)
+);
+
+CREATE TABLE idempotency_keys (
+	key TEXT PRIMARY KEY,
+	request_hash TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	response_body BLOB,
+	created_at DATETIME NOT NULL
+);
+
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	action TEXT NOT NULL,
+	entity TEXT NOT NULL,
+	entity_id INTEGER NOT NULL,
+	detail TEXT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
 
+CREATE INDEX idx_audit_log_user_id ON audit_log(user_id);
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Store gives access to every model behind its interface, so callers such as
+// the HTTP server don't depend on SQL and can be handed another
+// implementation, e.g. a MemoryStore for tests.
+type Store interface {
+	Users() UserModelInterface
+	Orders() OrderModelInterface
+	Positions() PositionModelInterface
+	Instruments() InstrumentModelInterface
+	Bars() BarModelInterface
+	// Trades, Idempotency and Audit may return nil when the store doesn't
+	// support them, which turns the features off
+	Trades() TradeModelInterface
+	Idempotency() IdempotencyModelInterface
+	Audit() AuditModelInterface
+}
+
+// DatabaseManagerInterface is the part of DatabaseManager the HTTP server
+// uses besides the models: health checks, query metrics and the admin
+// operations. Servers whose store has no database, such as a MemoryStore,
+// are given nil instead.
+type DatabaseManagerInterface interface {
+	Ping(ctx context.Context) error
+	LastPingStatus() (ok bool, at time.Time)
+	CheckDiskSpace(minFreeBytes uint64) error
+	SetQueryObserver(o QueryObserver)
+	ApplyMigrations(ctx context.Context) ([]Migration, error)
+	PendingMigrations(ctx context.Context) ([]Migration, error)
+	ResetData(ctx context.Context) ([]string, error)
+	AddSampleData() error
+	DumpSchema() (string, error)
+	Vacuum() error
+	Analyze() error
+}
+
+var _ DatabaseManagerInterface = (*DatabaseManager)(nil)
+
+// SQLStore is the Store backed by a DatabaseManager's connection pool. Its
+// fields are the concrete models, for callers that need more than the
+// interfaces, such as the matching engine.
+type SQLStore struct {
+	User            *UserModel
+	Order           *OrderModel
+	Position        *PositionModel
+	Instrument      *InstrumentModel
+	Bar             *BarModel
+	Trade           *TradeModel
+	IdempotencyKeys *IdempotencyModel
+	AuditLog        *AuditModel
+	Account         *AccountModel
+}
+
+// NewSQLStore creates the models on dm's connection pool and driver. Every
+// model reports its queries to dm, which records them as metrics and logs
+// the slow ones.
+func NewSQLStore(dm *DatabaseManager, logger *zap.Logger) *SQLStore {
+	return &SQLStore{
+		User:            &UserModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		Order:           &OrderModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		Position:        &PositionModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		Instrument:      &InstrumentModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		Bar:             &BarModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		Trade:           &TradeModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		IdempotencyKeys: &IdempotencyModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		AuditLog:        &AuditModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+		Account:         &AccountModel{DB: dm.DB, Logger: logger, Driver: dm.Driver, Observer: dm},
+	}
+}
+
+func (s *SQLStore) Users() UserModelInterface             { return s.User }
+func (s *SQLStore) Orders() OrderModelInterface           { return s.Order }
+func (s *SQLStore) Positions() PositionModelInterface     { return s.Position }
+func (s *SQLStore) Instruments() InstrumentModelInterface { return s.Instrument }
+func (s *SQLStore) Bars() BarModelInterface               { return s.Bar }
+
+// Trades returns nil if Trade is unset, disabling aggregated price bars
+func (s *SQLStore) Trades() TradeModelInterface {
+	if s.Trade == nil {
+		return nil
+	}
+	return s.Trade
+}
+
+// Idempotency returns nil rather than a nil *IdempotencyModel, so leaving the
+// field unset disables the feature
+func (s *SQLStore) Idempotency() IdempotencyModelInterface {
+	if s.IdempotencyKeys == nil {
+		return nil
+	}
+	return s.IdempotencyKeys
+}
+
+// Audit returns nil if AuditLog is unset, disabling the audit trail
+func (s *SQLStore) Audit() AuditModelInterface {
+	if s.AuditLog == nil {
+		return nil
+	}
+	return s.AuditLog
+}
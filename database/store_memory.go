@@ -0,0 +1,42 @@
+package db
+
+// MemoryStore is a Store whose models are kept in memory, for tests and
+// demos that don't want a database. It has no trades, idempotency keys or
+// audit trail, so the server runs with those features off. Servers using it are
+// given a nil DatabaseManagerInterface.
+type MemoryStore struct {
+	User       *InMemoryUserModel
+	Order      *InMemoryOrderModel
+	Position   *InMemoryPositionModel
+	Instrument *InMemoryInstrumentModel
+	Bar        *InMemoryBarModel
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a MemoryStore with empty models. Instruments must be
+// added with Instrument.Add before orders can be placed for them.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		User:       NewInMemoryUserModel(),
+		Order:      NewInMemoryOrderModel(),
+		Position:   NewInMemoryPositionModel(),
+		Instrument: NewInMemoryInstrumentModel(),
+		Bar:        NewInMemoryBarModel(),
+	}
+}
+
+func (s *MemoryStore) Users() UserModelInterface             { return s.User }
+func (s *MemoryStore) Orders() OrderModelInterface           { return s.Order }
+func (s *MemoryStore) Positions() PositionModelInterface     { return s.Position }
+func (s *MemoryStore) Instruments() InstrumentModelInterface { return s.Instrument }
+func (s *MemoryStore) Bars() BarModelInterface               { return s.Bar }
+
+// Trades returns nil: orders aren't matched, so there are no fills
+func (s *MemoryStore) Trades() TradeModelInterface { return nil }
+
+// Idempotency returns nil: replaying responses needs a database
+func (s *MemoryStore) Idempotency() IdempotencyModelInterface { return nil }
+
+// Audit returns nil: the audit trail needs a database
+func (s *MemoryStore) Audit() AuditModelInterface { return nil }
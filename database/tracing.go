@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the query spans. It uses the global tracer provider, which
+// does nothing until main installs an exporting one.
+var tracer = otel.Tracer("github.com/chrisp986/trader-backend/database")
+
+// startQuery starts a span for operation as a child of any span in ctx, e.g.
+// the one for the HTTP request. The returned function ends the span, reports
+// the query's duration and outcome to o, which may be nil, and returns the
+// query's error, wrapped with ErrDatabaseUnavailable if the pool was closed.
+// query is the SQL, for the slow query log; operations spanning several
+// statements pass "". Run the query with the returned context.
+func startQuery(ctx context.Context, o QueryObserver, driver, operation, query string) (context.Context, func(err error) error) {
+	start := time.Now()
+	if driver == "" {
+		driver = DriverSQLite
+	}
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", driver),
+			attribute.String("db.operation.name", operation),
+		),
+	)
+	return ctx, func(err error) error {
+		err = unavailableError(err)
+		observeQuery(o, operation, query, start, err)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		return err
+	}
+}
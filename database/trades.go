@@ -0,0 +1,203 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Trade struct {
+	TradeID    int       `json:"trade_id"`
+	OrderID    int       `json:"order_id"`
+	Symbol     string    `json:"symbol"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	ExecutedAt time.Time `json:"executed_at"`
+}
+
+// OHLCIntervals maps the intervals TradeModel.OHLC accepts to their length
+var OHLCIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+type TradeModelInterface interface {
+	Insert(ctx context.Context, trade *Trade) error
+	InsertTx(ctx context.Context, tx *sql.Tx, trade *Trade) error
+	ListByOrder(ctx context.Context, orderID int) ([]*Trade, error)
+	OHLC(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]Bar, error)
+}
+
+// TradeModel wraps a database connection pool for executed fills
+type TradeModel struct {
+	DB     *sql.DB
+	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled trades.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *TradeModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// Insert records an executed fill
+func (m *TradeModel) Insert(ctx context.Context, trade *Trade) error {
+	return m.insert(ctx, m.DB, trade)
+}
+
+// InsertTx records an executed fill inside an existing transaction, e.g.
+// alongside OrderModel.UpdateStatusTx
+func (m *TradeModel) InsertTx(ctx context.Context, tx *sql.Tx, trade *Trade) error {
+	return m.insert(ctx, tx, trade)
+}
+
+func (m *TradeModel) insert(ctx context.Context, q queryRower, trade *Trade) error {
+	trade.Symbol = NormalizeSymbol(trade.Symbol)
+
+	query := `
+	INSERT INTO trades (order_id, symbol, quantity, price)
+	VALUES (?, ?, ?, ?)
+	RETURNING id, executed_at`
+
+	start := time.Now()
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "trades.insert", query)
+	err := q.QueryRowContext(ctx, m.rebind(query), trade.OrderID, trade.Symbol, trade.Quantity, trade.Price).
+		Scan(&trade.TradeID, &trade.ExecutedAt)
+	err = done(err)
+
+	duration := time.Since(start)
+
+	if err != nil {
+		m.Logger.Error("Failed to record trade",
+			zap.Int("order_id", trade.OrderID),
+			zap.String("symbol", trade.Symbol),
+			zap.Duration("duration", duration),
+			zap.Error(err))
+		return fmt.Errorf("failed to record trade: %w", err)
+	}
+
+	m.Logger.Info("Trade recorded successfully",
+		zap.Int("trade_id", trade.TradeID),
+		zap.Int("order_id", trade.OrderID),
+		zap.Float64("quantity", trade.Quantity),
+		zap.Float64("price", trade.Price),
+		zap.Duration("duration", duration))
+
+	return nil
+}
+
+// ListByOrder returns the fills for an order in execution order
+func (m *TradeModel) ListByOrder(ctx context.Context, orderID int) ([]*Trade, error) {
+	query := `
+	SELECT id, order_id, symbol, quantity, price, executed_at
+	FROM trades
+	WHERE order_id = ?
+	ORDER BY executed_at, id`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "trades.list", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), orderID)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trades for order %d: %w", orderID, err)
+	}
+	defer rows.Close()
+
+	trades := []*Trade{}
+	for rows.Next() {
+		trade := &Trade{}
+		if err := rows.Scan(&trade.TradeID, &trade.OrderID, &trade.Symbol, &trade.Quantity, &trade.Price, &trade.ExecutedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trade: %w", err)
+		}
+		trades = append(trades, trade)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list trades for order %d: %w", orderID, err)
+	}
+
+	return trades, nil
+}
+
+// OHLC aggregates symbol's trades executed in [from, to] into bars of
+// interval, one of OHLCIntervals, oldest first. A zero from or to leaves
+// that end open. Each fill is recorded once per order, so only the buy
+// side's trades are counted, which keeps volume from doubling. limit is
+// clamped like BarModel.Query's.
+func (m *TradeModel) OHLC(ctx context.Context, symbol, interval string, from, to time.Time, limit int) ([]Bar, error) {
+	length, ok := OHLCIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unknown OHLC interval %q", interval)
+	}
+	switch {
+	case limit <= 0:
+		limit = DefaultBarLimit
+	case limit > MaxBarLimit:
+		limit = MaxBarLimit
+	}
+	symbol = NormalizeSymbol(symbol)
+
+	conditions := []string{"t.symbol = ?"}
+	args := []any{symbol}
+	if !from.IsZero() {
+		conditions = append(conditions, "t.executed_at >= ?")
+		args = append(args, timestampArg(m.Driver, from))
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "t.executed_at <= ?")
+		args = append(args, timestampArg(m.Driver, to))
+	}
+	args = append(args, limit)
+
+	// The interval's length comes from OHLCIntervals, never from the caller
+	seconds := int64(length / time.Second)
+	query := fmt.Sprintf(`
+	WITH ticks AS (
+		SELECT t.id, t.price, t.quantity, t.executed_at,
+			%[1]s / %[2]d * %[2]d AS bucket
+		FROM trades t
+		JOIN orders o ON o.id = t.order_id AND o.side = 'buy'
+		WHERE %[3]s
+	), ranked AS (
+		SELECT bucket, price, quantity,
+			FIRST_VALUE(price) OVER (PARTITION BY bucket ORDER BY executed_at, id) AS open,
+			FIRST_VALUE(price) OVER (PARTITION BY bucket ORDER BY executed_at DESC, id DESC) AS close
+		FROM ticks
+	)
+	SELECT bucket, MIN(open), MAX(price), MIN(price), MIN(close), SUM(quantity)
+	FROM ranked
+	GROUP BY bucket
+	ORDER BY bucket
+	LIMIT ?`, unixSeconds(m.Driver, "t.executed_at"), seconds, strings.Join(conditions, " AND "))
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "trades.ohlc", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), args...)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate %s %s bars: %w", symbol, interval, err)
+	}
+	defer rows.Close()
+
+	bars := []Bar{}
+	for rows.Next() {
+		b := Bar{Symbol: symbol, Timeframe: interval}
+		var bucket int64
+		if err := rows.Scan(&bucket, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+			return nil, fmt.Errorf("failed to scan bar: %w", err)
+		}
+		b.Timestamp = time.Unix(bucket, 0).UTC()
+		bars = append(bars, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate %s %s bars: %w", symbol, interval, err)
+	}
+
+	return bars, nil
+}
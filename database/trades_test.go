@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestTradeInsertAndListByOrder(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "alice")
+	order := limitOrder(user.UserID, "AAPL", OrderSideBuy, 3, 100)
+	other := limitOrder(user.UserID, "AAPL", OrderSideBuy, 1, 100)
+	for _, o := range []*Order{order, other} {
+		if err := s.Order.Insert(ctx, o); err != nil {
+			t.Fatalf("Insert order: %v", err)
+		}
+	}
+
+	first := &Trade{OrderID: order.OrderID, Symbol: "aapl", Quantity: 1, Price: 99.5}
+	second := &Trade{OrderID: order.OrderID, Symbol: "AAPL", Quantity: 2, Price: 100}
+	for _, trade := range []*Trade{first, second, {OrderID: other.OrderID, Symbol: "AAPL", Quantity: 1, Price: 100}} {
+		if err := s.Trade.Insert(ctx, trade); err != nil {
+			t.Fatalf("Insert trade: %v", err)
+		}
+	}
+	if first.TradeID == 0 || first.ExecutedAt.IsZero() || first.Symbol != "AAPL" {
+		t.Errorf("Insert = %+v, want an id, execution time and normalized symbol", first)
+	}
+
+	trades, err := s.Trade.ListByOrder(ctx, order.OrderID)
+	if err != nil {
+		t.Fatalf("ListByOrder: %v", err)
+	}
+	if len(trades) != 2 || trades[0].TradeID != first.TradeID || trades[1].TradeID != second.TradeID {
+		t.Fatalf("ListByOrder = %+v, want the order's two trades in execution order", trades)
+	}
+	if trades[1].Quantity != 2 || trades[1].Price != 100 {
+		t.Errorf("second trade = %+v", trades[1])
+	}
+}
+
+func TestTradeInsertTxWithStatusUpdate(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	user := insertTestUser(t, s, "alice")
+
+	fill := func(commit bool) *Order {
+		t.Helper()
+		order := limitOrder(user.UserID, "AAPL", OrderSideBuy, 1, 100)
+		if err := s.Order.Insert(ctx, order); err != nil {
+			t.Fatalf("Insert order: %v", err)
+		}
+
+		tx, err := BeginTx(ctx, dm.DB, zap.NewNop())
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		defer tx.Rollback()
+		if err := s.Trade.InsertTx(ctx, tx.Tx, &Trade{OrderID: order.OrderID, Symbol: "AAPL", Quantity: 1, Price: 100}); err != nil {
+			t.Fatalf("InsertTx: %v", err)
+		}
+		if err := s.Order.UpdateStatusTx(ctx, tx.Tx, order.OrderID, OrderStatusFilled); err != nil {
+			t.Fatalf("UpdateStatusTx: %v", err)
+		}
+		if commit {
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Commit: %v", err)
+			}
+		}
+		return order
+	}
+
+	check := func(order *Order, trades int, status string) {
+		t.Helper()
+		got, err := s.Trade.ListByOrder(ctx, order.OrderID)
+		if err != nil {
+			t.Fatalf("ListByOrder: %v", err)
+		}
+		stored, err := s.Order.GetByID(ctx, order.OrderID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if len(got) != trades || stored.Status != status {
+			t.Errorf("order %d has %d trades and status %s, want %d and %s", order.OrderID, len(got), stored.Status, trades, status)
+		}
+	}
+
+	check(fill(true), 1, OrderStatusFilled)
+	check(fill(false), 0, OrderStatusOpen)
+}
+
+// insertTick records a fill of qty at price between buy and sell, executed
+// at the given time, the way the matching engine stores one per side
+func insertTick(t *testing.T, s *SQLStore, buy, sell *Order, qty, price float64, at time.Time) {
+	t.Helper()
+
+	for _, order := range []*Order{buy, sell} {
+		trade := &Trade{OrderID: order.OrderID, Symbol: order.Symbol, Quantity: qty, Price: price}
+		if err := s.Trade.Insert(context.Background(), trade); err != nil {
+			t.Fatalf("Insert trade: %v", err)
+		}
+		if _, err := s.Trade.DB.Exec("UPDATE trades SET executed_at = ? WHERE id = ?", at.UTC().Format(sqliteTimestampLayout), trade.TradeID); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTradeOHLC(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	alice := insertTestUser(t, s, "alice")
+	bob := insertTestUser(t, s, "bob")
+	orders := map[string]*Order{}
+	for _, o := range []*Order{
+		limitOrder(alice.UserID, "AAPL", OrderSideBuy, 100, 110),
+		limitOrder(bob.UserID, "AAPL", OrderSideSell, 100, 90),
+		limitOrder(alice.UserID, "MSFT", OrderSideBuy, 100, 110),
+		limitOrder(bob.UserID, "MSFT", OrderSideSell, 100, 90),
+	} {
+		if err := s.Order.Insert(ctx, o); err != nil {
+			t.Fatalf("Insert order: %v", err)
+		}
+		orders[o.Symbol+" "+o.Side] = o
+	}
+	tick := func(symbol string, qty, price float64, at string) {
+		t.Helper()
+		ts, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		insertTick(t, s, orders[symbol+" buy"], orders[symbol+" sell"], qty, price, ts)
+	}
+	tick("AAPL", 1, 100, "2026-10-16T09:30:00Z")
+	tick("AAPL", 2, 102, "2026-10-16T09:30:30Z")
+	tick("AAPL", 1, 99, "2026-10-16T12:00:00Z")
+	tick("AAPL", 3, 101, "2026-10-16T15:59:59Z")
+	tick("AAPL", 1, 105, "2026-10-17T09:31:00Z")
+	tick("MSFT", 5, 300, "2026-10-16T10:00:00Z")
+
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		interval string
+		from, to time.Time
+		limit    int
+		want     []Bar
+	}{
+		{"daily", "1d", time.Time{}, time.Time{}, 0, []Bar{
+			{Timestamp: day, Open: 100, High: 102, Low: 99, Close: 101, Volume: 7},
+			{Timestamp: day.AddDate(0, 0, 1), Open: 105, High: 105, Low: 105, Close: 105, Volume: 1},
+		}},
+		{"hourly within a day", "1h", day, day.Add(24*time.Hour - time.Second), 0, []Bar{
+			{Timestamp: day.Add(9 * time.Hour), Open: 100, High: 102, Low: 100, Close: 102, Volume: 3},
+			{Timestamp: day.Add(12 * time.Hour), Open: 99, High: 99, Low: 99, Close: 99, Volume: 1},
+			{Timestamp: day.Add(15 * time.Hour), Open: 101, High: 101, Low: 101, Close: 101, Volume: 3},
+		}},
+		{"limited", "1m", time.Time{}, time.Time{}, 1, []Bar{
+			{Timestamp: day.Add(9*time.Hour + 30*time.Minute), Open: 100, High: 102, Low: 100, Close: 102, Volume: 3},
+		}},
+		{"empty range", "1d", day.AddDate(0, 0, 5), time.Time{}, 0, []Bar{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bars, err := s.Trade.OHLC(ctx, " aapl ", tt.interval, tt.from, tt.to, tt.limit)
+			if err != nil {
+				t.Fatalf("OHLC: %v", err)
+			}
+			if len(bars) != len(tt.want) {
+				t.Fatalf("OHLC = %+v, want %d bars", bars, len(tt.want))
+			}
+			for i, want := range tt.want {
+				want.Symbol, want.Timeframe = "AAPL", tt.interval
+				if bars[i] != want {
+					t.Errorf("bar %d = %+v, want %+v", i, bars[i], want)
+				}
+			}
+		})
+	}
+
+	if _, err := s.Trade.OHLC(ctx, "AAPL", "5m", time.Time{}, time.Time{}, 0); err == nil {
+		t.Error("OHLC with an unknown interval succeeded")
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// txCounter hands out process-unique transaction ids for log correlation
+var txCounter atomic.Uint64
+
+// openTxs maps each *sql.DB to the number of Tx wrappers open on it, so
+// Vacuum can refuse to run while one is
+var openTxs sync.Map
+
+// openTxCount returns the open transaction counter for database
+func openTxCount(database *sql.DB) *atomic.Int64 {
+	count, _ := openTxs.LoadOrStore(database, new(atomic.Int64))
+	return count.(*atomic.Int64)
+}
+
+// Tx wraps *sql.Tx and logs its begin, commit and rollback at debug level,
+// tagged with a transaction id and the time since it began
+type Tx struct {
+	*sql.Tx
+	id     uint64
+	start  time.Time
+	logger *zap.Logger
+	open   *atomic.Int64
+	done   atomic.Bool
+}
+
+// beginTx starts a logging-aware transaction
+func (dm *DatabaseManager) beginTx(ctx context.Context) (*Tx, error) {
+	return BeginTx(ctx, dm.DB, dm.logger)
+}
+
+// BeginTx starts a logging-aware transaction on database. Models and the
+// matching engine use it instead of calling database.BeginTx directly so
+// every transaction is logged the same way.
+func BeginTx(ctx context.Context, database *sql.DB, logger *zap.Logger) (*Tx, error) {
+	sqlTx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, unavailableError(err)
+	}
+
+	tx := &Tx{
+		Tx:     sqlTx,
+		id:     txCounter.Add(1),
+		start:  time.Now(),
+		logger: logger,
+		open:   openTxCount(database),
+	}
+	tx.open.Add(1)
+	tx.logger.Debug("Transaction started", zap.Uint64("tx_id", tx.id))
+	return tx, nil
+}
+
+// Commit commits the transaction and logs the outcome
+func (tx *Tx) Commit() error {
+	err := tx.Tx.Commit()
+	tx.finish()
+	if err != nil {
+		tx.logger.Debug("Transaction commit failed",
+			zap.Uint64("tx_id", tx.id),
+			zap.Duration("duration", time.Since(tx.start)),
+			zap.Error(err))
+		return err
+	}
+
+	tx.logger.Debug("Transaction committed",
+		zap.Uint64("tx_id", tx.id),
+		zap.Duration("duration", time.Since(tx.start)))
+	return nil
+}
+
+// Rollback aborts the transaction and logs it. Rolling back a transaction
+// that has already finished is not logged.
+func (tx *Tx) Rollback() error {
+	err := tx.Tx.Rollback()
+	tx.finish()
+	if errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+
+	tx.logger.Debug("Transaction rolled back",
+		zap.Uint64("tx_id", tx.id),
+		zap.Duration("duration", time.Since(tx.start)),
+		zap.Error(err))
+	return err
+}
+
+// finish removes the transaction from its database's open count the first
+// time it commits or rolls back
+func (tx *Tx) finish() {
+	if tx.done.CompareAndSwap(false, true) {
+		tx.open.Add(-1)
+	}
+}
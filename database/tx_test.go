@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// userCount returns how many users dm holds, deleted ones included
+func userCount(t *testing.T, dm *DatabaseManager) int {
+	t.Helper()
+
+	var n int
+	if err := dm.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	return n
+}
+
+func TestWithTransactionCommitsOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	users := NewSQLStore(dm, zap.NewNop()).User
+
+	err := dm.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return users.InsertTx(ctx, tx, &User{Username: "alice", Email: "alice@example.com"})
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+	if n := userCount(t, dm); n != 1 {
+		t.Errorf("users after commit = %d, want 1", n)
+	}
+}
+
+func TestWithTransactionRollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	users := NewSQLStore(dm, zap.NewNop()).User
+
+	failure := errors.New("second step failed")
+	err := dm.WithTransaction(ctx, func(tx *sql.Tx) error {
+		if err := users.InsertTx(ctx, tx, &User{Username: "alice", Email: "alice@example.com"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTransaction = %v, want %v", err, failure)
+	}
+	if n := userCount(t, dm); n != 0 {
+		t.Errorf("users after rollback = %d, want 0", n)
+	}
+}
+
+func TestWithTransactionRollsBackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	users := NewSQLStore(dm, zap.NewNop()).User
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the panic re-raised", r)
+			}
+		}()
+		dm.WithTransaction(ctx, func(tx *sql.Tx) error {
+			if err := users.InsertTx(ctx, tx, &User{Username: "alice", Email: "alice@example.com"}); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	if n := userCount(t, dm); n != 0 {
+		t.Errorf("users after panic = %d, want 0", n)
+	}
+}
+
+// txMessages returns the messages of the logged transaction events, checking
+// they all carry the same tx_id
+func txMessages(t *testing.T, logs *observer.ObservedLogs) []string {
+	t.Helper()
+
+	var messages []string
+	var id any
+	for i, entry := range logs.All() {
+		txID, ok := entry.ContextMap()["tx_id"]
+		if !ok {
+			t.Errorf("%q logged without a tx_id", entry.Message)
+		}
+		if i > 0 && txID != id {
+			t.Errorf("%q logged tx_id %v, want %v", entry.Message, txID, id)
+		}
+		id = txID
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func TestTxLogsCommit(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	dm := newTestManager(t)
+
+	tx, err := BeginTx(context.Background(), dm.DB, zap.New(core))
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	// Rolling back a committed transaction, as deferred rollbacks do, logs nothing
+	tx.Rollback()
+
+	want := []string{"Transaction started", "Transaction committed"}
+	if got := txMessages(t, logs); !slices.Equal(got, want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
+
+func TestTxLogsRollback(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	dm := newTestManager(t)
+
+	tx, err := BeginTx(context.Background(), dm.DB, zap.New(core))
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	want := []string{"Transaction started", "Transaction rolled back"}
+	if got := txMessages(t, logs); !slices.Equal(got, want) {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+}
+
+func TestWithTransactionLogsRollbackOnPanic(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	dm, err := NewDatabaseManager(DriverSQLite, ":memory:", zap.New(core))
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	if err := dm.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer dm.Close()
+
+	func() {
+		defer func() { recover() }()
+		dm.WithTransaction(context.Background(), func(tx *sql.Tx) error { panic("boom") })
+	}()
+
+	rolledBack := logs.FilterMessage("Transaction rolled back").Len()
+	if rolledBack != 1 {
+		t.Errorf("logged %d rollbacks after a panic, want 1", rolledBack)
+	}
+}
+
+func TestConstraintErrorInTransactionReleasesConnection(t *testing.T) {
+	ctx := context.Background()
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	insertTestUser(t, s, "alice")
+	baseline := dm.DB.Stats().InUse
+
+	users := []*User{
+		{Username: "bob", Email: "bob@example.com"},
+		{Username: "alice2", Email: "alice@example.com"},
+	}
+	failed, err := s.User.InsertAll(ctx, users)
+	if failed != 1 || !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("InsertAll = %d, %v, want 1, ErrDuplicateEmail", failed, err)
+	}
+
+	err = dm.WithTransaction(ctx, func(tx *sql.Tx) error {
+		return s.User.InsertTx(ctx, tx, &User{Username: "alice3", Email: "alice@example.com"})
+	})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("WithTransaction = %v, want ErrDuplicateEmail", err)
+	}
+
+	if inUse := dm.DB.Stats().InUse; inUse != baseline {
+		t.Errorf("%d connections in use after the failed transactions, want %d", inUse, baseline)
+	}
+	if _, err := s.User.GetByEmail(ctx, "bob@example.com"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByEmail of rolled back user = %v, want ErrNoRecord", err)
+	}
+}
@@ -1,53 +1,259 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	UserID    int    `json:"user_id"`
-	Username  string `json:"user_name"`
-	Email     string `json:"email"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"user_name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set once the user is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Password is the plain-text input hashed by Insert; it's never stored
+	// or serialized
+	Password string `json:"-"`
 }
 
 type UserModelInterface interface {
-	Insert(user *User) error
-	// Authenticate(email, password string) (int, error)
-	// Exists(id int) (bool, error)
+	Insert(ctx context.Context, user *User) error
+	InsertAll(ctx context.Context, users []*User) (int, error)
+	InsertWithTimestamps(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id int) (*User, error)
+	GetByIDIncludeDeleted(ctx context.Context, id int) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) error
+	List(ctx context.Context, limit, offset int, sort string) ([]*User, error)
+	ListIncludeDeleted(ctx context.Context, limit, offset int, sort string) ([]*User, error)
+	Search(ctx context.Context, term string, limit, offset int) ([]*User, error)
+	Count(ctx context.Context) (int, error)
+	ListFiltered(ctx context.Context, filter UserFilter, limit, offset int, sort string) ([]*User, error)
+	ListAfter(ctx context.Context, afterID, limit int) ([]*User, error)
+	CountFiltered(ctx context.Context, filter UserFilter) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Authenticate(ctx context.Context, email, password string) (int, error)
+}
+
+const (
+	// passwordHashCost is the bcrypt cost used when hashing passwords
+	passwordHashCost = 12
+
+	// DefaultListLimit is used when List is called with a non-positive limit
+	DefaultListLimit = 20
+	// MaxListLimit caps the number of users List returns in one page
+	MaxListLimit = 100
+)
+
+// UserSortColumns are the columns List can order by
+var UserSortColumns = []string{"id", "username", "email", "created_at"}
+
+// orderByClause turns a sort key such as "username" or "-created_at" into an
+// ORDER BY clause. Only columns in allowed are accepted, so user input never
+// reaches the SQL; an empty key orders by id.
+func orderByClause(sort string, allowed []string) (string, error) {
+	if sort == "" {
+		return "ORDER BY id", nil
+	}
+
+	column, desc := strings.CutPrefix(sort, "-")
+	if !slices.Contains(allowed, column) {
+		return "", fmt.Errorf("unsupported sort column %q", column)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	// Tie-break on id so pages stay stable when sort values repeat
+	return fmt.Sprintf("ORDER BY %s %s, id %s", column, direction, direction), nil
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx, so model methods can
+// run either standalone or inside a caller's transaction.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 // Define a new UserModel type which wraps a database connection pool.
 type UserModel struct {
 	DB     *sql.DB
 	Logger *zap.Logger
+	// Driver selects the placeholder syntax; empty means DriverSQLite
+	Driver string
+	// Observer, if set, receives the duration and outcome of each query,
+	// labelled users.<operation>
+	Observer QueryObserver
+}
+
+// rebind rewrites query's placeholders for the model's driver
+func (m *UserModel) rebind(query string) string {
+	return Rebind(m.Driver, query)
+}
+
+// normalizeEmail trims surrounding whitespace and lowercases the address so
+// that case variants like JOHN@example.com and john@example.com collide on the
+// UNIQUE constraint instead of creating separate accounts.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// duplicateUserError maps a UNIQUE violation on users.email or
+// users.username to ErrDuplicateEmail or ErrDuplicateUsername. It returns nil
+// for any other error.
+func duplicateUserError(err error) error {
+	cerr, ok := AsConstraintError(err)
+	if !ok || cerr.Kind != ConstraintDuplicate {
+		return nil
+	}
+	switch {
+	case slices.Contains(cerr.Fields, "email"):
+		return ErrDuplicateEmail
+	case slices.Contains(cerr.Fields, "username"):
+		return ErrDuplicateUsername
+	}
+	return nil
+}
+
+// Insert creates a new user. It returns ErrDuplicateEmail or
+// ErrDuplicateUsername if either value is already taken.
+func (m *UserModel) Insert(ctx context.Context, user *User) error {
+	return m.insert(ctx, m.DB, user, false)
+}
+
+// InsertTx creates a new user inside an existing transaction, e.g. one
+// started with DatabaseManager.WithTransaction
+func (m *UserModel) InsertTx(ctx context.Context, tx *sql.Tx, user *User) error {
+	return m.insert(ctx, tx, user, false)
 }
 
-// CreateUser creates a new user
-func (m *UserModel) Insert(user *User) error {
+// InsertAll creates users in one transaction, so either all of them are
+// created or none is. If one fails it returns that user's index and error,
+// e.g. ErrDuplicateEmail; failures not caused by a particular user, such as
+// a failed commit, return -1.
+func (m *UserModel) InsertAll(ctx context.Context, users []*User) (failed int, err error) {
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.insert_all", "")
+	defer func() { err = done(err) }()
+
+	tx, err := BeginTx(ctx, m.DB, m.Logger)
+	if err != nil {
+		return -1, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, user := range users {
+		if err := m.insert(ctx, tx, user, false); err != nil {
+			return i, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return -1, fmt.Errorf("failed to commit users: %w", err)
+	}
+	return -1, nil
+}
+
+// InsertWithTimestamps creates a new user like Insert, but keeps a non-zero
+// user.CreatedAt instead of using the current time, e.g. for users imported
+// from another system. UpdatedAt is kept too if set, and otherwise equals
+// CreatedAt. Times are stored in UTC at the precision of the column, whole
+// seconds on SQLite. It returns ErrInvalidTimestamp for times in the future,
+// before the Unix epoch, or with UpdatedAt before CreatedAt.
+func (m *UserModel) InsertWithTimestamps(ctx context.Context, user *User) error {
+	if user.CreatedAt.IsZero() {
+		return m.insert(ctx, m.DB, user, false)
+	}
+	if err := validateTimestamps(user.CreatedAt, user.UpdatedAt); err != nil {
+		return err
+	}
+	return m.insert(ctx, m.DB, user, true)
+}
+
+// maxClockSkew is how far in the future a supplied timestamp may be, to allow
+// for clocks on the source system running slightly ahead
+const maxClockSkew = time.Minute
+
+// validateTimestamps checks caller-supplied created and updated times; a
+// zero updated time is allowed and defaults to created
+func validateTimestamps(created, updated time.Time) error {
+	latest := time.Now().Add(maxClockSkew)
+	switch {
+	case created.Before(time.Unix(0, 0)) || created.After(latest):
+		return fmt.Errorf("%w: created_at %s must be between 1970 and now", ErrInvalidTimestamp, created.Format(time.RFC3339))
+	case updated.IsZero():
+		return nil
+	case updated.Before(created) || updated.After(latest):
+		return fmt.Errorf("%w: updated_at %s must be between created_at and now", ErrInvalidTimestamp, updated.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// insert adds user. With keepTimestamps the user's CreatedAt and UpdatedAt
+// are stored instead of the column defaults; InsertWithTimestamps has
+// validated them.
+func (m *UserModel) insert(ctx context.Context, q queryRower, user *User, keepTimestamps bool) error {
+	user.Email = normalizeEmail(user.Email)
+
+	// Users created without a password get a NULL hash and can't log in
+	var passwordHash sql.NullString
+	if user.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), passwordHashCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		passwordHash = sql.NullString{String: string(hash), Valid: true}
+	}
+
 	query := `
-	INSERT INTO users (user_id, user_name, email) 
+	INSERT INTO users (username, email, password_hash) 
 	VALUES (?, ?, ?) 
 	RETURNING id, created_at, updated_at`
+	args := []any{user.Username, user.Email, passwordHash}
+	if keepTimestamps {
+		updated := user.UpdatedAt
+		if updated.IsZero() {
+			updated = user.CreatedAt
+		}
+		query = `
+	INSERT INTO users (username, email, password_hash, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING id, created_at, updated_at`
+		args = append(args, timestampArg(m.Driver, user.CreatedAt), timestampArg(m.Driver, updated))
+	}
 
 	m.Logger.Info("Creating new user",
-		zap.Int("user_id", user.UserID),
 		zap.String("username", user.Username),
 		zap.String("email", user.Email))
 
 	start := time.Now()
-	err := m.DB.QueryRow(query, user.UserID, user.Username, user.Email).Scan(&user.CreatedAt, &user.UpdatedAt)
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.insert", query)
+	err := q.QueryRowContext(ctx, m.rebind(query), args...).Scan(&user.UserID, &user.CreatedAt, &user.UpdatedAt)
+	err = done(err)
 
 	duration := time.Since(start)
 
 	if err != nil {
+		if dupErr := duplicateUserError(err); dupErr != nil {
+			m.Logger.Info("Rejected duplicate user",
+				zap.String("username", user.Username),
+				zap.Error(dupErr))
+			return dupErr
+		}
+
 		m.Logger.Error("Failed to create user",
-			zap.Int("user_id", user.UserID),
 			zap.String("username", user.Username),
 			zap.String("email", user.Email),
 			zap.Duration("duration", duration),
@@ -62,3 +268,413 @@ func (m *UserModel) Insert(user *User) error {
 
 	return nil
 }
+
+// userColumns is the column list scanned by scanUser
+const userColumns = `id, username, email, created_at, updated_at, deleted_at`
+
+// notDeleted restricts a query on users to those that aren't soft-deleted
+const notDeleted = `deleted_at IS NULL`
+
+// scanUser reads one row selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	user := &User{}
+	var deletedAt sql.NullTime
+	if err := row.Scan(&user.UserID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	return user, nil
+}
+
+// getUser returns the single user matching where, or ErrNoRecord
+func (m *UserModel) getUser(ctx context.Context, where string, arg any) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + where
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.get", query)
+	user, err := scanUser(m.DB.QueryRowContext(ctx, m.rebind(query), arg))
+	err = done(err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetByID returns the user with the given id, or ErrNoRecord if none exists
+// or it has been deleted
+func (m *UserModel) GetByID(ctx context.Context, id int) (*User, error) {
+	user, err := m.getUser(ctx, `id = ? AND `+notDeleted, id)
+	if err != nil && !errors.Is(err, ErrNoRecord) {
+		return nil, fmt.Errorf("failed to get user %d: %w", id, err)
+	}
+	return user, err
+}
+
+// GetByIDIncludeDeleted is GetByID that also returns soft-deleted users,
+// which have DeletedAt set
+func (m *UserModel) GetByIDIncludeDeleted(ctx context.Context, id int) (*User, error) {
+	user, err := m.getUser(ctx, `id = ?`, id)
+	if err != nil && !errors.Is(err, ErrNoRecord) {
+		return nil, fmt.Errorf("failed to get user %d: %w", id, err)
+	}
+	return user, err
+}
+
+// GetByEmail returns the user with the given email, or ErrNoRecord if none
+// exists. The email is normalized first so lookups are case-insensitive.
+func (m *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := m.getUser(ctx, `email = ? AND `+notDeleted, normalizeEmail(email))
+	if err != nil && !errors.Is(err, ErrNoRecord) {
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	return user, err
+}
+
+// GetByUsername returns the user with the given username, or ErrNoRecord if
+// none exists
+func (m *UserModel) GetByUsername(ctx context.Context, username string) (*User, error) {
+	user, err := m.getUser(ctx, `username = ? AND `+notDeleted, username)
+	if err != nil && !errors.Is(err, ErrNoRecord) {
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+	return user, err
+}
+
+// Update changes the username and email of the user identified by
+// user.UserID. It returns ErrNoRecord if the user doesn't exist or has been
+// deleted, and ErrDuplicateEmail or ErrDuplicateUsername if the new values
+// are taken.
+func (m *UserModel) Update(ctx context.Context, user *User) error {
+	user.Email = normalizeEmail(user.Email)
+
+	query := `
+	UPDATE users
+	SET username = ?, email = ?, updated_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND ` + notDeleted + `
+	RETURNING created_at, updated_at`
+
+	start := time.Now()
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.update", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), user.Username, user.Email, user.UserID).Scan(&user.CreatedAt, &user.UpdatedAt)
+	err = done(err)
+
+	duration := time.Since(start)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+
+		if dupErr := duplicateUserError(err); dupErr != nil {
+			return dupErr
+		}
+
+		m.Logger.Error("Failed to update user",
+			zap.Int("user_id", user.UserID),
+			zap.Duration("duration", duration),
+			zap.Error(err))
+		return fmt.Errorf("failed to update user %d: %w", user.UserID, err)
+	}
+
+	m.Logger.Info("User updated successfully",
+		zap.Int("user_id", user.UserID),
+		zap.String("username", user.Username),
+		zap.Duration("duration", duration))
+
+	return nil
+}
+
+// Delete soft-deletes the user with the given id by setting deleted_at. The
+// row is kept, along with the records that refer to it, and can be brought
+// back with Restore; its email and username stay taken meanwhile. It returns
+// ErrNoRecord if no such user exists or it is already deleted.
+func (m *UserModel) Delete(ctx context.Context, id int) error {
+	query := `
+	UPDATE users
+	SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND ` + notDeleted
+
+	start := time.Now()
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.delete", query)
+	result, err := m.DB.ExecContext(ctx, m.rebind(query), id)
+	err = done(err)
+
+	duration := time.Since(start)
+
+	if err != nil {
+		m.Logger.Error("Failed to delete user",
+			zap.Int("user_id", id),
+			zap.Duration("duration", duration),
+			zap.Error(err))
+		return fmt.Errorf("failed to delete user %d: %w", id, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check deleted rows for user %d: %w", id, err)
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	m.Logger.Info("User deleted successfully",
+		zap.Int("user_id", id),
+		zap.Duration("duration", duration))
+
+	return nil
+}
+
+// Restore undoes Delete for the user with the given id. It returns
+// ErrNoRecord if no such user exists or it isn't deleted.
+func (m *UserModel) Restore(ctx context.Context, id int) error {
+	query := `
+	UPDATE users
+	SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND deleted_at IS NOT NULL`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.restore", query)
+	result, err := m.DB.ExecContext(ctx, m.rebind(query), id)
+	err = done(err)
+	if err != nil {
+		return fmt.Errorf("failed to restore user %d: %w", id, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check restored rows for user %d: %w", id, err)
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	m.Logger.Info("User restored", zap.Int("user_id", id))
+	return nil
+}
+
+// UserFilter narrows ListFiltered and CountFiltered. Zero values leave a
+// bound open.
+type UserFilter struct {
+	// CreatedFrom and CreatedTo bound created_at, inclusive. created_at has
+	// one-second resolution, so sub-second parts are ignored.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// IncludeDeleted also selects soft-deleted users
+	IncludeDeleted bool
+	// AfterID selects only users with a greater id, for paging through all
+	// users in id order without offsets
+	AfterID int
+}
+
+// where returns the WHERE clause selecting the users that match f, and its
+// arguments
+func (f UserFilter) where(driver string) (string, []any) {
+	var conditions []string
+	var args []any
+	if !f.IncludeDeleted {
+		conditions = append(conditions, notDeleted)
+	}
+	if !f.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, timestampArg(driver, f.CreatedFrom))
+	}
+	if !f.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, timestampArg(driver, f.CreatedTo))
+	}
+	if f.AfterID > 0 {
+		conditions = append(conditions, "id > ?")
+		args = append(args, f.AfterID)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// List returns a page of users ordered by sort, one of UserSortColumns with an
+// optional "-" prefix for descending order, or by id when sort is empty. A
+// non-positive limit falls back to DefaultListLimit and limits above
+// MaxListLimit are capped. Deleted users are left out.
+func (m *UserModel) List(ctx context.Context, limit, offset int, sort string) ([]*User, error) {
+	return m.ListFiltered(ctx, UserFilter{}, limit, offset, sort)
+}
+
+// ListIncludeDeleted is List that also returns soft-deleted users
+func (m *UserModel) ListIncludeDeleted(ctx context.Context, limit, offset int, sort string) ([]*User, error) {
+	return m.ListFiltered(ctx, UserFilter{IncludeDeleted: true}, limit, offset, sort)
+}
+
+// ListFiltered is List restricted to the users matching filter
+func (m *UserModel) ListFiltered(ctx context.Context, filter UserFilter, limit, offset int, sort string) ([]*User, error) {
+	orderBy, err := orderByClause(sort, UserSortColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	where, args := filter.where(m.Driver)
+	query := `SELECT ` + userColumns + ` FROM users` + where + `
+	` + orderBy + `
+	LIMIT ? OFFSET ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.list", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), append(args, limit, offset)...)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// ListAfter returns up to limit users with an id greater than afterID,
+// ordered by id. Passing the last id of one page as afterID gives the next,
+// which, unlike an offset, stays cheap however deep the page is. limit is
+// clamped the same way as List; deleted users are left out.
+func (m *UserModel) ListAfter(ctx context.Context, afterID, limit int) ([]*User, error) {
+	return m.ListFiltered(ctx, UserFilter{AfterID: afterID}, limit, 0, "id")
+}
+
+// likeEscaper escapes the LIKE wildcards, and the escape character itself,
+// so they match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search returns a page of users whose username or email contains term,
+// ignoring case, ordered by username. Wildcards in term match literally.
+// limit and offset are clamped the same way as List; deleted users are left
+// out.
+func (m *UserModel) Search(ctx context.Context, term string, limit, offset int) ([]*User, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(term)) + "%"
+	query := `SELECT ` + userColumns + ` FROM users
+	WHERE (LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\') AND ` + notDeleted + `
+	ORDER BY username, id
+	LIMIT ? OFFSET ?`
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.search", query)
+	rows, err := m.DB.QueryContext(ctx, m.rebind(query), pattern, pattern, limit, offset)
+	err = done(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return users, nil
+}
+
+// Count returns the number of users that aren't deleted
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+	return m.CountFiltered(ctx, UserFilter{})
+}
+
+// CountFiltered returns the number of users matching filter
+func (m *UserModel) CountFiltered(ctx context.Context, filter UserFilter) (int, error) {
+	where, args := filter.where(m.Driver)
+
+	query := "SELECT COUNT(*) FROM users" + where
+
+	var count int
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.count", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), args...).Scan(&count)
+	err = done(err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// Exists reports whether a user with the given id exists and isn't deleted
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	query := "SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND " + notDeleted + ")"
+
+	var exists bool
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.exists", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), id).Scan(&exists)
+	err = done(err)
+	if err != nil {
+		return false, fmt.Errorf("failed to check user %d exists: %w", id, err)
+	}
+	return exists, nil
+}
+
+// Authenticate checks the password for the user with the given email and
+// returns the user's id, or ErrInvalidCredentials if either doesn't match.
+// Deleted users can't log in.
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	var id int
+	var passwordHash sql.NullString
+
+	query := `SELECT id, password_hash FROM users WHERE email = ? AND ` + notDeleted
+
+	ctx, done := startQuery(ctx, m.Observer, m.Driver, "users.authenticate", query)
+	err := m.DB.QueryRowContext(ctx, m.rebind(query), normalizeEmail(email)).Scan(&id, &passwordHash)
+	err = done(err)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, fmt.Errorf("failed to look up credentials: %w", err)
+	}
+
+	if !passwordHash.Valid {
+		return 0, ErrInvalidCredentials
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(passwordHash.String), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, fmt.Errorf("failed to compare password: %w", err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,408 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// InMemoryUserModel is a UserModelInterface kept in a map, for tests and
+// demos that don't want a database. It follows UserModel's rules: emails are
+// normalized, usernames and emails are unique across all users including
+// deleted ones, deletes are soft, and the same sentinel errors are returned.
+// Timestamps are truncated to whole seconds in UTC, as SQLite stores them.
+// The zero value is ready to use.
+type InMemoryUserModel struct {
+	mu     sync.Mutex
+	users  map[int]*memoryUser
+	lastID int
+}
+
+// memoryUser is a stored user with its password hash, which User doesn't carry
+type memoryUser struct {
+	User
+	passwordHash []byte
+}
+
+var _ UserModelInterface = (*InMemoryUserModel)(nil)
+
+// NewInMemoryUserModel returns an empty InMemoryUserModel
+func NewInMemoryUserModel() *InMemoryUserModel {
+	return &InMemoryUserModel{}
+}
+
+// memoryNow returns the current time at the resolution of a SQLite timestamp
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Insert creates a new user with the next id. It returns ErrDuplicateEmail or
+// ErrDuplicateUsername if either value is already taken.
+func (m *InMemoryUserModel) Insert(ctx context.Context, user *User) error {
+	return m.insert(user, false)
+}
+
+// InsertAll inserts users in order and, if one fails, removes those already
+// inserted, as rolling back UserModel.InsertAll's transaction would. The
+// ids they took are not reused.
+func (m *InMemoryUserModel) InsertAll(ctx context.Context, users []*User) (int, error) {
+	for i, user := range users {
+		if err := m.insert(user, false); err != nil {
+			m.mu.Lock()
+			for _, inserted := range users[:i] {
+				delete(m.users, inserted.UserID)
+			}
+			m.mu.Unlock()
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// InsertWithTimestamps is Insert keeping a non-zero user.CreatedAt, and
+// UpdatedAt if set, as UserModel.InsertWithTimestamps does
+func (m *InMemoryUserModel) InsertWithTimestamps(ctx context.Context, user *User) error {
+	if user.CreatedAt.IsZero() {
+		return m.insert(user, false)
+	}
+	if err := validateTimestamps(user.CreatedAt, user.UpdatedAt); err != nil {
+		return err
+	}
+	return m.insert(user, true)
+}
+
+func (m *InMemoryUserModel) insert(user *User, keepTimestamps bool) error {
+	user.Email = normalizeEmail(user.Email)
+
+	var passwordHash []byte
+	if user.Password != "" {
+		// Nothing is persisted, so the cheapest cost keeps tests fast
+		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.MinCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		passwordHash = hash
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkUnique(0, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	if keepTimestamps {
+		user.CreatedAt = user.CreatedAt.UTC().Truncate(time.Second)
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = user.CreatedAt
+		}
+		user.UpdatedAt = user.UpdatedAt.UTC().Truncate(time.Second)
+	} else {
+		user.CreatedAt = memoryNow()
+		user.UpdatedAt = user.CreatedAt
+	}
+
+	if m.users == nil {
+		m.users = make(map[int]*memoryUser)
+	}
+	m.lastID++
+	user.UserID = m.lastID
+	user.DeletedAt = nil
+
+	stored := &memoryUser{User: *user, passwordHash: passwordHash}
+	stored.Password = ""
+	m.users[user.UserID] = stored
+	return nil
+}
+
+// checkUnique returns ErrDuplicateEmail or ErrDuplicateUsername if a user
+// other than id already has email or username. The caller holds m.mu.
+func (m *InMemoryUserModel) checkUnique(id int, username, email string) error {
+	for _, u := range m.users {
+		if u.UserID == id {
+			continue
+		}
+		if u.Email == email {
+			return ErrDuplicateEmail
+		}
+		if u.Username == username {
+			return ErrDuplicateUsername
+		}
+	}
+	return nil
+}
+
+// find returns a copy of the first user matching match, or ErrNoRecord
+func (m *InMemoryUserModel) find(match func(u *memoryUser) bool) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if match(u) {
+			user := u.copy()
+			return user, nil
+		}
+	}
+	return nil, ErrNoRecord
+}
+
+// copy returns the user as callers see it, sharing nothing with the store
+func (u *memoryUser) copy() *User {
+	user := u.User
+	if u.DeletedAt != nil {
+		deletedAt := *u.DeletedAt
+		user.DeletedAt = &deletedAt
+	}
+	return &user
+}
+
+// GetByID returns the user with the given id, or ErrNoRecord if none exists
+// or it has been deleted
+func (m *InMemoryUserModel) GetByID(ctx context.Context, id int) (*User, error) {
+	return m.find(func(u *memoryUser) bool { return u.UserID == id && u.DeletedAt == nil })
+}
+
+// GetByIDIncludeDeleted is GetByID that also returns soft-deleted users
+func (m *InMemoryUserModel) GetByIDIncludeDeleted(ctx context.Context, id int) (*User, error) {
+	return m.find(func(u *memoryUser) bool { return u.UserID == id })
+}
+
+// GetByEmail returns the user with the given email, compared after
+// normalizing, or ErrNoRecord if none exists
+func (m *InMemoryUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	email = normalizeEmail(email)
+	return m.find(func(u *memoryUser) bool { return u.Email == email && u.DeletedAt == nil })
+}
+
+// GetByUsername returns the user with the given username, or ErrNoRecord if
+// none exists
+func (m *InMemoryUserModel) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return m.find(func(u *memoryUser) bool { return u.Username == username && u.DeletedAt == nil })
+}
+
+// Update changes the username and email of the user identified by
+// user.UserID, with the same errors as UserModel.Update
+func (m *InMemoryUserModel) Update(ctx context.Context, user *User) error {
+	user.Email = normalizeEmail(user.Email)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[user.UserID]
+	if !ok || stored.DeletedAt != nil {
+		return ErrNoRecord
+	}
+	if err := m.checkUnique(user.UserID, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	stored.Username = user.Username
+	stored.Email = user.Email
+	stored.UpdatedAt = memoryNow()
+	user.CreatedAt = stored.CreatedAt
+	user.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+// Delete soft-deletes the user with the given id. It returns ErrNoRecord if
+// no such user exists or it is already deleted.
+func (m *InMemoryUserModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[id]
+	if !ok || stored.DeletedAt != nil {
+		return ErrNoRecord
+	}
+	now := memoryNow()
+	stored.DeletedAt = &now
+	stored.UpdatedAt = now
+	return nil
+}
+
+// Restore undoes Delete. It returns ErrNoRecord if no such user exists or it
+// isn't deleted.
+func (m *InMemoryUserModel) Restore(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[id]
+	if !ok || stored.DeletedAt == nil {
+		return ErrNoRecord
+	}
+	stored.DeletedAt = nil
+	stored.UpdatedAt = memoryNow()
+	return nil
+}
+
+// List returns a page of users that aren't deleted, ordered and bounded as
+// UserModel.List
+func (m *InMemoryUserModel) List(ctx context.Context, limit, offset int, sort string) ([]*User, error) {
+	return m.ListFiltered(ctx, UserFilter{}, limit, offset, sort)
+}
+
+// ListIncludeDeleted is List that also returns soft-deleted users
+func (m *InMemoryUserModel) ListIncludeDeleted(ctx context.Context, limit, offset int, sort string) ([]*User, error) {
+	return m.ListFiltered(ctx, UserFilter{IncludeDeleted: true}, limit, offset, sort)
+}
+
+// ListAfter returns up to limit users with an id greater than afterID,
+// ordered by id
+func (m *InMemoryUserModel) ListAfter(ctx context.Context, afterID, limit int) ([]*User, error) {
+	return m.ListFiltered(ctx, UserFilter{AfterID: afterID}, limit, 0, "id")
+}
+
+// ListFiltered is List restricted to the users matching filter
+func (m *InMemoryUserModel) ListFiltered(ctx context.Context, filter UserFilter, limit, offset int, sort string) ([]*User, error) {
+	compare, err := memoryUserOrder(sort)
+	if err != nil {
+		return nil, err
+	}
+	users := m.matching(func(u *memoryUser) bool { return filter.matches(&u.User) })
+	slices.SortFunc(users, compare)
+	return page(users, limit, offset), nil
+}
+
+// Search returns a page of users that aren't deleted whose username or email
+// contains term, ignoring case, ordered by username
+func (m *InMemoryUserModel) Search(ctx context.Context, term string, limit, offset int) ([]*User, error) {
+	term = strings.ToLower(term)
+	users := m.matching(func(u *memoryUser) bool {
+		return u.DeletedAt == nil &&
+			(strings.Contains(strings.ToLower(u.Username), term) || strings.Contains(strings.ToLower(u.Email), term))
+	})
+	slices.SortFunc(users, func(a, b *User) int {
+		return cmp.Or(strings.Compare(a.Username, b.Username), cmp.Compare(a.UserID, b.UserID))
+	})
+	return page(users, limit, offset), nil
+}
+
+// Count returns the number of users that aren't deleted
+func (m *InMemoryUserModel) Count(ctx context.Context) (int, error) {
+	return m.CountFiltered(ctx, UserFilter{})
+}
+
+// CountFiltered returns the number of users matching filter
+func (m *InMemoryUserModel) CountFiltered(ctx context.Context, filter UserFilter) (int, error) {
+	return len(m.matching(func(u *memoryUser) bool { return filter.matches(&u.User) })), nil
+}
+
+// Exists reports whether a user with the given id exists and isn't deleted
+func (m *InMemoryUserModel) Exists(ctx context.Context, id int) (bool, error) {
+	_, err := m.GetByID(ctx, id)
+	if errors.Is(err, ErrNoRecord) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Authenticate checks the password for the user with the given email and
+// returns the user's id, or ErrInvalidCredentials if either doesn't match
+func (m *InMemoryUserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	email = normalizeEmail(email)
+
+	m.mu.Lock()
+	var id int
+	var hash []byte
+	for _, u := range m.users {
+		if u.Email == email && u.DeletedAt == nil {
+			id, hash = u.UserID, u.passwordHash
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if hash == nil {
+		return 0, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, fmt.Errorf("failed to compare password: %w", err)
+	}
+	return id, nil
+}
+
+// matching returns copies of the users for which match is true
+func (m *InMemoryUserModel) matching(match func(u *memoryUser) bool) []*User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := []*User{}
+	for _, u := range m.users {
+		if match(u) {
+			users = append(users, u.copy())
+		}
+	}
+	return users
+}
+
+// matches reports whether user is selected by f, as f.where does in SQL
+func (f UserFilter) matches(user *User) bool {
+	switch {
+	case !f.IncludeDeleted && user.DeletedAt != nil:
+		return false
+	case !f.CreatedFrom.IsZero() && user.CreatedAt.Before(f.CreatedFrom.Truncate(time.Second)):
+		return false
+	case !f.CreatedTo.IsZero() && user.CreatedAt.After(f.CreatedTo.Truncate(time.Second)):
+		return false
+	case f.AfterID > 0 && user.UserID <= f.AfterID:
+		return false
+	}
+	return true
+}
+
+// memoryUserOrder returns the comparison for a List sort key, accepting the
+// same keys as orderByClause and breaking ties on id
+func memoryUserOrder(sort string) (func(a, b *User) int, error) {
+	column, desc := strings.CutPrefix(sort, "-")
+	if sort == "" {
+		column = "id"
+	}
+	if !slices.Contains(UserSortColumns, column) {
+		return nil, fmt.Errorf("unsupported sort column %q", column)
+	}
+
+	return func(a, b *User) int {
+		var c int
+		switch column {
+		case "username":
+			c = strings.Compare(a.Username, b.Username)
+		case "email":
+			c = strings.Compare(a.Email, b.Email)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		c = cmp.Or(c, cmp.Compare(a.UserID, b.UserID))
+		if desc {
+			return -c
+		}
+		return c
+	}, nil
+}
+
+// page applies List's limit and offset rules to items, e.g. users or orders
+func page[T any](items []T, limit, offset int) []T {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset >= len(items) {
+		return []T{}
+	}
+	return items[offset:min(offset+limit, len(items))]
+}
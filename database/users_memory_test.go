@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// forEachUserModel runs test against the SQLite UserModel and the
+// InMemoryUserModel, so both are held to the same behaviour
+func forEachUserModel(t *testing.T, test func(t *testing.T, m UserModelInterface)) {
+	t.Run("sqlite", func(t *testing.T) { test(t, newTestStore(t).User) })
+	t.Run("memory", func(t *testing.T) { test(t, NewInMemoryUserModel()) })
+}
+
+func TestUserModelParityInsertAndGet(t *testing.T) {
+	forEachUserModel(t, func(t *testing.T, m UserModelInterface) {
+		ctx := context.Background()
+
+		user := &User{Username: "alice", Email: " Alice@Example.com "}
+		if err := m.Insert(ctx, user); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if user.UserID == 0 || user.CreatedAt.IsZero() || user.Email != "alice@example.com" {
+			t.Errorf("inserted user = %+v, want an id, CreatedAt and the normalized email", user)
+		}
+		second := &User{Username: "bob", Email: "bob@example.com"}
+		if err := m.Insert(ctx, second); err != nil {
+			t.Fatal(err)
+		}
+		if second.UserID <= user.UserID {
+			t.Errorf("second id = %d, want it after %d", second.UserID, user.UserID)
+		}
+
+		byID, err := m.GetByID(ctx, user.UserID)
+		if err != nil || byID.Username != "alice" {
+			t.Errorf("GetByID = %+v, %v, want alice", byID, err)
+		}
+		byEmail, err := m.GetByEmail(ctx, "ALICE@example.com")
+		if err != nil || byEmail.UserID != user.UserID {
+			t.Errorf("GetByEmail = %+v, %v, want alice", byEmail, err)
+		}
+		if _, err := m.GetByID(ctx, 42); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("GetByID of missing user = %v, want ErrNoRecord", err)
+		}
+		if _, err := m.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("GetByEmail of missing user = %v, want ErrNoRecord", err)
+		}
+	})
+}
+
+func TestUserModelParityDuplicates(t *testing.T) {
+	forEachUserModel(t, func(t *testing.T, m UserModelInterface) {
+		ctx := context.Background()
+		alice := &User{Username: "alice", Email: "alice@example.com"}
+		bob := &User{Username: "bob", Email: "bob@example.com"}
+		for _, user := range []*User{alice, bob} {
+			if err := m.Insert(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := m.Insert(ctx, &User{Username: "carol", Email: "ALICE@example.com"}); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Insert with a taken email = %v, want ErrDuplicateEmail", err)
+		}
+		if err := m.Insert(ctx, &User{Username: "alice", Email: "carol@example.com"}); !errors.Is(err, ErrDuplicateUsername) {
+			t.Errorf("Insert with a taken username = %v, want ErrDuplicateUsername", err)
+		}
+		bob.Email = "alice@example.com"
+		if err := m.Update(ctx, bob); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Update to a taken email = %v, want ErrDuplicateEmail", err)
+		}
+
+		// A deleted user's email stays taken until it is purged
+		if err := m.Delete(ctx, alice.UserID); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Insert(ctx, &User{Username: "carol", Email: "alice@example.com"}); !errors.Is(err, ErrDuplicateEmail) {
+			t.Errorf("Insert with a deleted user's email = %v, want ErrDuplicateEmail", err)
+		}
+		if count, err := m.Count(ctx); err != nil || count != 1 {
+			t.Errorf("Count = %d, %v, want 1", count, err)
+		}
+	})
+}
+
+func TestUserModelParityListing(t *testing.T) {
+	forEachUserModel(t, func(t *testing.T, m UserModelInterface) {
+		ctx := context.Background()
+		for _, name := range []string{"carol", "alice", "bob_1", "bobby"} {
+			if err := m.Insert(ctx, &User{Username: name, Email: name + "@example.com"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sorted, err := m.List(ctx, 10, 0, "-username")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := usernames(sorted); !slices.Equal(got, []string{"carol", "bobby", "bob_1", "alice"}) {
+			t.Errorf("List by -username = %v", got)
+		}
+		if _, err := m.List(ctx, 10, 0, "password"); err == nil {
+			t.Error("List by an unknown column succeeded, want an error")
+		}
+
+		found, err := m.Search(ctx, "B_", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := usernames(found); !slices.Equal(got, []string{"bob_1"}) {
+			t.Errorf("Search(B_) = %v, want the literal underscore matched", got)
+		}
+
+		after, err := m.ListAfter(ctx, sorted[3].UserID, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := usernames(after); !slices.Equal(got, []string{"bob_1", "bobby"}) {
+			t.Errorf("ListAfter alice = %v, want the next two by id", got)
+		}
+	})
+}
+
+func TestUserModelParityAuthenticate(t *testing.T) {
+	forEachUserModel(t, func(t *testing.T, m UserModelInterface) {
+		ctx := context.Background()
+		user := &User{Username: "alice", Email: "alice@example.com", Password: "secret-password"}
+		if err := m.Insert(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+
+		if id, err := m.Authenticate(ctx, "Alice@example.com", "secret-password"); err != nil || id != user.UserID {
+			t.Errorf("Authenticate = %d, %v, want %d", id, err, user.UserID)
+		}
+		if _, err := m.Authenticate(ctx, "alice@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate with a wrong password = %v, want ErrInvalidCredentials", err)
+		}
+		if _, err := m.Authenticate(ctx, "nobody@example.com", "secret-password"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate of an unknown email = %v, want ErrInvalidCredentials", err)
+		}
+	})
+}
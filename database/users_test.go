@@ -0,0 +1,610 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestInsertRejectsCaseVariantEmail(t *testing.T) {
+	ctx := context.Background()
+	users := newTestStore(t).User
+
+	if err := users.Insert(ctx, &User{Username: "john", Email: "John@Example.com"}); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	err := users.Insert(ctx, &User{Username: "john2", Email: " john@EXAMPLE.com "})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("Insert of case variant = %v, want ErrDuplicateEmail", err)
+	}
+}
+
+func TestInsertDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	insertTestUser(t, s, "alice")
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		want     error
+	}{
+		{"email", "bob", "alice@example.com", ErrDuplicateEmail},
+		{"username", "alice", "bob@example.com", ErrDuplicateUsername},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.User.Insert(ctx, &User{Username: tt.username, Email: tt.email})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Insert = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateUserErrorIgnoresOtherErrors(t *testing.T) {
+	if err := duplicateUserError(errors.New("boom")); err != nil {
+		t.Errorf("duplicateUserError of a plain error = %v, want nil", err)
+	}
+
+	dm := newTestManager(t)
+	_, err := dm.DB.Exec("INSERT INTO orders (user_id, symbol, side, type, quantity) VALUES (1, 'AAPL', 'buy', 'market', -1)")
+	if err == nil {
+		t.Fatal("insert succeeded, want a CHECK violation")
+	}
+	if got := duplicateUserError(err); got != nil {
+		t.Errorf("duplicateUserError of a CHECK violation = %v, want nil", got)
+	}
+}
+
+func TestGetByEmailIgnoresCase(t *testing.T) {
+	ctx := context.Background()
+	users := newTestStore(t).User
+
+	user := &User{Username: "john", Email: "JOHN@example.com"}
+	if err := users.Insert(ctx, user); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if user.Email != "john@example.com" {
+		t.Errorf("stored email = %q, want it lowercased", user.Email)
+	}
+
+	got, err := users.GetByEmail(ctx, "John@Example.COM")
+	if err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if got.UserID != user.UserID {
+		t.Errorf("GetByEmail returned user %d, want %d", got.UserID, user.UserID)
+	}
+}
+
+func TestGetByEmailMissing(t *testing.T) {
+	_, err := newTestStore(t).User.GetByEmail(context.Background(), "nobody@example.com")
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByEmail of missing user = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestInsertReturnsGeneratedFields(t *testing.T) {
+	user := &User{Username: "john", Email: "john@example.com"}
+	if err := newTestStore(t).User.Insert(context.Background(), user); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	if user.UserID == 0 {
+		t.Error("UserID is zero after Insert")
+	}
+	if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+		t.Errorf("timestamps not populated: created %v, updated %v", user.CreatedAt, user.UpdatedAt)
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	want := insertTestUser(t, s, "john")
+
+	got, err := s.User.GetByID(ctx, want.UserID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.UserID != want.UserID || got.Username != want.Username || got.Email != want.Email {
+		t.Errorf("GetByID = %+v, want %+v", got, want)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, want.CreatedAt)
+	}
+}
+
+func TestGetByIDMissing(t *testing.T) {
+	_, err := newTestStore(t).User.GetByID(context.Background(), 42)
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByID of missing user = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestGetByIDScanError(t *testing.T) {
+	dm := newTestManager(t)
+	s := NewSQLStore(dm, zap.NewNop())
+	user := insertTestUser(t, s, "john")
+
+	if _, err := dm.DB.Exec("UPDATE users SET created_at = X'00' WHERE id = ?", user.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.User.GetByID(context.Background(), user.UserID)
+	if err == nil || errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByID of unscannable row = %v, want a scan error", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "john")
+
+	user.Username = "johnny"
+	user.Email = "Johnny@Example.com"
+	if err := s.User.Update(ctx, user); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err := s.User.GetByID(ctx, user.UserID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Username != "johnny" || got.Email != "johnny@example.com" {
+		t.Errorf("updated user = %+v, want johnny <johnny@example.com>", got)
+	}
+}
+
+func TestUpdateMissing(t *testing.T) {
+	err := newTestStore(t).User.Update(context.Background(), &User{UserID: 42, Username: "john", Email: "john@example.com"})
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("Update of missing user = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestUpdateConflicts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	insertTestUser(t, s, "alice")
+	bob := insertTestUser(t, s, "bob")
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		want     error
+	}{
+		{"email", "bob", "ALICE@example.com", ErrDuplicateEmail},
+		{"username", "alice", "bob@example.com", ErrDuplicateUsername},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := &User{UserID: bob.UserID, Username: tt.username, Email: tt.email}
+			if err := s.User.Update(ctx, update); !errors.Is(err, tt.want) {
+				t.Errorf("Update = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "john")
+
+	if err := s.User.Delete(ctx, user.UserID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.User.GetByID(ctx, user.UserID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByID after Delete = %v, want ErrNoRecord", err)
+	}
+	if err := s.User.Delete(ctx, user.UserID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("second Delete = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	if err := newTestStore(t).User.Delete(context.Background(), 42); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("Delete of missing user = %v, want ErrNoRecord", err)
+	}
+}
+
+// insertTestUsers stores n users named user1 to userN in s, in that order
+func insertTestUsers(t *testing.T, s *SQLStore, n int) []*User {
+	t.Helper()
+
+	users := make([]*User, n)
+	for i := range users {
+		users[i] = insertTestUser(t, s, fmt.Sprintf("user%d", i+1))
+	}
+	return users
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	users, err := s.User.List(ctx, 10, 0, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if users == nil || len(users) != 0 {
+		t.Errorf("List of no users = %v, want an empty slice", users)
+	}
+
+	insertTestUsers(t, s, 5)
+	tests := []struct {
+		name          string
+		limit, offset int
+		want          []string
+	}{
+		{"first page", 2, 0, []string{"user1", "user2"}},
+		{"partial last page", 2, 4, []string{"user5"}},
+		{"past the end", 2, 10, []string{}},
+		{"default limit", 0, 0, []string{"user1", "user2", "user3", "user4", "user5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := s.User.List(ctx, tt.limit, tt.offset, "")
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if got := usernames(users); !slices.Equal(got, tt.want) {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	count, err := s.User.Count(ctx)
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Count = %d, want 5", count)
+	}
+}
+
+func TestListCapsLimit(t *testing.T) {
+	s := newTestStore(t)
+	insertTestUsers(t, s, MaxListLimit+1)
+
+	users, err := s.User.List(context.Background(), MaxListLimit+1, 0, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(users) != MaxListLimit {
+		t.Errorf("List returned %d users, want the cap of %d", len(users), MaxListLimit)
+	}
+}
+
+// usernames returns the usernames of users, in order
+func usernames(users []*User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+func TestExists(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := insertTestUser(t, s, "john")
+
+	tests := []struct {
+		name string
+		id   int
+		want bool
+	}{
+		{"existing", user.UserID, true},
+		{"missing", user.UserID + 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := s.User.Exists(ctx, tt.id)
+			if err != nil {
+				t.Fatalf("Exists: %v", err)
+			}
+			if exists != tt.want {
+				t.Errorf("Exists(%d) = %v, want %v", tt.id, exists, tt.want)
+			}
+		})
+	}
+}
+
+func TestExistsQueryError(t *testing.T) {
+	dm := newTestManager(t)
+	users := NewSQLStore(dm, zap.NewNop()).User
+	dm.Close()
+
+	if _, err := users.Exists(context.Background(), 1); err == nil {
+		t.Error("Exists on a closed database succeeded")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	user := &User{Username: "john", Email: "john@example.com", Password: "correct horse"}
+	if err := s.User.Insert(ctx, user); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	insertTestUser(t, s, "nopassword")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{"correct password", "John@Example.com", "correct horse", user.UserID, nil},
+		{"wrong password", "john@example.com", "wrong horse", 0, ErrInvalidCredentials},
+		{"unknown email", "jane@example.com", "correct horse", 0, ErrInvalidCredentials},
+		{"no password set", "nopassword@example.com", "", 0, ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := s.User.Authenticate(ctx, tt.email, tt.password)
+			if id != tt.wantID || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Authenticate = %d, %v, want %d, %v", id, err, tt.wantID, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFiltersAreBoundAsParameters(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	insertTestUser(t, s, "alice")
+
+	const injection = "x' OR '1'='1"
+	if _, err := s.User.GetByUsername(ctx, injection); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByUsername(%q) = %v, want ErrNoRecord", injection, err)
+	}
+	users, err := s.User.Search(ctx, injection, 10, 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("Search(%q) = %v, want no users", injection, usernames(users))
+	}
+	if count, err := s.User.Count(ctx); err != nil || count != 1 {
+		t.Errorf("Count = %d, %v, want the user kept", count, err)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	users := insertTestUsers(t, s, 2)
+	deleted := users[0]
+
+	if err := s.User.Delete(ctx, deleted.UserID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := s.User.GetByEmail(ctx, deleted.Email); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("GetByEmail of deleted user = %v, want ErrNoRecord", err)
+	}
+	if exists, err := s.User.Exists(ctx, deleted.UserID); err != nil || exists {
+		t.Errorf("Exists of deleted user = %v, %v, want false", exists, err)
+	}
+	if listed, err := s.User.List(ctx, 10, 0, ""); err != nil || !slices.Equal(usernames(listed), []string{"user2"}) {
+		t.Errorf("List = %v, %v, want only user2", usernames(listed), err)
+	}
+	if count, err := s.User.Count(ctx); err != nil || count != 1 {
+		t.Errorf("Count = %d, %v, want 1", count, err)
+	}
+
+	// The row is kept, with the time it was deleted
+	kept, err := s.User.GetByIDIncludeDeleted(ctx, deleted.UserID)
+	if err != nil || kept.DeletedAt == nil {
+		t.Fatalf("GetByIDIncludeDeleted = %+v, %v, want the user with DeletedAt set", kept, err)
+	}
+	if all, err := s.User.ListIncludeDeleted(ctx, 10, 0, ""); err != nil || len(all) != 2 {
+		t.Errorf("ListIncludeDeleted = %v, %v, want both users", usernames(all), err)
+	}
+
+	if err := s.User.Restore(ctx, deleted.UserID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	restored, err := s.User.GetByID(ctx, deleted.UserID)
+	if err != nil || restored.DeletedAt != nil {
+		t.Errorf("GetByID after Restore = %+v, %v, want the user back", restored, err)
+	}
+	if err := s.User.Restore(ctx, deleted.UserID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("Restore of a user that isn't deleted = %v, want ErrNoRecord", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	for _, name := range []string{"johnny", "bjohn", "100%_club", "1000club", "alice"} {
+		insertTestUser(t, s, name)
+	}
+	deleted := insertTestUser(t, s, "johnold")
+	if err := s.User.Delete(ctx, deleted.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"john", []string{"bjohn", "johnny"}},
+		{"JOHN", []string{"bjohn", "johnny"}},
+		{"ohn", []string{"bjohn", "johnny"}},
+		{"0%", []string{"100%_club"}},
+		{"_", []string{"100%_club"}},
+		{"@example.com", []string{"100%_club", "1000club", "alice", "bjohn", "johnny"}},
+		{"nobody", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			users, err := s.User.Search(ctx, tt.term, 10, 0)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if got := usernames(users); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+// insertUsersCreated stores a user created at each of times, named by the
+// time's month, e.g. "jan"
+func insertUsersCreated(t *testing.T, s *SQLStore, times ...time.Time) {
+	t.Helper()
+
+	for _, created := range times {
+		name := strings.ToLower(created.Month().String()[:3])
+		user := &User{Username: name, Email: name + "@example.com", CreatedAt: created}
+		if err := s.User.InsertWithTimestamps(context.Background(), user); err != nil {
+			t.Fatalf("InsertWithTimestamps %s: %v", name, err)
+		}
+	}
+}
+
+func TestListFilteredByCreatedRange(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 15, 12, 0, 0, 0, time.UTC) }
+	insertUsersCreated(t, s, month(time.January), month(time.February), month(time.March), month(time.April))
+
+	tests := []struct {
+		name   string
+		filter UserFilter
+		want   []string
+	}{
+		{"unbounded", UserFilter{}, []string{"jan", "feb", "mar", "apr"}},
+		{"bounded", UserFilter{CreatedFrom: month(time.February), CreatedTo: month(time.March)}, []string{"feb", "mar"}},
+		{"from only", UserFilter{CreatedFrom: month(time.March).Add(time.Second)}, []string{"apr"}},
+		{"to only", UserFilter{CreatedTo: month(time.February).Add(-time.Second)}, []string{"jan"}},
+		{"other timezone", UserFilter{CreatedFrom: month(time.April).In(time.FixedZone("UTC+2", 2*60*60))}, []string{"apr"}},
+		{"empty", UserFilter{CreatedFrom: month(time.May)}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := s.User.ListFiltered(ctx, tt.filter, 10, 0, "")
+			if err != nil {
+				t.Fatalf("ListFiltered: %v", err)
+			}
+			if got := usernames(users); !slices.Equal(got, tt.want) {
+				t.Errorf("ListFiltered = %v, want %v", got, tt.want)
+			}
+			if count, err := s.User.CountFiltered(ctx, tt.filter); err != nil || count != len(tt.want) {
+				t.Errorf("CountFiltered = %d, %v, want %d", count, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestInsertWithTimestamps(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	created := time.Date(2019, 6, 1, 8, 30, 15, 0, time.FixedZone("UTC+2", 2*60*60))
+	updated := created.Add(48 * time.Hour)
+	user := &User{Username: "old", Email: "old@example.com", CreatedAt: created, UpdatedAt: updated}
+	if err := s.User.InsertWithTimestamps(ctx, user); err != nil {
+		t.Fatalf("InsertWithTimestamps: %v", err)
+	}
+
+	got, err := s.User.GetByID(ctx, user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(updated) {
+		t.Errorf("timestamps = %v, %v, want %v, %v", got.CreatedAt, got.UpdatedAt, created, updated)
+	}
+	if got.CreatedAt.Location() != time.UTC {
+		t.Errorf("CreatedAt location = %v, want UTC", got.CreatedAt.Location())
+	}
+
+	// Without UpdatedAt it defaults to CreatedAt
+	user = &User{Username: "older", Email: "older@example.com", CreatedAt: created}
+	if err := s.User.InsertWithTimestamps(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.User.GetByID(ctx, user.UserID); err != nil || !got.UpdatedAt.Equal(created) {
+		t.Errorf("UpdatedAt = %v, %v, want CreatedAt", got.UpdatedAt, err)
+	}
+
+	// The normal path still uses the current time
+	before := time.Now().Add(-time.Second).Truncate(time.Second)
+	user = &User{Username: "new", Email: "new@example.com"}
+	if err := s.User.InsertWithTimestamps(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.CreatedAt.Before(before) {
+		t.Errorf("CreatedAt = %v, want the current time", user.CreatedAt)
+	}
+}
+
+func TestInsertWithTimestampsRejectsInvalid(t *testing.T) {
+	s := newTestStore(t)
+	past := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		created, updated time.Time
+	}{
+		{"future", time.Now().Add(time.Hour), time.Time{}},
+		{"before the epoch", time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), time.Time{}},
+		{"updated before created", past, past.Add(-time.Hour)},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := fmt.Sprintf("user%d", i)
+			user := &User{Username: name, Email: name + "@example.com", CreatedAt: tt.created, UpdatedAt: tt.updated}
+			if err := s.User.InsertWithTimestamps(context.Background(), user); !errors.Is(err, ErrInvalidTimestamp) {
+				t.Errorf("InsertWithTimestamps = %v, want ErrInvalidTimestamp", err)
+			}
+		})
+	}
+}
+
+func TestListAfterWalksAllPages(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	users := insertTestUsers(t, s, 7)
+	if err := s.User.Delete(ctx, users[3].UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	afterID := 0
+	for pages := 0; ; pages++ {
+		if pages > len(users) {
+			t.Fatal("ListAfter never reached the end")
+		}
+		page, err := s.User.ListAfter(ctx, afterID, 2)
+		if err != nil {
+			t.Fatalf("ListAfter(%d): %v", afterID, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		seen = append(seen, usernames(page)...)
+		afterID = page[len(page)-1].UserID
+	}
+
+	want := []string{"user1", "user2", "user3", "user5", "user6", "user7"}
+	if !slices.Equal(seen, want) {
+		t.Errorf("pages = %v, want %v with none skipped or repeated", seen, want)
+	}
+}
@@ -0,0 +1,309 @@
+// Package engine matches orders in memory for the demo exchange mode.
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// ErrNotResting is returned by Cancel for an order that isn't in a book,
+// e.g. because it already filled
+var ErrNotResting = errors.New("engine: order is not resting in a book")
+
+// PricePublisher is notified of the price of each trade once its fill is
+// committed, e.g. to stream it to clients. PublishPrice must not block.
+type PricePublisher interface {
+	PublishPrice(symbol string, price float64)
+}
+
+// quantityEpsilon treats float residue left by partial fills as nothing
+const quantityEpsilon = 1e-9
+
+// restingOrder is an order waiting in a book with remaining quantity
+type restingOrder struct {
+	order     *db.Order
+	remaining float64
+}
+
+// orderBook holds one symbol's resting orders in price-time priority: the
+// best price first, and orders at the same price oldest first
+type orderBook struct {
+	bids []*restingOrder // highest price first
+	asks []*restingOrder // lowest price first
+}
+
+// MatchingEngine keeps a bid/ask book per symbol and matches incoming orders
+// against the opposite side. Fills are persisted through the order, trade
+// and position models before the books change, so the books never run ahead
+// of the database. Books start empty: orders left open by a previous run
+// don't rest. An order never fills against one of its own user's orders.
+type MatchingEngine struct {
+	mu      sync.Mutex
+	books   map[string]*orderBook
+	resting map[int]*restingOrder
+
+	db        *sql.DB
+	orders    *db.OrderModel
+	trades    *db.TradeModel
+	positions *db.PositionModel
+	// prices, if set, receives the price of each trade
+	prices PricePublisher
+	logger *zap.Logger
+}
+
+// New returns an engine with empty books that records fills in database and
+// applies them to both users' positions
+func New(database *sql.DB, orders *db.OrderModel, trades *db.TradeModel, positions *db.PositionModel, logger *zap.Logger) *MatchingEngine {
+	return &MatchingEngine{
+		books:     make(map[string]*orderBook),
+		resting:   make(map[int]*restingOrder),
+		db:        database,
+		orders:    orders,
+		trades:    trades,
+		positions: positions,
+		logger:    logger,
+	}
+}
+
+// SetPricePublisher makes the engine publish each trade's price to p. It
+// must be called before the engine is used concurrently.
+func (e *MatchingEngine) SetPricePublisher(p PricePublisher) {
+	e.prices = p
+}
+
+// book returns the book for symbol, creating it on first use
+func (e *MatchingEngine) book(symbol string) *orderBook {
+	b, ok := e.books[symbol]
+	if !ok {
+		b = &orderBook{}
+		e.books[symbol] = b
+	}
+	return b
+}
+
+// Submit matches a stored open order against the book and returns the trades
+// it produced, two per fill (one for each order). A limit order's unfilled
+// remainder rests in the book; a market order's remainder is cancelled.
+// Matching stops at the first resting order of the same user, and the
+// remainder is cancelled, so users never trade with themselves.
+// order is updated to its final filled quantity and status. If persisting a
+// fill fails, the trades recorded so far are returned with the error.
+func (e *MatchingEngine) Submit(ctx context.Context, order *db.Order) ([]db.Trade, error) {
+	if order.OrderID == 0 || order.Status != db.OrderStatusOpen {
+		return nil, fmt.Errorf("engine: order %d must be stored and open", order.OrderID)
+	}
+	if order.Type == db.OrderTypeLimit && order.Price == nil {
+		return nil, fmt.Errorf("engine: limit order %d has no price", order.OrderID)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book := e.book(order.Symbol)
+	opposite := &book.asks
+	if order.Side == db.OrderSideSell {
+		opposite = &book.bids
+	}
+
+	var trades []db.Trade
+	selfTrade := false
+	remaining := order.Quantity - order.FilledQuantity
+	for remaining > quantityEpsilon && len(*opposite) > 0 && crosses(order, (*opposite)[0].order) {
+		best := (*opposite)[0]
+		if best.order.UserID == order.UserID {
+			// Cancelling the newer order keeps the book uncrossed, which
+			// skipping the resting one to match deeper wouldn't
+			selfTrade = true
+			break
+		}
+		qty := min(remaining, best.remaining)
+		price := *best.order.Price
+
+		fill, err := e.recordFill(ctx, order, best.order, qty, price)
+		if err != nil {
+			return trades, err
+		}
+		trades = append(trades, fill...)
+
+		remaining -= qty
+		order.FilledQuantity += qty
+		best.remaining -= qty
+		best.order.FilledQuantity += qty
+		if best.remaining <= quantityEpsilon {
+			best.order.Status = db.OrderStatusFilled
+			*opposite = (*opposite)[1:]
+			delete(e.resting, best.order.OrderID)
+		}
+	}
+
+	switch {
+	case remaining <= quantityEpsilon:
+		order.Status = db.OrderStatusFilled
+	case order.Type == db.OrderTypeMarket || selfTrade:
+		// Nothing left to match against; market orders never rest, and a
+		// limit order resting here would cross its user's own order
+		if err := e.orders.UpdateStatus(ctx, order.OrderID, db.OrderStatusCancelled); err != nil {
+			return trades, fmt.Errorf("failed to cancel unfilled market order %d: %w", order.OrderID, err)
+		}
+		order.Status = db.OrderStatusCancelled
+	default:
+		// Rest a copy so later fills don't race with the caller's use of order
+		rested := *order
+		ro := &restingOrder{order: &rested, remaining: remaining}
+		book.insert(ro)
+		e.resting[order.OrderID] = ro
+	}
+
+	e.logger.Info("Order matched",
+		zap.Int("order_id", order.OrderID),
+		zap.String("symbol", order.Symbol),
+		zap.Int("fills", len(trades)/2),
+		zap.Float64("filled_quantity", order.FilledQuantity),
+		zap.String("status", order.Status),
+		zap.Bool("self_trade_prevented", selfTrade))
+
+	return trades, nil
+}
+
+// crosses reports whether incoming may trade at resting's limit price
+func crosses(incoming, resting *db.Order) bool {
+	if incoming.Type == db.OrderTypeMarket {
+		return true
+	}
+	if incoming.Side == db.OrderSideBuy {
+		return *resting.Price <= *incoming.Price
+	}
+	return *resting.Price >= *incoming.Price
+}
+
+// insert places ro behind every order at an equal or better price
+func (b *orderBook) insert(ro *restingOrder) {
+	price := *ro.order.Price
+	side := &b.asks
+	behind := func(i int) bool { return *b.asks[i].order.Price > price }
+	if ro.order.Side == db.OrderSideBuy {
+		side = &b.bids
+		behind = func(i int) bool { return *b.bids[i].order.Price < price }
+	}
+
+	i := sort.Search(len(*side), behind)
+	*side = append(*side, nil)
+	copy((*side)[i+1:], (*side)[i:])
+	(*side)[i] = ro
+}
+
+// remove takes ro out of its side of the book
+func (b *orderBook) remove(ro *restingOrder) {
+	side := &b.asks
+	if ro.order.Side == db.OrderSideBuy {
+		side = &b.bids
+	}
+	for i, other := range *side {
+		if other == ro {
+			*side = append((*side)[:i], (*side)[i+1:]...)
+			return
+		}
+	}
+}
+
+// recordFill persists one match atomically: both orders' filled quantities,
+// a trade for each of them and both users' positions. Order subscribers and
+// the price publisher are notified once it commits.
+func (e *MatchingEngine) recordFill(ctx context.Context, incoming, resting *db.Order, qty, price float64) ([]db.Trade, error) {
+	tx, err := db.BeginTx(ctx, e.db, e.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	trades := []db.Trade{
+		{OrderID: incoming.OrderID, Symbol: incoming.Symbol, Quantity: qty, Price: price},
+		{OrderID: resting.OrderID, Symbol: resting.Symbol, Quantity: qty, Price: price},
+	}
+	for i, order := range []*db.Order{incoming, resting} {
+		if err := e.orders.FillTx(ctx, tx.Tx, order.OrderID, qty); err != nil {
+			return nil, err
+		}
+		if err := e.trades.InsertTx(ctx, tx.Tx, &trades[i]); err != nil {
+			return nil, err
+		}
+		qtyDelta := qty
+		if order.Side == db.OrderSideSell {
+			qtyDelta = -qty
+		}
+		if err := e.positions.UpsertTx(ctx, tx.Tx, order.UserID, order.Symbol, qtyDelta, price); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit fill of orders %d and %d: %w", incoming.OrderID, resting.OrderID, err)
+	}
+
+	e.orders.Publish(ctx, incoming.OrderID)
+	e.orders.Publish(ctx, resting.OrderID)
+	if e.prices != nil {
+		e.prices.PublishPrice(incoming.Symbol, price)
+	}
+	return trades, nil
+}
+
+// CancelAll cancels all of the user's open orders for symbol through
+// OrderModel.CancelOpen and takes those resting in the book out of it,
+// returning the cancelled ids. Holding the engine lock keeps fills from
+// racing the cancellation.
+func (e *MatchingEngine) CancelAll(ctx context.Context, userID int, symbol string) ([]int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ids, err := e.orders.CancelOpen(ctx, userID, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		ro, ok := e.resting[id]
+		if !ok {
+			continue
+		}
+		e.book(ro.order.Symbol).remove(ro)
+		delete(e.resting, id)
+		ro.order.Status = db.OrderStatusCancelled
+	}
+
+	e.logger.Info("Resting orders cancelled",
+		zap.Int("user_id", userID),
+		zap.String("symbol", db.NormalizeSymbol(symbol)),
+		zap.Int("cancelled", len(ids)))
+	return ids, nil
+}
+
+// Cancel removes a resting order from its book and marks it cancelled. It
+// returns ErrNotResting if the order isn't in a book.
+func (e *MatchingEngine) Cancel(ctx context.Context, orderID int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ro, ok := e.resting[orderID]
+	if !ok {
+		return ErrNotResting
+	}
+
+	if err := e.orders.UpdateStatus(ctx, orderID, db.OrderStatusCancelled); err != nil {
+		return err
+	}
+
+	e.book(ro.order.Symbol).remove(ro)
+	delete(e.resting, orderID)
+	ro.order.Status = db.OrderStatusCancelled
+
+	e.logger.Info("Resting order cancelled", zap.Int("order_id", orderID))
+	return nil
+}
@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	db "github.com/chrisp986/trader-backend/database"
+	"go.uber.org/zap"
+)
+
+// testExchange is an engine over a fresh database with a few users
+type testExchange struct {
+	t      *testing.T
+	store  *db.SQLStore
+	engine *MatchingEngine
+	prices *recordedPrices
+}
+
+// recordedPrices is a PricePublisher remembering what it was sent
+type recordedPrices struct {
+	prices []float64
+}
+
+func (p *recordedPrices) PublishPrice(symbol string, price float64) {
+	p.prices = append(p.prices, price)
+}
+
+func newTestExchange(t *testing.T) *testExchange {
+	t.Helper()
+
+	dm, err := db.NewDatabaseManager(db.DriverSQLite, filepath.Join(t.TempDir(), "test.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewDatabaseManager: %v", err)
+	}
+	if err := dm.InitializeDatabase(); err != nil {
+		t.Fatalf("InitializeDatabase: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+
+	store := db.NewSQLStore(dm, zap.NewNop())
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if err := store.User.Insert(context.Background(), &db.User{Username: name, Email: name + "@example.com"}); err != nil {
+			t.Fatalf("Insert user %s: %v", name, err)
+		}
+	}
+
+	e := New(dm.DB, store.Order, store.Trade, store.Position, zap.NewNop())
+	prices := &recordedPrices{}
+	e.SetPricePublisher(prices)
+	return &testExchange{t: t, store: store, engine: e, prices: prices}
+}
+
+// submit stores an order for userID and submits it; a zero price makes it a
+// market order
+func (x *testExchange) submit(userID int, side string, quantity, price float64) (*db.Order, []db.Trade) {
+	x.t.Helper()
+
+	order := &db.Order{UserID: userID, Symbol: "AAPL", Side: side, Type: db.OrderTypeMarket, Quantity: quantity}
+	if price != 0 {
+		order.Type = db.OrderTypeLimit
+		order.Price = &price
+	}
+	if err := x.store.Order.Insert(context.Background(), order); err != nil {
+		x.t.Fatalf("Insert order: %v", err)
+	}
+	trades, err := x.engine.Submit(context.Background(), order)
+	if err != nil {
+		x.t.Fatalf("Submit: %v", err)
+	}
+	return order, trades
+}
+
+// stored returns the order as persisted
+func (x *testExchange) stored(order *db.Order) *db.Order {
+	x.t.Helper()
+
+	got, err := x.store.Order.GetByID(context.Background(), order.OrderID)
+	if err != nil {
+		x.t.Fatalf("GetByID: %v", err)
+	}
+	return got
+}
+
+// position returns the user's AAPL quantity and average price
+func (x *testExchange) position(userID int) (float64, float64) {
+	x.t.Helper()
+
+	positions, err := x.store.Position.ListByUser(context.Background(), userID)
+	if err != nil {
+		x.t.Fatalf("ListByUser: %v", err)
+	}
+	if len(positions) == 0 {
+		return 0, 0
+	}
+	return positions[0].Quantity, positions[0].AvgPrice
+}
+
+const (
+	alice = 1
+	bob   = 2
+	carol = 3
+)
+
+func TestSubmitRestsUnmatchedLimitOrder(t *testing.T) {
+	x := newTestExchange(t)
+
+	order, trades := x.submit(alice, db.OrderSideBuy, 5, 100)
+	if len(trades) != 0 || order.Status != db.OrderStatusOpen {
+		t.Fatalf("Submit = %d trades, status %s, want a resting open order", len(trades), order.Status)
+	}
+
+	if err := x.engine.Cancel(context.Background(), order.OrderID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if got := x.stored(order); got.Status != db.OrderStatusCancelled {
+		t.Errorf("stored status = %s, want cancelled", got.Status)
+	}
+	if err := x.engine.Cancel(context.Background(), order.OrderID); !errors.Is(err, ErrNotResting) {
+		t.Errorf("second Cancel = %v, want ErrNotResting", err)
+	}
+
+	// The cancelled order no longer matches
+	if _, trades := x.submit(bob, db.OrderSideSell, 5, 100); len(trades) != 0 {
+		t.Errorf("sell matched %d trades against a cancelled order", len(trades))
+	}
+}
+
+func TestSubmitCrossingMatch(t *testing.T) {
+	x := newTestExchange(t)
+
+	sell, _ := x.submit(alice, db.OrderSideSell, 5, 100)
+	buy, trades := x.submit(bob, db.OrderSideBuy, 5, 101)
+
+	if len(trades) != 2 {
+		t.Fatalf("Submit = %d trades, want one per order", len(trades))
+	}
+	for _, trade := range trades {
+		if trade.Quantity != 5 || trade.Price != 100 || trade.TradeID == 0 {
+			t.Errorf("trade = %+v, want 5 at the resting price 100", trade)
+		}
+	}
+	if buy.Status != db.OrderStatusFilled {
+		t.Errorf("incoming status = %s, want filled", buy.Status)
+	}
+	for _, order := range []*db.Order{sell, buy} {
+		if got := x.stored(order); got.Status != db.OrderStatusFilled || got.FilledQuantity != 5 {
+			t.Errorf("stored order %d = %s with %v filled, want filled with 5", order.OrderID, got.Status, got.FilledQuantity)
+		}
+	}
+
+	if qty, avg := x.position(bob); qty != 5 || avg != 100 {
+		t.Errorf("buyer position = %v at %v, want 5 at 100", qty, avg)
+	}
+	if qty, avg := x.position(alice); qty != -5 || avg != 100 {
+		t.Errorf("seller position = %v at %v, want -5 at 100", qty, avg)
+	}
+	if len(x.prices.prices) != 1 || x.prices.prices[0] != 100 {
+		t.Errorf("published prices = %v, want [100]", x.prices.prices)
+	}
+}
+
+func TestSubmitPartialFill(t *testing.T) {
+	x := newTestExchange(t)
+
+	x.submit(alice, db.OrderSideSell, 3, 100)
+	buy, trades := x.submit(bob, db.OrderSideBuy, 5, 100)
+	if len(trades) != 2 || buy.FilledQuantity != 3 || buy.Status != db.OrderStatusOpen {
+		t.Fatalf("Submit = %d trades, %v filled, status %s, want 3 filled and the rest resting", len(trades), buy.FilledQuantity, buy.Status)
+	}
+
+	// The remainder rests at its limit, so a lower sell fills at 100
+	sell, trades := x.submit(carol, db.OrderSideSell, 2, 99)
+	if len(trades) != 2 || trades[0].Price != 100 || sell.Status != db.OrderStatusFilled {
+		t.Fatalf("second Submit = %+v, status %s, want a fill of the resting remainder at 100", trades, sell.Status)
+	}
+	if got := x.stored(buy); got.Status != db.OrderStatusFilled || got.FilledQuantity != 5 {
+		t.Errorf("partially filled order = %s with %v filled, want filled with 5", got.Status, got.FilledQuantity)
+	}
+}
+
+func TestSubmitPriceTimePriority(t *testing.T) {
+	x := newTestExchange(t)
+
+	first, _ := x.submit(alice, db.OrderSideSell, 1, 100)
+	second, _ := x.submit(carol, db.OrderSideSell, 1, 100)
+	best, _ := x.submit(carol, db.OrderSideSell, 1, 99)
+
+	_, trades := x.submit(bob, db.OrderSideBuy, 2, 0)
+	if len(trades) != 4 {
+		t.Fatalf("Submit = %d trades, want two fills", len(trades))
+	}
+	if trades[1].OrderID != best.OrderID || trades[1].Price != 99 {
+		t.Errorf("first fill = %+v, want the best price %d at 99", trades[1], best.OrderID)
+	}
+	if trades[3].OrderID != first.OrderID || trades[3].Price != 100 {
+		t.Errorf("second fill = %+v, want the oldest order %d at 100", trades[3], first.OrderID)
+	}
+	if got := x.stored(second); got.Status != db.OrderStatusOpen {
+		t.Errorf("newer order at the same price = %s, want it still open", got.Status)
+	}
+}
+
+func TestSubmitMarketOrderWithoutLiquidityIsCancelled(t *testing.T) {
+	x := newTestExchange(t)
+
+	order, trades := x.submit(alice, db.OrderSideBuy, 1, 0)
+	if len(trades) != 0 || order.Status != db.OrderStatusCancelled {
+		t.Errorf("Submit = %d trades, status %s, want cancelled", len(trades), order.Status)
+	}
+	if got := x.stored(order); got.Status != db.OrderStatusCancelled {
+		t.Errorf("stored status = %s, want cancelled", got.Status)
+	}
+}
+
+func TestSubmitPreventsSelfTrade(t *testing.T) {
+	x := newTestExchange(t)
+
+	sell, _ := x.submit(alice, db.OrderSideSell, 1, 100)
+	buy, trades := x.submit(alice, db.OrderSideBuy, 1, 101)
+	if len(trades) != 0 || buy.Status != db.OrderStatusCancelled {
+		t.Fatalf("self-crossing Submit = %d trades, status %s, want cancelled without trades", len(trades), buy.Status)
+	}
+
+	// The resting order is untouched and still matches other users
+	if _, trades := x.submit(bob, db.OrderSideBuy, 1, 100); len(trades) != 2 {
+		t.Errorf("other user's buy = %d trades, want a fill", len(trades))
+	}
+	if got := x.stored(sell); got.Status != db.OrderStatusFilled {
+		t.Errorf("resting order = %s, want filled by the other user", got.Status)
+	}
+}
+
+func TestCancelAllRemovesRestingOrders(t *testing.T) {
+	x := newTestExchange(t)
+
+	x.submit(alice, db.OrderSideSell, 1, 100)
+	x.submit(alice, db.OrderSideSell, 1, 101)
+
+	ids, err := x.engine.CancelAll(context.Background(), alice, "aapl")
+	if err != nil {
+		t.Fatalf("CancelAll: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("CancelAll = %v, want both orders", ids)
+	}
+	if _, trades := x.submit(bob, db.OrderSideBuy, 1, 0); len(trades) != 0 {
+		t.Errorf("buy matched %d trades against cancelled orders", len(trades))
+	}
+}